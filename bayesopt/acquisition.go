@@ -0,0 +1,59 @@
+package bayesopt
+
+import "math"
+
+// Acquisition scores candidate points using the surrogate model's
+// prediction.  Larger scores are more desirable.
+type Acquisition interface {
+	// Acquire returns the score of a point with posterior mean mu and
+	// standard deviation sd given the best (lowest) observed value best.
+	Acquire(mu, sd, best float64) float64
+}
+
+// EI is the expected improvement over the best observed value.  Xi is a
+// margin (in objective units) the improvement must exceed - larger values
+// encourage exploration.
+type EI struct {
+	Xi float64
+}
+
+func (a EI) Acquire(mu, sd, best float64) float64 {
+	imp := best - mu - a.Xi
+	if sd <= 0 {
+		return math.Max(imp, 0)
+	}
+	z := imp / sd
+	return imp*normcdf(z) + sd*normpdf(z)
+}
+
+// PI is the probability of improving on the best observed value by at least
+// Xi (in objective units).  PI is greedy for small Xi and tends to get stuck
+// in local minima.
+type PI struct {
+	Xi float64
+}
+
+func (a PI) Acquire(mu, sd, best float64) float64 {
+	imp := best - mu - a.Xi
+	if sd <= 0 {
+		if imp > 0 {
+			return 1
+		}
+		return 0
+	}
+	return normcdf(imp / sd)
+}
+
+// UCB is the (negated) lower confidence bound mu - sqrt(Beta)*sd.  Larger
+// Beta values encourage exploration.
+type UCB struct {
+	Beta float64
+}
+
+func (a UCB) Acquire(mu, sd, best float64) float64 {
+	return -(mu - math.Sqrt(a.Beta)*sd)
+}
+
+func normpdf(z float64) float64 { return math.Exp(-z*z/2) / math.Sqrt(2*math.Pi) }
+
+func normcdf(z float64) float64 { return 0.5 * math.Erfc(-z/math.Sqrt2) }
@@ -0,0 +1,405 @@
+// Package bayesopt provides Bayesian optimization for expensive objectives
+// using a Gaussian process surrogate model as described in:
+//
+//     Jones, Donald R., Matthias Schonlau, and William J. Welch. "Efficient
+//     global optimization of expensive black-box functions." Journal of
+//     Global Optimization 13.4 (1998): 455-492.
+//
+//     Ginsbourger, David, Rodolphe Le Riche, and Laurent Carraro. "Kriging is
+//     well-suited to parallelize optimization." Computational Intelligence in
+//     Expensive Optimization Problems. Springer (2010): 131-162.
+//
+// The search space is the box defined by lower and upper bounds.  After an
+// initial Latin hypercube design, each call to Iterate refits the model's
+// hyperparameters and evaluates the points maximizing an acquisition
+// function.  Batches of more than one point are built with the kriging
+// believer heuristic so they can be evaluated concurrently (e.g. with
+// optim.ParallelEvaler).  The model can be seeded with previous evaluations
+// from an optim.CacheEvaler or a database (see optim.LoadPoints).
+package bayesopt
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/neldermead"
+)
+
+const (
+	// TblObs is the name of the sql database table that contains every
+	// point evaluated by the method.
+	TblObs = "bayesobs"
+	// TblModel is the name of the sql database table that contains the
+	// fitted GP hyperparameters for each iteration.
+	TblModel = "bayesmodel"
+)
+
+const DefaultXi = 0.01
+
+const (
+	// NCandidates is the number of random candidates scored when maximizing
+	// the acquisition function.
+	NCandidates = 1000
+	// NRefine is the number of best candidates that are locally refined
+	// with Nelder-Mead.
+	NRefine = 3
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Acquire sets the acquisition function (EI by default).
+func Acquire(a Acquisition) Option { return func(m *Method) { m.Acq = a } }
+
+// Batch sets the number of points proposed and evaluated per iteration.
+func Batch(q int) Option { return func(m *Method) { m.BatchSize = q } }
+
+// InitDesign sets the number of observations required before the surrogate
+// model is used.  If fewer observations (including seeds) are available, the
+// remainder are sampled from a Latin hypercube design.  At least one
+// observation is always required.
+func InitDesign(n int) Option { return func(m *Method) { m.NInit = n } }
+
+// Seed adds previously evaluated points to the model's observations.
+// Points with infinite or NaN values are ignored.
+func Seed(pts ...*optim.Point) Option {
+	return func(m *Method) {
+		for _, p := range pts {
+			m.observe(p)
+		}
+	}
+}
+
+type Method struct {
+	Low, Up   []float64
+	GP        *GP
+	Acq       Acquisition
+	BatchSize int
+	NInit     int
+	// Obs holds all observations used to fit the surrogate model.
+	Obs  []*optim.Point
+	Db   *sql.DB
+	ev   optim.Evaler
+	best *optim.Point
+	iter int
+	// seen holds the hashes of all proposed and observed positions while
+	// observed only holds those in Obs.
+	seen     map[[sha1.Size]byte]bool
+	observed map[[sha1.Size]byte]bool
+	// fitted is true if the model was fit in the current iteration.
+	fitted bool
+	loglik float64
+	// added holds the points evaluated in the current iteration.
+	added []*optim.Point
+}
+
+// New creates a Bayesian optimizer searching the box defined by low and up.
+// By default, 2*ndim+1 initial design points are evaluated before the
+// surrogate model is used.
+func New(low, up []float64, opts ...Option) *Method {
+	m := &Method{
+		Low:       append([]float64{}, low...),
+		Up:        append([]float64{}, up...),
+		GP:        NewGP(len(low)),
+		Acq:       EI{DefaultXi},
+		BatchSize: 1,
+		NInit:     2*len(low) + 1,
+		ev:        optim.SerialEvaler{},
+		best:      &optim.Point{Val: math.Inf(1)},
+		seen:      map[[sha1.Size]byte]bool{},
+		observed:  map[[sha1.Size]byte]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint adds p to the model's observations.
+func (m *Method) AddPoint(p *optim.Point) { m.observe(p.Clone()) }
+
+func (m *Method) observe(p *optim.Point) {
+	h := p.Hash()
+	m.seen[h] = true
+	if math.IsInf(p.Val, 0) || math.IsNaN(p.Val) || m.observed[h] {
+		return
+	}
+	m.observed[h] = true
+	m.Obs = append(m.Obs, p)
+	if p.Val < m.best.Val {
+		m.best = p
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	m.added = nil
+	m.fitted = false
+	defer m.updateDb()
+
+	ninit := m.NInit
+	if ninit < 1 {
+		ninit = 1
+	}
+
+	var pts []*optim.Point
+	if len(m.Obs) < ninit {
+		pts = m.design(ninit-len(m.Obs), mesh)
+	} else {
+		pts, err = m.propose(mesh)
+		if err != nil {
+			return m.best, 0, err
+		}
+	}
+	if len(pts) == 0 {
+		// every candidate on the mesh has already been observed
+		return m.best, 0, optim.ErrConverged
+	}
+
+	_, n, err = m.ev.Eval(obj, pts...)
+	for _, p := range pts {
+		m.observe(p)
+	}
+	m.added = pts
+	return m.best, n, err
+}
+
+// design returns n unseen points from a Latin hypercube design.
+func (m *Method) design(n int, mesh optim.Mesh) []*optim.Point {
+	ndim := len(m.Low)
+	perms := make([][]int, ndim)
+	for j := range perms {
+		perms[j] = optim.Rand.Perm(n)
+	}
+
+	pts := []*optim.Point{}
+	for i := 0; i < n; i++ {
+		u := make([]float64, ndim)
+		for j := range u {
+			u[j] = (float64(perms[j][i]) + optim.RandFloat()) / float64(n)
+		}
+		p := &optim.Point{Pos: mesh.Nearest(m.denormalize(u)), Val: math.Inf(1)}
+		if h := p.Hash(); !m.seen[h] {
+			m.seen[h] = true
+			pts = append(pts, p)
+		}
+	}
+	return pts
+}
+
+// propose refits the surrogate model and returns a batch of unseen points
+// maximizing the acquisition function.
+func (m *Method) propose(mesh optim.Mesh) ([]*optim.Point, error) {
+	gp := m.GP
+	gp.X, gp.Y = nil, nil
+	for _, p := range m.Obs {
+		gp.Add(m.normalize(p.Pos), p.Val)
+	}
+	if err := gp.Fit(); err != nil {
+		return nil, err
+	}
+	m.fitted, m.loglik = true, gp.LogLikelihood()
+
+	pts := []*optim.Point{}
+	for len(pts) < m.BatchSize {
+		fbest := math.Inf(1)
+		for _, y := range gp.Y {
+			fbest = math.Min(fbest, y)
+		}
+
+		var next *optim.Point
+		for _, u := range m.candidates(gp, fbest) {
+			p := &optim.Point{Pos: mesh.Nearest(m.denormalize(u)), Val: math.Inf(1)}
+			if h := p.Hash(); !m.seen[h] {
+				m.seen[h] = true
+				next = p
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		pts = append(pts, next)
+
+		if len(pts) < m.BatchSize {
+			// kriging believer: pretend the model's prediction was observed
+			u := m.normalize(next.Pos)
+			mu, _ := gp.Predict(u)
+			gp.Add(u, mu)
+			if err := gp.Update(); err != nil {
+				return pts, nil
+			}
+		}
+	}
+	return pts, nil
+}
+
+// candidates returns normalized positions sorted from best to worst
+// acquisition score.
+func (m *Method) candidates(gp *GP, fbest float64) [][]float64 {
+	ndim := len(m.Low)
+	acq := func(u []float64) float64 {
+		mu, sd := gp.Predict(u)
+		return m.Acq.Acquire(mu, sd, fbest)
+	}
+
+	cands := make([][]float64, 0, NCandidates)
+	for i := 0; i < NCandidates/2; i++ {
+		u := make([]float64, ndim)
+		for j := range u {
+			u[j] = optim.RandFloat()
+		}
+		cands = append(cands, u)
+	}
+	// local candidates around the best observations
+	ranked := append([]*optim.Point{}, m.Obs...)
+	sort.Sort(byval(ranked))
+	ntop := len(ranked)
+	if ntop > 5 {
+		ntop = 5
+	}
+	for i := 0; ntop > 0 && len(cands) < NCandidates; i++ {
+		u := m.normalize(ranked[i%ntop].Pos)
+		for j := range u {
+			u[j] = math.Min(1, math.Max(0, u[j]+0.05*optim.RandNorm()))
+		}
+		cands = append(cands, u)
+	}
+
+	scores := make([]float64, len(cands))
+	for i, u := range cands {
+		scores[i] = acq(u)
+	}
+	sort.Sort(byscore{cands, scores})
+
+	// polish the best candidates
+	low, up := make([]float64, ndim), make([]float64, ndim)
+	for j := range up {
+		up[j] = 1
+	}
+	mesh := &optim.BoxMesh{Mesh: &optim.InfMesh{}, Lower: low, Upper: up}
+	obj := optim.Func(func(u []float64) float64 { return -acq(u) })
+	for i := 0; i < NRefine && i < len(cands); i++ {
+		start := &optim.Point{Pos: cands[i], Val: -scores[i]}
+		nm := neldermead.New(start, neldermead.InitStep(0.05))
+		solv := &optim.Solver{Method: nm, Obj: obj, Mesh: mesh, MaxEval: 50 * ndim}
+		solv.Run()
+		if best := solv.Best(); -best.Val > scores[i] {
+			cands[i], scores[i] = best.Pos, -best.Val
+		}
+	}
+	sort.Sort(byscore{cands, scores})
+	return cands
+}
+
+func (m *Method) normalize(x []float64) []float64 {
+	u := make([]float64, len(x))
+	for i := range u {
+		u[i] = (x[i] - m.Low[i]) / (m.Up[i] - m.Low[i])
+	}
+	return u
+}
+
+func (m *Method) denormalize(u []float64) []float64 {
+	x := make([]float64, len(u))
+	for i := range x {
+		x[i] = m.Low[i] + u[i]*(m.Up[i]-m.Low[i])
+	}
+	return x
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblObs + " (iter INTEGER, val REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblModel + " (iter INTEGER, dim INTEGER, length REAL, signalvar REAL, noise REAL, loglik REAL);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s0, err := tx.Prepare("INSERT INTO " + TblObs + " (iter,val,posid) VALUES (?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+	for _, p := range m.added {
+		_, err := s0.Exec(m.iter, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+	}
+
+	if m.fitted {
+		s1, err := tx.Prepare("INSERT INTO " + TblModel + " (iter,dim,length,signalvar,noise,loglik) VALUES (?,?,?,?,?,?);")
+		if checkdberr(err) {
+			return
+		}
+		for i, l := range m.GP.Lengths {
+			_, err := s1.Exec(m.iter, i, l, m.GP.SignalVar, m.GP.Noise, m.loglik)
+			if checkdberr(err) {
+				return
+			}
+		}
+	}
+
+	err = optim.RecordPointPos(tx, m.added...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+type byval []*optim.Point
+
+func (b byval) Len() int           { return len(b) }
+func (b byval) Less(i, j int) bool { return b[i].Val < b[j].Val }
+func (b byval) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+// byscore sorts candidates by decreasing score.
+type byscore struct {
+	cands  [][]float64
+	scores []float64
+}
+
+func (b byscore) Len() int           { return len(b.cands) }
+func (b byscore) Less(i, j int) bool { return b.scores[i] > b.scores[j] }
+func (b byscore) Swap(i, j int) {
+	b.cands[i], b.cands[j] = b.cands[j], b.cands[i]
+	b.scores[i], b.scores[j] = b.scores[j], b.scores[i]
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("bayesopt: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package bayesopt
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+	"github.com/baaaaam/optim/pattern"
+)
+
+func TestGP(t *testing.T) {
+	f := func(x float64) float64 { return math.Sin(6 * x) }
+	gp := NewGP(1)
+	for _, x := range []float64{0, 0.2, 0.4, 0.6, 0.8, 1} {
+		gp.Add([]float64{x}, f(x))
+	}
+	if err := gp.Update(); err != nil {
+		t.Fatal(err)
+	}
+	before := gp.LogLikelihood()
+	if err := gp.Fit(); err != nil {
+		t.Fatal(err)
+	}
+	after := gp.LogLikelihood()
+	t.Logf("[INFO] log likelihood %v -> %v (length=%v, signal=%v, noise=%v)", before, after, gp.Lengths[0], gp.SignalVar, gp.Noise)
+	if after < before {
+		t.Errorf("fitting decreased log likelihood from %v to %v", before, after)
+	}
+
+	for _, x := range gp.X {
+		mu, sd := gp.Predict(x)
+		if math.Abs(mu-f(x[0])) > 1e-2 || sd > 1e-1 {
+			t.Errorf("f(%v): want %v with small sd, got %v +/- %v", x[0], f(x[0]), mu, sd)
+		}
+	}
+	if mu, _ := gp.Predict([]float64{0.5}); math.Abs(mu-f(0.5)) > 0.05 {
+		t.Errorf("f(0.5): want %v, got %v", f(0.5), mu)
+	}
+	_, sdnear := gp.Predict([]float64{0.45})
+	_, sdfar := gp.Predict([]float64{3})
+	if sdfar <= sdnear {
+		t.Errorf("uncertainty far from data (%v) not larger than near data (%v)", sdfar, sdnear)
+	}
+}
+
+func TestAcquisitions(t *testing.T) {
+	acqs := []Acquisition{EI{0}, PI{0}, UCB{4}}
+	for _, a := range acqs {
+		if a.Acquire(0, 1, 1) <= a.Acquire(0.5, 1, 1) {
+			t.Errorf("%T: lower mean not preferred", a)
+		}
+	}
+	for _, a := range []Acquisition{EI{0}, UCB{4}} {
+		if a.Acquire(2, 2, 1) <= a.Acquire(2, 1, 1) {
+			t.Errorf("%T: higher uncertainty not preferred when mean is worse than best", a)
+		}
+	}
+	if v := (EI{}).Acquire(2, 0, 1); v != 0 {
+		t.Errorf("EI without uncertainty or improvement: want 0, got %v", v)
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+
+	cases := []struct {
+		name string
+		acq  Acquisition
+	}{
+		{"EI", EI{DefaultXi}},
+		{"PI", PI{1}},
+		{"UCB", UCB{4}},
+	}
+	for _, c := range cases {
+		solv := &optim.Solver{
+			Method:  New(low, up, Acquire(c.acq)),
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 60,
+		}
+		for solv.Next() {
+			if solv.Best().Val < fn.Tol() {
+				break
+			}
+		}
+
+		t.Logf("[INFO] %v: %v evals: got %v", c.name, solv.Neval(), solv.Best())
+		if solv.Best().Val > fn.Tol() {
+			t.Errorf("%v: want < %v, got %v", c.name, fn.Tol(), solv.Best().Val)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	q := 4
+	m := New(low, up, Batch(q), Evaler(optim.ParallelEvaler{}))
+	obj := optim.Func(fn.Eval)
+	mesh := &optim.InfMesh{}
+
+	if _, n, err := m.Iterate(obj, mesh); err != nil || n != m.NInit {
+		t.Fatalf("initial design: want %v evals, got %v (err=%v)", m.NInit, n, err)
+	}
+	for i := 0; i < 15 && m.best.Val > fn.Tol(); i++ {
+		_, n, err := m.Iterate(obj, mesh)
+		if err != nil {
+			t.Fatal(err)
+		} else if n != q {
+			t.Fatalf("iter %v: want %v evals, got %v", i, q, n)
+		}
+	}
+
+	seen := map[[20]byte]bool{}
+	for _, p := range m.Obs {
+		if seen[p.Hash()] {
+			t.Errorf("point %v evaluated twice", p.Pos)
+		}
+		seen[p.Hash()] = true
+	}
+	t.Logf("[INFO] %v evals: got %v", len(m.Obs), m.best)
+	if m.best.Val > fn.Tol() {
+		t.Errorf("want < %v, got %v", fn.Tol(), m.best.Val)
+	}
+}
+
+func TestIntMesh(t *testing.T) {
+	obj := optim.Func(func(x []float64) float64 {
+		return (x[0]-3)*(x[0]-3) + (x[1]+2)*(x[1]+2)
+	})
+	solv := &optim.Solver{
+		Method:  New([]float64{-5, -5}, []float64{5, 5}),
+		Obj:     obj,
+		Mesh:    &optim.IntMesh{Mesh: &optim.InfMesh{}},
+		MaxEval: 30,
+	}
+	for solv.Next() {
+		if solv.Best().Val == 0 {
+			break
+		}
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if solv.Best().Val != 0 {
+		t.Errorf("want [3 -2], got %v", solv.Best())
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	// only 4 integer points in [0,1]^2
+	obj := optim.Func(func(x []float64) float64 { return x[0] + x[1] })
+	solv := &optim.Solver{
+		Method:  New([]float64{0, 0}, []float64{1, 1}),
+		Obj:     obj,
+		Mesh:    &optim.IntMesh{Mesh: &optim.InfMesh{}},
+		MaxEval: 30,
+	}
+	err := solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if err != nil {
+		t.Errorf("want solver to stop without error, got %v", err)
+	}
+	if solv.Neval() > 4 || solv.Best().Val != 0 {
+		t.Errorf("want [0 0] after at most 4 evals, got %v after %v", solv.Best(), solv.Neval())
+	}
+}
+
+func TestNoInitDesign(t *testing.T) {
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	solv := &optim.Solver{
+		Method:  New(low, up, InitDesign(0)),
+		Obj:     optim.Func(fn.Eval),
+		MaxIter: 5,
+	}
+	if err := solv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if solv.Neval() != 5 {
+		t.Errorf("want 5 evals, got %v", solv.Neval())
+	}
+}
+
+func TestSeedCache(t *testing.T) {
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	obj := optim.Func(fn.Eval)
+
+	cache := optim.NewCacheEvaler(optim.SerialEvaler{})
+	cache.Eval(obj, optim.RandPop(10, low, up)...)
+
+	m := New(low, up, Seed(cache.Points()...))
+	if len(m.Obs) != 10 {
+		t.Fatalf("want 10 seeded observations, got %v", len(m.Obs))
+	}
+	_, n, err := m.Iterate(obj, &optim.InfMesh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || !m.fitted {
+		t.Errorf("seeded model should skip the initial design: got %v evals", n)
+	}
+}
+
+func TestSeedDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	start := &optim.Point{Pos: []float64{4, 4}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  pattern.New(start, pattern.DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{StepSize: 1},
+		MaxIter: 5,
+	}
+	solv.Run()
+
+	pts, err := optim.LoadPoints(db, pattern.TblPolls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(low, up, Seed(pts...))
+	t.Logf("[INFO] seeded %v points from %v", len(m.Obs), pattern.TblPolls)
+	if len(m.Obs) < m.NInit {
+		t.Fatalf("want at least %v seeded observations, got %v", m.NInit, len(m.Obs))
+	}
+	for _, p := range m.Obs {
+		if v := fn.Eval(p.Pos); v != p.Val {
+			t.Errorf("seeded point %v: want value %v", p, v)
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	solv := &optim.Solver{
+		Method:  New(low, up, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 4,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblObs, TblModel} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+
+	// recorded evaluations can be used to seed a new model
+	pts, err := optim.LoadPoints(db, TblObs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != solv.Neval() {
+		t.Errorf("loaded %v points, want %v", len(pts), solv.Neval())
+	}
+}
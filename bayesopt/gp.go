@@ -0,0 +1,247 @@
+package bayesopt
+
+import (
+	"errors"
+	"math"
+
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/neldermead"
+)
+
+// Bounds on the (natural log of the) GP hyperparameters.  Inputs are
+// normalized to the unit cube and outputs are standardized, so these are
+// problem independent.
+var (
+	LengthBounds = [2]float64{math.Log(1e-2), math.Log(1e1)}
+	SignalBounds = [2]float64{math.Log(5e-2), math.Log(2e1)}
+	NoiseBounds  = [2]float64{math.Log(1e-6), math.Log(1)}
+)
+
+// jitter is added to the kernel matrix diagonal for numerical stability.
+const jitter = 1e-10
+
+var errNotPosDef = errors.New("bayesopt: kernel matrix not positive definite")
+
+// GP is a Gaussian process regression model with a constant mean and an
+// anisotropic Matern 5/2 kernel.  Observed values are standardized before
+// fitting, so SignalVar and Noise are relative to the observed variance.
+type GP struct {
+	X [][]float64
+	Y []float64
+	// Lengths holds the kernel length scale for each input dimension.
+	Lengths   []float64
+	SignalVar float64
+	// Noise is the observation noise variance.
+	Noise float64
+
+	ymean, ystd float64
+	chol        [][]float64
+	alpha       []float64
+}
+
+// NewGP returns a model for ndim inputs with default hyperparameters.
+func NewGP(ndim int) *GP {
+	gp := &GP{Lengths: make([]float64, ndim), SignalVar: 1, Noise: 1e-4}
+	for i := range gp.Lengths {
+		gp.Lengths[i] = 0.3
+	}
+	return gp
+}
+
+// Add appends an observation.  Update or Fit must be called before the
+// model reflects the new data.
+func (gp *GP) Add(x []float64, y float64) {
+	gp.X = append(gp.X, x)
+	gp.Y = append(gp.Y, y)
+}
+
+func (gp *GP) kernel(a, b []float64) float64 {
+	r2 := 0.0
+	for i := range a {
+		d := (a[i] - b[i]) / gp.Lengths[i]
+		r2 += d * d
+	}
+	r := math.Sqrt(5 * r2)
+	return gp.SignalVar * (1 + r + r*r/3) * math.Exp(-r)
+}
+
+// Update factors the kernel matrix using the current hyperparameters.
+func (gp *GP) Update() error {
+	n := len(gp.Y)
+	gp.ymean, gp.ystd = 0, 0
+	for _, y := range gp.Y {
+		gp.ymean += y / float64(n)
+	}
+	for _, y := range gp.Y {
+		gp.ystd += (y - gp.ymean) * (y - gp.ymean) / float64(n)
+	}
+	gp.ystd = math.Sqrt(gp.ystd)
+	if gp.ystd == 0 {
+		gp.ystd = 1
+	}
+
+	K := make([][]float64, n)
+	for i := range K {
+		K[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			K[i][j] = gp.kernel(gp.X[i], gp.X[j])
+			K[j][i] = K[i][j]
+		}
+		K[i][i] += gp.Noise + jitter
+	}
+	chol, ok := cholesky(K)
+	if !ok {
+		return errNotPosDef
+	}
+	gp.chol = chol
+
+	z := make([]float64, n)
+	for i, y := range gp.Y {
+		z[i] = (y - gp.ymean) / gp.ystd
+	}
+	gp.alpha = cholsolve(chol, z)
+	return nil
+}
+
+// Predict returns the posterior mean and standard deviation at x.
+func (gp *GP) Predict(x []float64) (mu, sd float64) {
+	k := make([]float64, len(gp.X))
+	for i, xi := range gp.X {
+		k[i] = gp.kernel(x, xi)
+		mu += k[i] * gp.alpha[i]
+	}
+	v := forwardsub(gp.chol, k)
+	variance := gp.SignalVar
+	for _, vi := range v {
+		variance -= vi * vi
+	}
+	sd = math.Sqrt(math.Max(variance, 0))
+	return gp.ymean + gp.ystd*mu, gp.ystd * sd
+}
+
+// LogLikelihood returns the log marginal likelihood of the standardized
+// observations.  Update must be called first.
+func (gp *GP) LogLikelihood() float64 {
+	n := len(gp.Y)
+	ll := -0.5 * float64(n) * math.Log(2*math.Pi)
+	for i := 0; i < n; i++ {
+		z := (gp.Y[i] - gp.ymean) / gp.ystd
+		ll -= 0.5*z*gp.alpha[i] + math.Log(gp.chol[i][i])
+	}
+	return ll
+}
+
+// Fit sets the hyperparameters to maximize the log marginal likelihood.
+// Nelder-Mead is started from both the current and the default
+// hyperparameters so that a poor previous fit (e.g. explaining all variation
+// as noise) is not carried forward.
+func (gp *GP) Fit() error {
+	ndim := len(gp.Lengths)
+	low := make([]float64, ndim+2)
+	up := make([]float64, ndim+2)
+	for i := range gp.Lengths {
+		low[i], up[i] = LengthBounds[0], LengthBounds[1]
+	}
+	low[ndim], up[ndim] = SignalBounds[0], SignalBounds[1]
+	low[ndim+1], up[ndim+1] = NoiseBounds[0], NoiseBounds[1]
+
+	obj := optim.Func(func(theta []float64) float64 {
+		gp.setHypers(theta)
+		if gp.Update() != nil {
+			return math.Inf(1)
+		}
+		return -gp.LogLikelihood()
+	})
+
+	mesh := &optim.BoxMesh{Mesh: &optim.InfMesh{}, Lower: low, Upper: up}
+	best := &optim.Point{Val: math.Inf(1)}
+	for _, theta := range [][]float64{gp.hypers(), NewGP(ndim).hypers()} {
+		start := &optim.Point{Pos: mesh.Nearest(theta), Val: math.Inf(1)}
+		nm := neldermead.New(start, neldermead.InitStep(1))
+		solv := &optim.Solver{Method: nm, Obj: obj, Mesh: mesh, MaxEval: 100 * len(theta)}
+		for solv.Next() {
+			if nm.Diameter() < 1e-3 {
+				break
+			}
+		}
+		if solv.Best().Val < best.Val {
+			best = solv.Best()
+		}
+	}
+
+	if best.Pos != nil {
+		gp.setHypers(best.Pos)
+	}
+	return gp.Update()
+}
+
+// hypers returns the natural log of the length scales, signal variance and
+// noise variance.
+func (gp *GP) hypers() []float64 {
+	theta := make([]float64, 0, len(gp.Lengths)+2)
+	for _, l := range gp.Lengths {
+		theta = append(theta, math.Log(l))
+	}
+	return append(theta, math.Log(gp.SignalVar), math.Log(gp.Noise))
+}
+
+func (gp *GP) setHypers(theta []float64) {
+	ndim := len(gp.Lengths)
+	for i := range gp.Lengths {
+		gp.Lengths[i] = math.Exp(theta[i])
+	}
+	gp.SignalVar = math.Exp(theta[ndim])
+	gp.Noise = math.Exp(theta[ndim+1])
+}
+
+// cholesky returns the lower triangular Cholesky factor of the symmetric
+// matrix a.
+func cholesky(a [][]float64) (l [][]float64, ok bool) {
+	n := len(a)
+	l = make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, true
+}
+
+// forwardsub solves l*x = b for lower triangular l.
+func forwardsub(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range x {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
+
+// cholsolve solves l*l^T*x = b.
+func cholsolve(l [][]float64, b []float64) []float64 {
+	y := forwardsub(l, b)
+	x := make([]float64, len(y))
+	for i := len(y) - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < len(y); k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
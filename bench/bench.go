@@ -46,6 +46,22 @@ type Func interface {
 	Name() string
 }
 
+// Differentiable is implemented by benchmark functions with an analytic
+// gradient.  Like Eval, Grad is not defined outside the function's bounds.
+type Differentiable interface {
+	Func
+	Grad(v []float64) []float64
+}
+
+// Objective returns an optim.Objectiver for fn that also implements
+// optim.Gradienter if fn is Differentiable.
+func Objective(fn Func) optim.Objectiver {
+	if d, ok := fn.(Differentiable); ok {
+		return optim.FuncGrad{Obj: d.Eval, Grad: d.Grad}
+	}
+	return optim.Func(fn.Eval)
+}
+
 type Ackley struct{}
 
 func (fn Ackley) Name() string { return "Ackley" }
@@ -62,6 +78,20 @@ func (fn Ackley) Eval(v []float64) float64 {
 		20 + math.E
 }
 
+func (fn Ackley) Grad(v []float64) []float64 {
+	x := v[0]
+	y := v[1]
+	r := math.Sqrt(0.5 * (x*x + y*y))
+	c := math.Exp(0.5 * (math.Cos(2*math.Pi*x) + math.Cos(2*math.Pi*y)))
+	g := []float64{math.Pi * math.Sin(2*math.Pi*x) * c, math.Pi * math.Sin(2*math.Pi*y) * c}
+	if r > 0 {
+		// the first term is not differentiable at the origin
+		g[0] += 2 * x * math.Exp(-0.2*r) / r
+		g[1] += 2 * y * math.Exp(-0.2*r) / r
+	}
+	return g
+}
+
 func (fn Ackley) Tol() float64 { return .01 }
 
 func (fn Ackley) Bounds() (low, up []float64) {
@@ -70,7 +100,7 @@ func (fn Ackley) Bounds() (low, up []float64) {
 
 func (fn Ackley) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{[]float64{0, 0}, 0},
+		&optim.Point{Pos: []float64{0, 0}, Val: 0},
 	}
 }
 
@@ -96,10 +126,10 @@ func (fn CrossTray) Bounds() (low, up []float64) {
 
 func (fn CrossTray) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{[]float64{1.34941, -1.34941}, -2.06261},
-		&optim.Point{[]float64{1.34941, 1.34941}, -2.06261},
-		&optim.Point{[]float64{-1.34941, 1.34941}, -2.06261},
-		&optim.Point{[]float64{-1.34941, -1.34941}, -2.06261},
+		&optim.Point{Pos: []float64{1.34941, -1.34941}, Val: -2.06261},
+		&optim.Point{Pos: []float64{1.34941, 1.34941}, Val: -2.06261},
+		&optim.Point{Pos: []float64{-1.34941, 1.34941}, Val: -2.06261},
+		&optim.Point{Pos: []float64{-1.34941, -1.34941}, Val: -2.06261},
 	}
 }
 
@@ -125,7 +155,7 @@ func (fn Eggholder) Bounds() (low, up []float64) {
 
 func (fn Eggholder) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{[]float64{512, 404.2319}, -959.6407},
+		&optim.Point{Pos: []float64{512, 404.2319}, Val: -959.6407},
 	}
 }
 
@@ -151,10 +181,10 @@ func (fn HolderTable) Bounds() (low, up []float64) {
 
 func (fn HolderTable) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{[]float64{8.05502, 9.66459}, -19.2085},
-		&optim.Point{[]float64{-8.05502, 9.66459}, -19.2085},
-		&optim.Point{[]float64{8.05502, -9.66459}, -19.2085},
-		&optim.Point{[]float64{-8.05502, -9.66459}, -19.2085},
+		&optim.Point{Pos: []float64{8.05502, 9.66459}, Val: -19.2085},
+		&optim.Point{Pos: []float64{-8.05502, 9.66459}, Val: -19.2085},
+		&optim.Point{Pos: []float64{8.05502, -9.66459}, Val: -19.2085},
+		&optim.Point{Pos: []float64{-8.05502, -9.66459}, Val: -19.2085},
 	}
 }
 
@@ -180,7 +210,7 @@ func (fn Schaffer2) Bounds() (low, up []float64) {
 
 func (fn Schaffer2) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{[]float64{0, 0}, 0},
+		&optim.Point{Pos: []float64{0, 0}, Val: 0},
 	}
 }
 
@@ -190,6 +220,14 @@ type Styblinski struct {
 
 func (fn Styblinski) Name() string { return fmt.Sprintf("Styblinski_%vD", fn.NDim) }
 
+func (fn Styblinski) Grad(x []float64) []float64 {
+	g := make([]float64, len(x))
+	for i, v := range x {
+		g[i] = (4*v*v*v - 32*v + 5) / 2
+	}
+	return g
+}
+
 func (fn Styblinski) Tol() float64 { return fn.Optima()[0].Val + math.Abs(fn.Optima()[0].Val*.01) }
 
 func (fn Styblinski) Eval(x []float64) float64 {
@@ -220,7 +258,7 @@ func (fn Styblinski) Optima() []*optim.Point {
 		pos[i] = -2.903534
 	}
 	return []*optim.Point{
-		&optim.Point{pos, -39.16599 * float64(fn.NDim)},
+		&optim.Point{Pos: pos, Val: -39.16599 * float64(fn.NDim)},
 	}
 }
 
@@ -230,6 +268,14 @@ type Rastrigin struct {
 
 func (fn Rastrigin) Name() string { return fmt.Sprintf("Rastrigrin_%vD", fn.NDim) }
 
+func (fn Rastrigin) Grad(x []float64) []float64 {
+	g := make([]float64, fn.NDim)
+	for i := range g {
+		g[i] = 2*x[i] + 20*math.Pi*math.Sin(2*math.Pi*x[i])
+	}
+	return g
+}
+
 func (fn Rastrigin) Tol() float64 { return 5.0 / 3.0 * float64(fn.NDim) }
 
 func (fn Rastrigin) Eval(x []float64) float64 {
@@ -256,7 +302,7 @@ func (fn Rastrigin) Bounds() (low, up []float64) {
 
 func (fn Rastrigin) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{make([]float64, fn.NDim), 0},
+		&optim.Point{Pos: make([]float64, fn.NDim), Val: 0},
 	}
 }
 
@@ -266,6 +312,21 @@ type Griewank struct {
 
 func (fn Griewank) Name() string { return fmt.Sprintf("Griewank_%vD", fn.NDim) }
 
+func (fn Griewank) Grad(x []float64) []float64 {
+	g := make([]float64, fn.NDim)
+	for i := range g {
+		si := math.Sqrt(float64(i + 1))
+		prod := math.Sin(x[i]/si) / si
+		for j := 0; j < fn.NDim; j++ {
+			if j != i {
+				prod *= math.Cos(x[j] / math.Sqrt(float64(j+1)))
+			}
+		}
+		g[i] = x[i]/2000 + prod
+	}
+	return g
+}
+
 func (fn Griewank) Tol() float64 { return .1 } //return .1/ 30.0 * float64(fn.NDim) }
 
 func (fn Griewank) Eval(x []float64) float64 {
@@ -294,7 +355,7 @@ func (fn Griewank) Bounds() (low, up []float64) {
 
 func (fn Griewank) Optima() []*optim.Point {
 	return []*optim.Point{
-		&optim.Point{make([]float64, fn.NDim), 0},
+		&optim.Point{Pos: make([]float64, fn.NDim), Val: 0},
 	}
 }
 
@@ -304,6 +365,16 @@ type Rosenbrock struct {
 
 func (fn Rosenbrock) Name() string { return fmt.Sprintf("Rosenbrock_%vD", fn.NDim) }
 
+func (fn Rosenbrock) Grad(x []float64) []float64 {
+	g := make([]float64, fn.NDim)
+	for i := 0; i < fn.NDim-1; i++ {
+		diff1 := x[i+1] - x[i]*x[i]
+		g[i] += -400*x[i]*diff1 + 2*(x[i]-1)
+		g[i+1] += 200 * diff1
+	}
+	return g
+}
+
 func (fn Rosenbrock) Tol() float64 { return 10.0 / 3 * float64(fn.NDim) }
 
 func (fn Rosenbrock) Eval(x []float64) float64 {
@@ -337,7 +408,7 @@ func (fn Rosenbrock) Optima() []*optim.Point {
 		pos[i] = 1
 	}
 	return []*optim.Point{
-		&optim.Point{pos, 0},
+		&optim.Point{Pos: pos, Val: 0},
 	}
 }
 
@@ -9,6 +9,9 @@ import (
 	_ "github.com/baaaaam/go-sqlite/sqlite3"
 	"github.com/baaaaam/optim"
 	"github.com/baaaaam/optim/bench"
+	"github.com/baaaaam/optim/cmaes"
+	"github.com/baaaaam/optim/coord"
+	"github.com/baaaaam/optim/hookejeeves"
 	"github.com/baaaaam/optim/pattern"
 	"github.com/baaaaam/optim/swarm"
 )
@@ -24,6 +27,18 @@ const seed = 7
 
 func init() { bench.BenchSeed = seed }
 
+// starts generates the initial points for benchmark runs.  It is kept
+// separate from optim.Rand so that starts don't shift when a method's own
+// random draws change.
+var starts = rand.New(rand.NewSource(seed))
+
+// benchmark runs bench.Benchmark with starts reseeded so that each test's
+// runs begin from the same points regardless of which tests ran before.
+func benchmark(t *testing.T, fn bench.Func, sfn func() *optim.Solver, successfrac, avgeval float64) {
+	starts = rand.New(rand.NewSource(seed))
+	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+}
+
 func TestBenchSwarmRosen(t *testing.T) {
 	ndim := 30
 	npar := 30
@@ -39,7 +54,7 @@ func TestBenchSwarmRosen(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
 }
 
 func TestBenchSwarmGriewank(t *testing.T) {
@@ -57,7 +72,7 @@ func TestBenchSwarmGriewank(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
 }
 
 func TestBenchSwarmRastrigin(t *testing.T) {
@@ -75,7 +90,7 @@ func TestBenchSwarmRastrigin(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
 }
 
 func TestBenchPSwarmRosen(t *testing.T) {
@@ -95,7 +110,7 @@ func TestBenchPSwarmRosen(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
 }
 
 func TestBenchPSwarmGriewank(t *testing.T) {
@@ -115,7 +130,7 @@ func TestBenchPSwarmGriewank(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
 }
 
 func TestBenchPSwarmRastrigin(t *testing.T) {
@@ -135,7 +150,89 @@ func TestBenchPSwarmRastrigin(t *testing.T) {
 			MaxEval: maxeval,
 		}
 	}
-	bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+	benchmark(t, fn, sfn, successfrac, avgeval)
+}
+
+func TestBenchCMAESRosen(t *testing.T) {
+	ndim := 30
+	maxeval := 30000
+	avgeval := 5000.0
+	successfrac := 1.00
+
+	fn := bench.Rosenbrock{ndim}
+	sfn := func() *optim.Solver {
+		return &optim.Solver{
+			Method:  cmaessolver(fn, nil),
+			Obj:     optim.Func(fn.Eval),
+			MaxEval: maxeval,
+		}
+	}
+	benchmark(t, fn, sfn, successfrac, avgeval)
+}
+
+func TestBenchCMAESGriewank(t *testing.T) {
+	ndim := 30
+	maxeval := 30000
+	avgeval := 4000.0
+	successfrac := 1.00
+
+	fn := bench.Griewank{ndim}
+	sfn := func() *optim.Solver {
+		return &optim.Solver{
+			Method:  cmaessolver(fn, nil),
+			Obj:     optim.Func(fn.Eval),
+			MaxEval: maxeval,
+		}
+	}
+	benchmark(t, fn, sfn, successfrac, avgeval)
+}
+
+func TestBenchCMAESRastrigin(t *testing.T) {
+	ndim := 20
+	maxeval := 30000
+	avgeval := 9000.0
+	successfrac := 1.00
+
+	fn := bench.Rastrigin{ndim}
+	sfn := func() *optim.Solver {
+		return &optim.Solver{
+			Method:  cmaessolver(fn, nil),
+			Obj:     optim.Func(fn.Eval),
+			MaxEval: maxeval,
+		}
+	}
+	benchmark(t, fn, sfn, successfrac, avgeval)
+}
+
+func TestGradients(t *testing.T) {
+	fns := []bench.Differentiable{
+		bench.Ackley{},
+		bench.Rosenbrock{NDim: 5},
+		bench.Rastrigin{NDim: 5},
+		bench.Griewank{NDim: 5},
+		bench.Styblinski{NDim: 5},
+	}
+	for _, fn := range fns {
+		obj := bench.Objective(fn)
+		if _, ok := obj.(optim.Gradienter); !ok {
+			t.Errorf("[%v] objective does not implement optim.Gradienter", fn.Name())
+		}
+		for n := 0; n < 20; n++ {
+			// stay well inside the bounds so the finite difference points
+			// are too
+			p := initialpoint(fn)
+			for i := range p.Pos {
+				p.Pos[i] *= 0.9
+			}
+			maxerr, worst, err := optim.CheckGradient(obj, p.Pos)
+			if err != nil {
+				t.Fatalf("[%v] %v", fn.Name(), err)
+			} else if maxerr > 1e-5 {
+				t.Errorf("[%v] gradient error %v in dimension %v at %v", fn.Name(), maxerr, worst, p.Pos)
+				break
+			}
+		}
+	}
 }
 
 func TestOverviewPattern(t *testing.T) {
@@ -154,7 +251,47 @@ func TestOverviewPattern(t *testing.T) {
 				MaxEval: maxeval,
 			}
 		}
-		bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+		benchmark(t, fn, sfn, successfrac, avgeval)
+	}
+}
+
+func TestOverviewHookeJeeves(t *testing.T) {
+	maxeval := 20000
+	avgeval := 6000.0
+	successfrac := 0.75
+
+	for _, fn := range []bench.Func{bench.Rosenbrock{NDim: 2}} {
+		sfn := func() *optim.Solver {
+			low, up := fn.Bounds()
+			mesh := &optim.InfMesh{StepSize: (up[0] - low[0]) / 9}
+			return &optim.Solver{
+				Method:  hookejeeves.New(initialpoint(fn)),
+				Obj:     optim.Func(fn.Eval),
+				Mesh:    mesh,
+				MaxEval: maxeval,
+			}
+		}
+		benchmark(t, fn, sfn, successfrac, avgeval)
+	}
+}
+
+func TestOverviewCoord(t *testing.T) {
+	maxeval := 20000
+	avgeval := 3000.0
+	successfrac := 0.85
+
+	for _, fn := range []bench.Func{bench.Rosenbrock{NDim: 2}} {
+		sfn := func() *optim.Solver {
+			low, up := fn.Bounds()
+			mesh := &optim.InfMesh{StepSize: (up[0] - low[0]) / 9}
+			return &optim.Solver{
+				Method:  coord.New(initialpoint(fn)),
+				Obj:     optim.Func(fn.Eval),
+				Mesh:    mesh,
+				MaxEval: maxeval,
+			}
+		}
+		benchmark(t, fn, sfn, successfrac, avgeval)
 	}
 }
 
@@ -171,7 +308,7 @@ func TestOverviewSwarm(t *testing.T) {
 				MaxEval: maxeval,
 			}
 		}
-		bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+		benchmark(t, fn, sfn, successfrac, avgeval)
 	}
 }
 
@@ -190,7 +327,24 @@ func TestOverviewPSwarm(t *testing.T) {
 				MaxEval: maxeval,
 			}
 		}
-		bench.Benchmark(t, fn, sfn, successfrac, avgeval)
+		benchmark(t, fn, sfn, successfrac, avgeval)
+	}
+}
+
+func TestOverviewCMAES(t *testing.T) {
+	maxeval := 50000
+	avgeval := 10000.00
+	successfrac := .90
+
+	for _, fn := range bench.Basic {
+		sfn := func() *optim.Solver {
+			return &optim.Solver{
+				Method:  cmaessolver(fn, nil),
+				Obj:     optim.Func(fn.Eval),
+				MaxEval: maxeval,
+			}
+		}
+		benchmark(t, fn, sfn, successfrac, avgeval)
 	}
 }
 
@@ -233,12 +387,70 @@ func pswarmsolver(fn bench.Func, db *sql.DB, n int) (*pattern.Method, optim.Mesh
 	), mesh
 }
 
+func cmaessolver(fn bench.Func, db *sql.DB) optim.Method {
+	low, up := fn.Bounds()
+	max, min := up[0], low[0]
+	return cmaes.New(initialpoint(fn), 0.3*(max-min),
+		cmaes.Bounds(low, up),
+		cmaes.Restart(cmaes.BIPOP),
+		cmaes.DB(db),
+	)
+}
+
 func initialpoint(fn bench.Func) *optim.Point {
 	low, up := fn.Bounds()
 	max, min := up[0], low[0]
 	pos := make([]float64, len(low))
 	for i := range low {
-		pos[i] = rand.Float64()*(max-min) + min
+		pos[i] = starts.Float64()*(max-min) + min
+	}
+	return &optim.Point{Pos: pos, Val: math.Inf(1)}
+}
+
+func TestMultiFronts(t *testing.T) {
+	for _, fn := range bench.Multi {
+		front := fn.Front(100)
+		if len(front) == 0 || len(front) > 100 {
+			t.Errorf("%v: want 1 to 100 front points, got %v", fn.Name(), len(front))
+			continue
+		}
+
+		pts := make([]*optim.Point, len(front))
+		for i, v := range front {
+			if len(v) != fn.NObj() {
+				t.Fatalf("%v: want %v objectives, got %v", fn.Name(), fn.NObj(), len(v))
+			}
+			pts[i] = &optim.Point{Vals: v}
+		}
+		if n := len(optim.NondominatedSort(pts)[0]); n != len(pts) {
+			t.Errorf("%v: %v of %v front points are dominated", fn.Name(), len(pts)-n, len(pts))
+		}
+		if optim.Hypervolume(pts, fn.Ref()) <= 0 {
+			t.Errorf("%v: front doesn't dominate reference point %v", fn.Name(), fn.Ref())
+		}
+	}
+}
+
+func TestMultiOptimal(t *testing.T) {
+	tests := []struct {
+		Fn   bench.MultiFunc
+		X    []float64
+		Want []float64
+	}{
+		{bench.ZDT1{NDim: 3}, []float64{0.25, 0, 0}, []float64{0.25, 0.5}},
+		{bench.ZDT2{NDim: 3}, []float64{0.5, 0, 0}, []float64{0.5, 0.75}},
+		{bench.ZDT4{NDim: 3}, []float64{0.25, 0, 0}, []float64{0.25, 0.5}},
+		{bench.DTLZ1{NDim: 4, M: 3}, []float64{0.5, 0.5, 0.5, 0.5}, []float64{0.125, 0.125, 0.25}},
+		{bench.DTLZ2{NDim: 4, M: 3}, []float64{0, 1, 0.5, 0.5}, []float64{0, 1, 0}},
+	}
+
+	for _, test := range tests {
+		got := test.Fn.Objectives(test.X)
+		for i := range got {
+			if math.Abs(got[i]-test.Want[i]) > 1e-10 {
+				t.Errorf("%v(%v): want %v, got %v", test.Fn.Name(), test.X, test.Want, got)
+				break
+			}
+		}
 	}
-	return &optim.Point{pos, math.Inf(1)}
 }
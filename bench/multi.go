@@ -0,0 +1,349 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// MultiFunc is a multi-objective benchmark problem with a known Pareto front.
+type MultiFunc interface {
+	Objectives(v []float64) []float64
+	Bounds() (low, up []float64)
+	// NObj returns the number of objectives.
+	NObj() int
+	// Front returns about n objective vectors evenly spread over the true
+	// Pareto front (e.g. as the reference set for optim.IGD).
+	Front(n int) [][]float64
+	// Ref returns a reference point for optim.Hypervolume that is dominated
+	// by the whole Pareto front.
+	Ref() []float64
+	Name() string
+}
+
+// Multi contains the ZDT and DTLZ problems in their standard sizes.
+var Multi = []MultiFunc{
+	ZDT1{NDim: 30},
+	ZDT2{NDim: 30},
+	ZDT3{NDim: 30},
+	ZDT4{NDim: 10},
+	ZDT6{NDim: 10},
+	DTLZ1{NDim: 7, M: 3},
+	DTLZ2{NDim: 12, M: 3},
+}
+
+// MultiObjective returns an optim.MultiObjectiver for fn.
+func MultiObjective(fn MultiFunc) optim.MultiObjectiver {
+	return optim.MultiFunc(fn.Objectives)
+}
+
+// The ZDT problems from:
+//
+//     Zitzler, Eckart, Kalyanmoy Deb, and Lothar Thiele. "Comparison of
+//     multiobjective evolutionary algorithms: Empirical results."
+//     Evolutionary Computation 8.2 (2000): 173-195.
+//
+// all have two objectives f1 = x1 (except ZDT6) and f2 = g*h(f1, g) with the
+// Pareto front at g = 1.
+
+type ZDT1 struct{ NDim int }
+
+func (fn ZDT1) Name() string { return fmt.Sprintf("ZDT1_%vD", fn.NDim) }
+func (fn ZDT1) NObj() int    { return 2 }
+
+func (fn ZDT1) Ref() []float64 { return []float64{1.1, 1.1} }
+
+func (fn ZDT1) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn ZDT1) Objectives(x []float64) []float64 {
+	g := zdtg(x)
+	return []float64{x[0], g * (1 - math.Sqrt(x[0]/g))}
+}
+
+func (fn ZDT1) Front(n int) [][]float64 {
+	return twoObjFront(n, 0, 1, func(f1 float64) float64 { return 1 - math.Sqrt(f1) })
+}
+
+type ZDT2 struct{ NDim int }
+
+func (fn ZDT2) Name() string { return fmt.Sprintf("ZDT2_%vD", fn.NDim) }
+func (fn ZDT2) NObj() int    { return 2 }
+
+func (fn ZDT2) Ref() []float64 { return []float64{1.1, 1.1} }
+
+func (fn ZDT2) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn ZDT2) Objectives(x []float64) []float64 {
+	g := zdtg(x)
+	return []float64{x[0], g * (1 - (x[0]/g)*(x[0]/g))}
+}
+
+func (fn ZDT2) Front(n int) [][]float64 {
+	return twoObjFront(n, 0, 1, func(f1 float64) float64 { return 1 - f1*f1 })
+}
+
+// ZDT3 has a Pareto front made of five disconnected pieces.
+type ZDT3 struct{ NDim int }
+
+func (fn ZDT3) Name() string { return fmt.Sprintf("ZDT3_%vD", fn.NDim) }
+func (fn ZDT3) NObj() int    { return 2 }
+
+func (fn ZDT3) Ref() []float64 { return []float64{1.1, 1.1} }
+
+func (fn ZDT3) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn ZDT3) Objectives(x []float64) []float64 {
+	g := zdtg(x)
+	return []float64{x[0], g * (1 - math.Sqrt(x[0]/g) - x[0]/g*math.Sin(10*math.Pi*x[0]))}
+}
+
+func (fn ZDT3) Front(n int) [][]float64 {
+	// sample densely along g = 1 and keep the non-dominated pieces
+	curve := twoObjFront(20*n, 0, 1, func(f1 float64) float64 {
+		return 1 - math.Sqrt(f1) - f1*math.Sin(10*math.Pi*f1)
+	})
+	front := nondominated(curve)
+	return thin(front, n)
+}
+
+// ZDT4 has 21^9 local Pareto fronts.
+type ZDT4 struct{ NDim int }
+
+func (fn ZDT4) Name() string { return fmt.Sprintf("ZDT4_%vD", fn.NDim) }
+func (fn ZDT4) NObj() int    { return 2 }
+
+func (fn ZDT4) Ref() []float64 { return []float64{1.1, 1.1} }
+
+func (fn ZDT4) Bounds() (low, up []float64) {
+	low, up = make([]float64, fn.NDim), make([]float64, fn.NDim)
+	up[0] = 1
+	for i := 1; i < fn.NDim; i++ {
+		low[i], up[i] = -5, 5
+	}
+	return low, up
+}
+
+func (fn ZDT4) Objectives(x []float64) []float64 {
+	g := 1 + 10*float64(len(x)-1)
+	for _, v := range x[1:] {
+		g += v*v - 10*math.Cos(4*math.Pi*v)
+	}
+	return []float64{x[0], g * (1 - math.Sqrt(x[0]/g))}
+}
+
+func (fn ZDT4) Front(n int) [][]float64 { return ZDT1{}.Front(n) }
+
+// ZDT6 has a non-uniformly dense search space and Pareto front.
+type ZDT6 struct{ NDim int }
+
+func (fn ZDT6) Name() string { return fmt.Sprintf("ZDT6_%vD", fn.NDim) }
+func (fn ZDT6) NObj() int    { return 2 }
+
+func (fn ZDT6) Ref() []float64 { return []float64{1.1, 1.1} }
+
+func (fn ZDT6) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn ZDT6) Objectives(x []float64) []float64 {
+	f1 := 1 - math.Exp(-4*x[0])*math.Pow(math.Sin(6*math.Pi*x[0]), 6)
+	g := 0.0
+	for _, v := range x[1:] {
+		g += v
+	}
+	g = 1 + 9*math.Pow(g/float64(len(x)-1), 0.25)
+	return []float64{f1, g * (1 - (f1/g)*(f1/g))}
+}
+
+func (fn ZDT6) Front(n int) [][]float64 {
+	// the smallest f1 on the front is reached at the first minimum of
+	// 1 - exp(-4x)sin(6 pi x)^6
+	return twoObjFront(n, 0.2807753191, 1, func(f1 float64) float64 { return 1 - f1*f1 })
+}
+
+// The DTLZ problems from:
+//
+//     Deb, Kalyanmoy, et al. "Scalable test problems for evolutionary
+//     multiobjective optimization." Evolutionary Multiobjective Optimization.
+//     Springer London, 2005. 105-145.
+//
+// have a configurable number of objectives M.  The last NDim-M+1 variables
+// determine the distance from the Pareto front.
+
+// DTLZ1 has a linear Pareto front (the objectives sum to 0.5) and 11^k - 1
+// local fronts.
+type DTLZ1 struct{ NDim, M int }
+
+func (fn DTLZ1) Name() string { return fmt.Sprintf("DTLZ1_%vD_%vM", fn.NDim, fn.M) }
+func (fn DTLZ1) NObj() int    { return fn.M }
+
+func (fn DTLZ1) Ref() []float64 { return filled(fn.M, 1) }
+
+func (fn DTLZ1) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn DTLZ1) Objectives(x []float64) []float64 {
+	xm := x[fn.M-1:]
+	g := float64(len(xm))
+	for _, v := range xm {
+		g += (v-0.5)*(v-0.5) - math.Cos(20*math.Pi*(v-0.5))
+	}
+	g *= 100
+
+	f := filled(fn.M, 0.5*(1+g))
+	for i := range f {
+		for _, v := range x[:fn.M-1-i] {
+			f[i] *= v
+		}
+		if i > 0 {
+			f[i] *= 1 - x[fn.M-1-i]
+		}
+	}
+	return f
+}
+
+func (fn DTLZ1) Front(n int) [][]float64 {
+	front := simplexGrid(fn.M, n)
+	for _, f := range front {
+		for i := range f {
+			f[i] *= 0.5
+		}
+	}
+	return front
+}
+
+// DTLZ2 has a spherical Pareto front (the objectives have unit norm).
+type DTLZ2 struct{ NDim, M int }
+
+func (fn DTLZ2) Name() string { return fmt.Sprintf("DTLZ2_%vD_%vM", fn.NDim, fn.M) }
+func (fn DTLZ2) NObj() int    { return fn.M }
+
+func (fn DTLZ2) Ref() []float64 { return filled(fn.M, 1.1) }
+
+func (fn DTLZ2) Bounds() (low, up []float64) { return unitBounds(fn.NDim) }
+
+func (fn DTLZ2) Objectives(x []float64) []float64 {
+	g := 0.0
+	for _, v := range x[fn.M-1:] {
+		g += (v - 0.5) * (v - 0.5)
+	}
+
+	f := filled(fn.M, 1+g)
+	for i := range f {
+		for _, v := range x[:fn.M-1-i] {
+			f[i] *= math.Cos(v * math.Pi / 2)
+		}
+		if i > 0 {
+			f[i] *= math.Sin(x[fn.M-1-i] * math.Pi / 2)
+		}
+	}
+	return f
+}
+
+func (fn DTLZ2) Front(n int) [][]float64 {
+	front := simplexGrid(fn.M, n)
+	for _, f := range front {
+		norm := 0.0
+		for _, v := range f {
+			norm += v * v
+		}
+		for i := range f {
+			f[i] /= math.Sqrt(norm)
+		}
+	}
+	return front
+}
+
+func zdtg(x []float64) float64 {
+	g := 0.0
+	for _, v := range x[1:] {
+		g += v
+	}
+	return 1 + 9*g/float64(len(x)-1)
+}
+
+func unitBounds(ndim int) (low, up []float64) {
+	return make([]float64, ndim), filled(ndim, 1)
+}
+
+func filled(n int, v float64) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = v
+	}
+	return x
+}
+
+// twoObjFront returns n points (f1, f2(f1)) with f1 evenly spaced in
+// [lo, up].
+func twoObjFront(n int, lo, up float64, f2 func(float64) float64) [][]float64 {
+	front := make([][]float64, n)
+	for i := range front {
+		f1 := lo + (up-lo)*float64(i)/float64(n-1)
+		front[i] = []float64{f1, f2(f1)}
+	}
+	return front
+}
+
+// nondominated returns the objective vectors in vals not dominated by any
+// other.
+func nondominated(vals [][]float64) [][]float64 {
+	pts := make([]*optim.Point, len(vals))
+	for i, v := range vals {
+		pts[i] = &optim.Point{Vals: v}
+	}
+	front := [][]float64{}
+	for _, p := range optim.NondominatedSort(pts)[0] {
+		front = append(front, p.Vals)
+	}
+	return front
+}
+
+// thin returns about n evenly spaced elements of vals.
+func thin(vals [][]float64, n int) [][]float64 {
+	if len(vals) <= n {
+		return vals
+	}
+	thinned := make([][]float64, 0, n)
+	for i := 0; i < n; i++ {
+		thinned = append(thinned, vals[i*(len(vals)-1)/(n-1)])
+	}
+	return thinned
+}
+
+// simplexGrid returns the Das and Dennis lattice of m-dimensional points with
+// non-negative coordinates summing to one using the finest spacing 1/h that
+// gives at most n points.
+func simplexGrid(m, n int) [][]float64 {
+	h := 1
+	for count(m, h+1) <= n {
+		h++
+	}
+
+	grid := [][]float64{}
+	var fill func(prefix []int, left int)
+	fill = func(prefix []int, left int) {
+		if len(prefix) == m-1 {
+			pt := make([]float64, m)
+			for i, k := range prefix {
+				pt[i] = float64(k) / float64(h)
+			}
+			pt[m-1] = float64(left) / float64(h)
+			grid = append(grid, pt)
+			return
+		}
+		for k := 0; k <= left; k++ {
+			fill(append(prefix, k), left-k)
+		}
+	}
+	fill(make([]int, 0, m), h)
+	return grid
+}
+
+// count returns the number of points in the simplex lattice with spacing
+// 1/h in m dimensions - i.e. (h+m-1 choose m-1).
+func count(m, h int) int {
+	c := 1
+	for i := 1; i < m; i++ {
+		c = c * (h + i) / i
+	}
+	return c
+}
@@ -0,0 +1,870 @@
+// Package bobyqa provides a model-based derivative-free trust-region method
+// for bound constrained problems in the spirit of:
+//
+//     Powell, M. J. D. "The BOBYQA algorithm for bound constrained
+//     optimization without derivatives." Cambridge NA Report NA2009/06,
+//     University of Cambridge (2009).
+//
+// A quadratic model of the objective is built by interpolating it at a set
+// of points near the best point found so far.  Each iteration minimizes the
+// model within a trust region (intersected with the bounds) and evaluates the
+// objective at the resulting step.  The interpolation set is updated one
+// point at a time with the model's Hessian changing as little as possible
+// (in the Frobenius norm) - so 2n+1 points are enough to capture useful
+// curvature information while a full set of (n+1)(n+2)/2 points determines
+// the quadratic uniquely.  Typically only one objective evaluation is needed
+// per iteration which makes the method much more evaluation efficient than
+// pattern search on smooth problems.
+//
+// When the method's Evaler is an optim.CacheEvaler, previously evaluated
+// points lying in the trust region are considered as replacements before new
+// points are evaluated to repair the interpolation set's geometry.
+package bobyqa
+
+import (
+	"database/sql"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// TblIter is the name of the sql database table that contains the best
+// point, trust region radius and resolution for each iteration.
+const TblIter = "bobyqaiter"
+
+const (
+	// DefaultRhoBeg is the default initial trust region radius.  It is
+	// clipped to half the narrowest bound width.
+	DefaultRhoBeg = 0.5
+	// DefaultRhoEnd is the default final trust region resolution.
+	DefaultRhoEnd = 1e-6
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Bounds constrains the search to the box defined by low and up.  If no
+// bounds are given and the solver's mesh is an optim.BoxMesh, the mesh's
+// bounds are used.  A bench.Func's bounds can be passed directly as
+// Bounds(fn.Bounds()).
+func Bounds(low, up []float64) Option {
+	return func(m *Method) {
+		m.Low = append([]float64{}, low...)
+		m.Up = append([]float64{}, up...)
+	}
+}
+
+// NPoints sets the number of interpolation points.  It is clipped to the
+// range [n+2, (n+1)(n+2)/2] for an n dimensional problem.
+func NPoints(npt int) Option { return func(m *Method) { m.NPoints = npt } }
+
+// Full uses (n+1)(n+2)/2 interpolation points so that the quadratic model is
+// fully determined by interpolation.  This is most useful for small problems
+// since the initial set alone costs O(n^2) evaluations.
+func Full() Option { return func(m *Method) { m.NPoints = -1 } }
+
+// RhoBeg sets the initial trust region radius which is also the spacing of
+// the initial interpolation points.
+func RhoBeg(rho float64) Option { return func(m *Method) { m.RhoBeg = rho } }
+
+// RhoEnd sets the final trust region resolution.  The method is considered
+// converged once the resolution would drop below it.
+func RhoEnd(rho float64) Option { return func(m *Method) { m.RhoEnd = rho } }
+
+type Method struct {
+	// X is the best point found and the center of the trust region.  It is
+	// always one of Points.
+	X *optim.Point
+	// Points is the interpolation set.
+	Points  []*optim.Point
+	NPoints int
+	Low, Up []float64
+	// Delta is the trust region radius.
+	Delta float64
+	// Rho is the trust region resolution - a lower bound on Delta which is
+	// reduced from RhoBeg to RhoEnd as the method converges.
+	Rho    float64
+	RhoBeg float64
+	RhoEnd float64
+	// Converged is set once Rho reaches RhoEnd and no further progress can
+	// be made.  Subsequent iterations evaluate nothing and return
+	// optim.ErrConverged.
+	Converged bool
+	// Nreuse counts interpolation points taken from an optim.CacheEvaler
+	// instead of being evaluated.
+	Nreuse int
+	Db     *sql.DB
+	ev     optim.Evaler
+
+	// The quadratic model is q(X+d) = c + g.d + d.h.d/2 about base.
+	base []float64
+	c    float64
+	g    []float64
+	h    [][]float64
+	// winv is the inverse of the interpolation system matrix for the
+	// displacements disp of Points from base scaled by 1/scale.  Its rows
+	// hold the coefficients of the Lagrange functions of Points.
+	winv  [][]float64
+	disp  [][]float64
+	scale float64
+	ratio float64
+	iter  int
+}
+
+// New creates a trust-region method starting at start.  If start's value is
+// +infinity, it is evaluated on the first iteration along with the rest of
+// the initial interpolation set.
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		X:      start.Clone(),
+		RhoBeg: DefaultRhoBeg,
+		RhoEnd: DefaultRhoEnd,
+		ev:     optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	n := len(start.Pos)
+	full := (n + 1) * (n + 2) / 2
+	if m.NPoints == 0 {
+		m.NPoints = 2*n + 1
+	} else if m.NPoints < 0 || m.NPoints > full {
+		m.NPoints = full
+	}
+	if m.NPoints < n+2 {
+		m.NPoints = n + 2
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint adds p to the interpolation set if it is better than the current
+// best point.  Before the first iteration, p replaces the start point
+// instead.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val >= m.X.Val || math.IsInf(p.Val, 1) {
+		return
+	} else if m.Points == nil {
+		m.X = p.Clone()
+		return
+	}
+	m.insert(p.Clone())
+	m.Converged = false
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	if bm, ok := mesh.(*optim.BoxMesh); ok && m.Low == nil {
+		m.Low, m.Up = bm.Lower, bm.Upper
+	}
+
+	if m.Points == nil {
+		rho := m.RhoBeg
+		if m.Rho > 0 {
+			// restarting after the interpolation set degenerated
+			rho = m.Rho
+		}
+		n, err = m.initset(obj, mesh, rho)
+		return m.X, n, err
+	} else if m.Converged {
+		return m.X, 0, optim.ErrConverged
+	}
+
+	d := m.trstep()
+	pos := make([]float64, len(d))
+	for i := range pos {
+		pos[i] = m.X.Pos[i] + d[i]
+	}
+	pos = m.project(pos, mesh)
+	dnorm := dist(pos, m.X.Pos)
+	if dnorm < 0.5*m.Rho || m.contains(pos) {
+		// the model can't make progress at the current resolution
+		m.ratio = 0
+		n, err = m.improve(obj, mesh, true)
+		return m.X, n, err
+	}
+
+	p := &optim.Point{Pos: pos, Val: math.Inf(1)}
+	_, n, err = m.ev.Eval(obj, p)
+	if err != nil {
+		return m.X, n, err
+	}
+
+	m.ratio = -1
+	if pred := m.model(m.X.Pos) - m.model(pos); pred > 0 && !math.IsInf(p.Val, 0) && !math.IsNaN(p.Val) {
+		m.ratio = (m.X.Val - p.Val) / pred
+	}
+
+	switch {
+	case m.ratio <= 0.1:
+		m.Delta = math.Min(0.5*m.Delta, dnorm)
+	case m.ratio <= 0.7:
+		m.Delta = math.Max(0.5*m.Delta, dnorm)
+	default:
+		m.Delta = math.Max(0.5*m.Delta, 2*dnorm)
+	}
+	if m.Delta <= 1.5*m.Rho {
+		m.Delta = m.Rho
+	}
+
+	if !math.IsInf(p.Val, 0) && !math.IsNaN(p.Val) {
+		m.insert(p)
+	}
+	if m.Points != nil && m.ratio < 0.1 {
+		ni, err := m.improve(obj, mesh, false)
+		return m.X, n + ni, err
+	}
+	return m.X, n, nil
+}
+
+// initset evaluates an initial interpolation set with spacing rho around X
+// following Powell's scheme: X, X+rho*e_i, X-rho*e_i and then
+// X+rho*e_i+rho*e_j for pairs of dimensions.  Next to a bound X is moved onto
+// it or rho away from it so that all points are feasible.  Cached points
+// within rho/4 of a target point are used instead of evaluating it.
+func (m *Method) initset(obj optim.Objectiver, mesh optim.Mesh, rho float64) (n int, err error) {
+	ndim := len(m.X.Pos)
+	x0 := append([]float64{}, m.X.Pos...)
+	step1 := make([]float64, ndim)
+	step2 := make([]float64, ndim)
+	for i := range x0 {
+		if m.Low != nil {
+			rho = math.Min(rho, (m.Up[i]-m.Low[i])/2)
+		}
+	}
+	for i := range x0 {
+		step1[i], step2[i] = rho, -rho
+		if m.Low == nil {
+			continue
+		}
+		switch lo, up := x0[i]-m.Low[i], m.Up[i]-x0[i]; {
+		case lo <= rho/2:
+			x0[i], step2[i] = m.Low[i], 2*rho
+		case lo < rho:
+			x0[i] = m.Low[i] + rho
+		case up <= rho/2:
+			x0[i], step1[i], step2[i] = m.Up[i], -rho, -2*rho
+		case up < rho:
+			x0[i] = m.Up[i] - rho
+		}
+	}
+
+	offsets := [][]float64{make([]float64, ndim)}
+	for i := 0; i < ndim && len(offsets) < m.NPoints; i++ {
+		d := make([]float64, ndim)
+		d[i] = step1[i]
+		offsets = append(offsets, d)
+	}
+	for i := 0; i < ndim && len(offsets) < m.NPoints; i++ {
+		d := make([]float64, ndim)
+		d[i] = step2[i]
+		offsets = append(offsets, d)
+	}
+	for i := 0; i < ndim && len(offsets) < m.NPoints; i++ {
+		for j := i + 1; j < ndim && len(offsets) < m.NPoints; j++ {
+			d := make([]float64, ndim)
+			d[i], d[j] = step1[i], step1[j]
+			offsets = append(offsets, d)
+		}
+	}
+
+	pts := make([]*optim.Point, len(offsets))
+	eval := []*optim.Point{}
+	for k, d := range offsets {
+		pos := make([]float64, ndim)
+		for i := range pos {
+			pos[i] = x0[i] + d[i]
+		}
+		pos = m.project(pos, mesh)
+		if k == 0 && !math.IsInf(m.X.Val, 1) && dist(pos, m.X.Pos) == 0 {
+			pts[k] = m.X
+			continue
+		} else if p := m.nearest(pos, rho/4, mesh); p != nil {
+			// targets are at least rho apart so no cached point is used
+			// twice
+			pts[k] = p
+			m.Nreuse++
+			continue
+		}
+		pts[k] = &optim.Point{Pos: pos, Val: math.Inf(1)}
+		eval = append(eval, pts[k])
+	}
+
+	_, n, err = m.ev.Eval(obj, eval...)
+	if err != nil {
+		return n, err
+	}
+
+	m.Points = pts
+	m.X = pts[0]
+	for _, p := range pts {
+		if p.Val < m.X.Val {
+			m.X = p
+		}
+	}
+	m.Rho, m.Delta = rho, rho
+	m.g, m.h = nil, nil
+	if !m.fit() {
+		// the mesh is too coarse (or the bounds too narrow) to resolve
+		// distinct interpolation points
+		m.Converged = true
+	}
+	return n, nil
+}
+
+// insert adds p to the interpolation set in place of the point whose removal
+// best preserves the set's geometry - i.e. the point with the largest
+// Lagrange function value at p, weighted toward points far from the best
+// point.
+func (m *Method) insert(p *optim.Point) {
+	better := p.Val < m.X.Val
+	center := m.X.Pos
+	if better {
+		center = p.Pos
+	}
+
+	j, wbest := -1, -1.0
+	for k, y := range m.Points {
+		if y == m.X && !better {
+			continue
+		}
+		r := dist(y.Pos, center) / m.Delta
+		w := math.Abs(m.lagrange(k, p.Pos)) * math.Max(1, r*r)
+		if w > wbest {
+			j, wbest = k, w
+		}
+	}
+
+	m.Points[j] = p
+	if better {
+		m.X = p
+	}
+	if !m.fit() {
+		// rebuild the interpolation set around X on the next iteration
+		m.Points = nil
+	}
+}
+
+// improve either repairs the interpolation set's geometry by replacing its
+// point farthest from X or, if all points are close enough, reduces the
+// resolution Rho.  Rho is only reduced if the last trust region step was too
+// short or the trust region is already as small as Rho allows.
+func (m *Method) improve(obj optim.Objectiver, mesh optim.Mesh, short bool) (n int, err error) {
+	if m.Points == nil {
+		return 0, nil
+	}
+
+	far, fardist := -1, 0.0
+	for k, y := range m.Points {
+		if d := dist(y.Pos, m.X.Pos); d > fardist {
+			far, fardist = k, d
+		}
+	}
+
+	if fardist > math.Max(2*m.Delta, 10*m.Rho) {
+		replaced, n, err := m.geometry(obj, mesh, far)
+		if err != nil || replaced {
+			return n, err
+		}
+	}
+	if short || m.Delta <= m.Rho {
+		m.reduceRho()
+	}
+	return n, nil
+}
+
+// geometry replaces interpolation point j with a point in the trust region
+// where j's Lagrange function is large in magnitude.  Candidates are steps
+// of length Delta along the coordinate axes, the Lagrange function's
+// gradient and the direction to point j along with any cached points in the
+// trust region.  A cached point is preferred if it is at least half as good
+// as the best new candidate.
+func (m *Method) geometry(obj optim.Objectiver, mesh optim.Mesh, j int) (replaced bool, n int, err error) {
+	ndim := len(m.X.Pos)
+	dirs := [][]float64{}
+	for i := 0; i < ndim; i++ {
+		d := make([]float64, ndim)
+		d[i] = 1
+		dirs = append(dirs, d)
+	}
+	grad := m.winv[j][len(m.Points)+1:]
+	toj := make([]float64, ndim)
+	for i := range toj {
+		toj[i] = m.Points[j].Pos[i] - m.X.Pos[i]
+	}
+	for _, d := range [][]float64{grad, toj} {
+		if norm := math.Sqrt(dot(d, d)); norm > 0 {
+			u := make([]float64, ndim)
+			for i := range u {
+				u[i] = d[i] / norm
+			}
+			dirs = append(dirs, u)
+		}
+	}
+
+	var best []float64
+	lbest := 0.0
+	for _, d := range dirs {
+		for _, sign := range []float64{1, -1} {
+			pos := make([]float64, ndim)
+			for i := range pos {
+				pos[i] = m.X.Pos[i] + sign*m.Delta*d[i]
+			}
+			pos = m.project(pos, mesh)
+			if m.contains(pos) {
+				continue
+			}
+			if l := math.Abs(m.lagrange(j, pos)); l > lbest {
+				best, lbest = pos, l
+			}
+		}
+	}
+
+	var cached *optim.Point
+	lcached := 0.0
+	if ce, ok := m.ev.(*optim.CacheEvaler); ok {
+		for _, p := range ce.Points() {
+			if math.IsInf(p.Val, 0) || math.IsNaN(p.Val) || dist(p.Pos, m.X.Pos) > m.Delta || m.contains(p.Pos) {
+				continue
+			}
+			if l := math.Abs(m.lagrange(j, p.Pos)); l > lcached {
+				cached, lcached = p, l
+			}
+		}
+	}
+
+	var p *optim.Point
+	if cached != nil && lcached >= 0.5*lbest {
+		p = cached
+		m.Nreuse++
+	} else if best != nil {
+		p = &optim.Point{Pos: best, Val: math.Inf(1)}
+		_, n, err = m.ev.Eval(obj, p)
+		if err != nil {
+			return false, n, err
+		}
+	} else {
+		return false, 0, nil
+	}
+
+	m.Points[j] = p
+	if p.Val < m.X.Val {
+		m.X = p
+	}
+	if !m.fit() {
+		m.Points = nil
+	}
+	return true, n, nil
+}
+
+// nearest returns the cached point closest to pos that is at most radius
+// away, feasible and on the mesh.  It returns nil if there is no such point
+// or the method's Evaler is not an optim.CacheEvaler.
+func (m *Method) nearest(pos []float64, radius float64, mesh optim.Mesh) *optim.Point {
+	ce, ok := m.ev.(*optim.CacheEvaler)
+	if !ok {
+		return nil
+	}
+
+	var near *optim.Point
+	neardist := 0.0
+	for _, p := range ce.Points() {
+		d := dist(p.Pos, pos)
+		if d > radius || math.IsInf(p.Val, 0) || math.IsNaN(p.Val) || dist(m.project(p.Pos, mesh), p.Pos) > 0 {
+			continue
+		}
+		// break ties by value so the choice doesn't depend on map order
+		if near == nil || d < neardist || d == neardist && p.Val < near.Val {
+			near, neardist = p, d
+		}
+	}
+	return near
+}
+
+func (m *Method) reduceRho() {
+	if m.Rho <= m.RhoEnd {
+		m.Converged = true
+		return
+	}
+
+	old := m.Rho
+	switch {
+	case m.Rho > 250*m.RhoEnd:
+		m.Rho /= 10
+	case m.Rho > 16*m.RhoEnd:
+		m.Rho = math.Sqrt(m.Rho * m.RhoEnd)
+	default:
+		m.Rho = m.RhoEnd
+	}
+	m.Delta = math.Max(old/2, m.Rho)
+}
+
+// fit updates the quadratic model to interpolate the current points by
+// adding the correction with the least Frobenius norm Hessian.  It returns
+// false if the interpolation points are degenerate.
+func (m *Method) fit() bool {
+	npt := len(m.Points)
+	ndim := len(m.X.Pos)
+
+	// rebase the previous model at X
+	c, g, h := 0.0, make([]float64, ndim), make([][]float64, ndim)
+	for i := range h {
+		h[i] = make([]float64, ndim)
+	}
+	if m.g != nil {
+		c = m.model(m.X.Pos)
+		d := sub(m.X.Pos, m.base)
+		for i := range g {
+			g[i] = m.g[i]
+			for k := range d {
+				g[i] += m.h[i][k] * d[k]
+			}
+			copy(h[i], m.h[i])
+		}
+	}
+
+	// values that can't be interpolated are treated as worse than all
+	// others
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range m.Points {
+		if !math.IsInf(p.Val, 0) && !math.IsNaN(p.Val) {
+			lo, hi = math.Min(lo, p.Val), math.Max(hi, p.Val)
+		}
+	}
+
+	scale := m.Delta
+	disp := make([][]float64, npt)
+	resid := make([]float64, npt+ndim+1)
+	for k, p := range m.Points {
+		d := sub(p.Pos, m.X.Pos)
+		f := p.Val
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			f = hi + (hi - lo) + 1
+		}
+		resid[k] = f - c - dot(g, d) - 0.5*quad(h, d)
+		disp[k] = make([]float64, ndim)
+		for i := range d {
+			disp[k][i] = d[i] / scale
+		}
+	}
+
+	// the interpolation system is [A P^T; P 0] with A[i][j] =
+	// (disp_i.disp_j)^2/2 and P's columns [1 disp_i]
+	nw := npt + ndim + 1
+	w := make([][]float64, nw)
+	for i := range w {
+		w[i] = make([]float64, nw)
+	}
+	for i := 0; i < npt; i++ {
+		for j := 0; j <= i; j++ {
+			v := dot(disp[i], disp[j])
+			w[i][j], w[j][i] = 0.5*v*v, 0.5*v*v
+		}
+		w[i][npt], w[npt][i] = 1, 1
+		for k := 0; k < ndim; k++ {
+			w[i][npt+1+k], w[npt+1+k][i] = disp[i][k], disp[i][k]
+		}
+	}
+	winv, ok := invert(w)
+	if !ok {
+		return false
+	}
+
+	coef := make([]float64, nw)
+	for i := range coef {
+		coef[i] = dot(winv[i], resid)
+	}
+	c += coef[npt]
+	for i := 0; i < ndim; i++ {
+		g[i] += coef[npt+1+i] / scale
+		for j := 0; j < ndim; j++ {
+			for k := 0; k < npt; k++ {
+				h[i][j] += coef[k] * disp[k][i] * disp[k][j] / (scale * scale)
+			}
+		}
+	}
+
+	m.base = append([]float64{}, m.X.Pos...)
+	m.c, m.g, m.h = c, g, h
+	m.winv, m.disp, m.scale = winv, disp, scale
+	return true
+}
+
+// model returns the quadratic model's value at x.
+func (m *Method) model(x []float64) float64 {
+	d := sub(x, m.base)
+	return m.c + dot(m.g, d) + 0.5*quad(m.h, d)
+}
+
+// lagrange returns the value at x of the Lagrange function of interpolation
+// point j - the minimum Frobenius norm quadratic that is one at point j and
+// zero at all other points.
+func (m *Method) lagrange(j int, x []float64) float64 {
+	npt := len(m.Points)
+	u := sub(x, m.base)
+	for i := range u {
+		u[i] /= m.scale
+	}
+	l := m.winv[j][npt]
+	for k := 0; k < npt; k++ {
+		v := dot(m.disp[k], u)
+		l += 0.5 * m.winv[j][k] * v * v
+	}
+	for i := range u {
+		l += m.winv[j][npt+1+i] * u[i]
+	}
+	return l
+}
+
+// trstep approximately minimizes the model within the intersection of the
+// trust region and the bounds using truncated conjugate gradients.  When a
+// step reaches a bound, that variable is fixed and the conjugate gradient
+// iteration restarts.
+func (m *Method) trstep() []float64 {
+	ndim := len(m.X.Pos)
+	s := make([]float64, ndim)
+	fixed := make([]bool, ndim)
+
+	gradient := func() []float64 {
+		gs := make([]float64, ndim)
+		for i := range gs {
+			gs[i] = m.g[i]
+			for k := range s {
+				gs[i] += m.h[i][k] * s[k]
+			}
+		}
+		return gs
+	}
+
+	for restart := 0; restart <= ndim; restart++ {
+		gs := gradient()
+		r := make([]float64, ndim)
+		for i := range gs {
+			x := m.X.Pos[i] + s[i]
+			if m.Low != nil && ((x <= m.Low[i] && gs[i] > 0) || (x >= m.Up[i] && gs[i] < 0)) {
+				fixed[i] = true
+			}
+			if !fixed[i] {
+				r[i] = -gs[i]
+			}
+		}
+		d := append([]float64{}, r...)
+
+		hitbound := false
+		for k := 0; k < ndim; k++ {
+			rr := dot(r, r)
+			if rr <= 1e-20*dot(m.g, m.g) || rr == 0 {
+				return s
+			}
+
+			hd := make([]float64, ndim)
+			for i := range hd {
+				for j := range d {
+					hd[i] += m.h[i][j] * d[j]
+				}
+			}
+			dhd := dot(d, hd)
+
+			// step to the trust region boundary: |s + alpha*d| = Delta
+			dd, sd, ss := dot(d, d), dot(s, d), dot(s, s)
+			alpha := (-sd + math.Sqrt(math.Max(0, sd*sd+dd*(m.Delta*m.Delta-ss)))) / dd
+			boundary := true
+			if dhd > 0 && rr/dhd < alpha {
+				alpha, boundary = rr/dhd, false
+			}
+
+			ibound := -1
+			if m.Low != nil {
+				for i := range d {
+					x := m.X.Pos[i] + s[i]
+					if d[i] > 0 && (m.Up[i]-x)/d[i] < alpha {
+						alpha, ibound = (m.Up[i]-x)/d[i], i
+					} else if d[i] < 0 && (m.Low[i]-x)/d[i] < alpha {
+						alpha, ibound = (m.Low[i]-x)/d[i], i
+					}
+				}
+			}
+
+			for i := range s {
+				s[i] += alpha * d[i]
+			}
+			if ibound >= 0 {
+				fixed[ibound] = true
+				hitbound = true
+				break
+			} else if boundary {
+				return s
+			}
+
+			rnew := make([]float64, ndim)
+			for i := range rnew {
+				if !fixed[i] {
+					rnew[i] = r[i] - alpha*hd[i]
+				}
+			}
+			beta := dot(rnew, rnew) / rr
+			for i := range d {
+				d[i] = rnew[i] + beta*d[i]
+			}
+			r = rnew
+		}
+		if !hitbound {
+			return s
+		}
+	}
+	return s
+}
+
+// project clips pos to the bounds and then onto the mesh.
+func (m *Method) project(pos []float64, mesh optim.Mesh) []float64 {
+	pos = append([]float64{}, pos...)
+	for i := range pos {
+		if m.Low != nil {
+			pos[i] = math.Min(m.Up[i], math.Max(m.Low[i], pos[i]))
+		}
+	}
+	return mesh.Nearest(pos)
+}
+
+// contains returns true if pos is already in the interpolation set.
+func (m *Method) contains(pos []float64) bool {
+	for _, p := range m.Points {
+		if dist(p.Pos, pos) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// invert returns the inverse of a using Gauss-Jordan elimination with
+// partial pivoting.  ok is false if a is (numerically) singular.
+func invert(a [][]float64) (inv [][]float64, ok bool) {
+	n := len(a)
+	aug := make([][]float64, n)
+	maxabs := 0.0
+	for i := range a {
+		aug[i] = make([]float64, 2*n)
+		copy(aug[i], a[i])
+		aug[i][n+i] = 1
+		for _, v := range a[i] {
+			maxabs = math.Max(maxabs, math.Abs(v))
+		}
+	}
+
+	for col := 0; col < n; col++ {
+		piv := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(aug[i][col]) > math.Abs(aug[piv][col]) {
+				piv = i
+			}
+		}
+		if math.Abs(aug[piv][col]) <= 1e-13*maxabs {
+			return nil, false
+		}
+		aug[col], aug[piv] = aug[piv], aug[col]
+
+		p := aug[col][col]
+		for j := range aug[col] {
+			aug[col][j] /= p
+		}
+		for i := 0; i < n; i++ {
+			if i == col || aug[i][col] == 0 {
+				continue
+			}
+			f := aug[i][col]
+			for j := range aug[i] {
+				aug[i][j] -= f * aug[col][j]
+			}
+		}
+	}
+
+	inv = make([][]float64, n)
+	for i := range inv {
+		inv[i] = aug[i][n:]
+	}
+	return inv, true
+}
+
+func dot(a, b []float64) float64 {
+	tot := 0.0
+	for i := range a {
+		tot += a[i] * b[i]
+	}
+	return tot
+}
+
+// quad returns d.h.d.
+func quad(h [][]float64, d []float64) float64 {
+	tot := 0.0
+	for i := range d {
+		for j := range d {
+			tot += d[i] * h[i][j] * d[j]
+		}
+	}
+	return tot
+}
+
+func sub(a, b []float64) []float64 {
+	d := make([]float64, len(a))
+	for i := range d {
+		d[i] = a[i] - b[i]
+	}
+	return d
+}
+
+func dist(a, b []float64) float64 {
+	d := sub(a, b)
+	return math.Sqrt(dot(d, d))
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblIter + " (iter INTEGER, val REAL, delta REAL, rho REAL, ratio REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil || m.Points == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblIter + " (iter,val,delta,rho,ratio,posid) VALUES (?,?,?,?,?,?);"
+	_, err = tx.Exec(s, m.iter, m.X.Val, m.Delta, m.Rho, m.ratio, m.X.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.X)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("bobyqa: db write failed -", err)
+		return true
+	}
+	return false
+}
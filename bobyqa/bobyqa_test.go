@@ -0,0 +1,212 @@
+package bobyqa
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 10}
+	start := &optim.Point{Pos: make([]float64, 10), Val: math.Inf(1)}
+	m := New(start, RhoEnd(1e-8))
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 5000,
+	}
+	if err := solv.Run(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best().Val)
+	if solv.Best().Val > 1e-8 {
+		t.Errorf("want < 1e-8, got %v", solv.Best().Val)
+	}
+}
+
+func TestFull(t *testing.T) {
+	// a quadratic is modeled exactly by a full interpolation set
+	obj := optim.Func(func(x []float64) float64 {
+		a, b, c := x[0]-1, x[1]+2, x[2]-0.5
+		return a*a + 10*b*b + 100*c*c + a*b
+	})
+	nevals := map[bool]int{}
+	for _, full := range []bool{false, true} {
+		start := &optim.Point{Pos: make([]float64, 3), Val: math.Inf(1)}
+		opts := []Option{}
+		if full {
+			opts = append(opts, Full())
+		}
+		m := New(start, opts...)
+		solv := &optim.Solver{Method: m, Obj: obj, Mesh: &optim.InfMesh{}, MaxEval: 1000}
+		for solv.Next() && solv.Best().Val > 1e-10 {
+		}
+
+		want := 7
+		if full {
+			want = 10
+		}
+		if len(m.Points) != want {
+			t.Errorf("full=%v: want %v interpolation points, got %v", full, want, len(m.Points))
+		}
+		t.Logf("[INFO] full=%v: %v evals: got %v", full, solv.Neval(), solv.Best())
+		if solv.Best().Val > 1e-10 {
+			t.Errorf("full=%v: want < 1e-10, got %v", full, solv.Best().Val)
+		}
+		nevals[full] = solv.Neval()
+	}
+	if nevals[true] > 30 {
+		t.Errorf("full model: want <= 30 evals, got %v", nevals[true])
+	}
+}
+
+func TestBounds(t *testing.T) {
+	// unconstrained optimum at (3, -3, 0.5)
+	low, up := []float64{-1, -1, -1}, []float64{1, 1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		a, b, c := x[0]-3, x[1]+3, x[2]-0.5
+		return a*a + b*b + c*c + a*b/2
+	})
+
+	for _, mesh := range []optim.Mesh{&optim.InfMesh{}, &optim.BoxMesh{Mesh: &optim.InfMesh{}, Lower: low, Upper: up}} {
+		opts := []Option{}
+		if _, ok := mesh.(*optim.BoxMesh); !ok {
+			opts = append(opts, Bounds(low, up))
+		}
+		start := &optim.Point{Pos: []float64{0.9, 0, -0.95}, Val: math.Inf(1)}
+		m := New(start, opts...)
+		solv := &optim.Solver{Method: m, Obj: obj, Mesh: mesh, MaxEval: 500}
+		if err := solv.Run(); err != nil {
+			t.Errorf("%T: want no error, got %v", mesh, err)
+		}
+
+		t.Logf("[INFO] %T: %v evals: got %v", mesh, solv.Neval(), solv.Best())
+		if outside > 0 {
+			t.Errorf("%T: %v points evaluated outside bounds", mesh, outside)
+		}
+		want := []float64{1, -1, 0.5}
+		for i := range want {
+			if math.Abs(solv.Best().Pos[i]-want[i]) > 1e-5 {
+				t.Errorf("%T: want %v, got %v", mesh, want, solv.Best().Pos)
+				break
+			}
+		}
+	}
+}
+
+func TestBenchBounds(t *testing.T) {
+	fn := bench.Styblinski{NDim: 4}
+	start := &optim.Point{Pos: []float64{-1, -1, -1, -1}, Val: math.Inf(1)}
+	m := New(start, Bounds(fn.Bounds()))
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 1000,
+	}
+	if err := solv.Run(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if want := fn.Optima()[0].Val + 1e-4; solv.Best().Val > want {
+		t.Errorf("want < %v, got %v", want, solv.Best().Val)
+	}
+}
+
+func TestCache(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 4}
+	obj := optim.Func(fn.Eval)
+
+	// fill the cache with a previous run's evaluations
+	ev := optim.NewCacheEvaler(optim.SerialEvaler{})
+	start := &optim.Point{Pos: make([]float64, 4), Val: math.Inf(1)}
+	prev := New(start, Evaler(ev), RhoBeg(0.3))
+	solv := &optim.Solver{Method: prev, Obj: obj, Mesh: &optim.InfMesh{}, MaxEval: 2000}
+	solv.Run()
+
+	m := New(start, Evaler(ev))
+	solv = &optim.Solver{Method: m, Obj: obj, Mesh: &optim.InfMesh{}, MaxEval: 2000}
+	if err := solv.Run(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	t.Logf("[INFO] %v evals, %v reused: got %v", solv.Neval(), m.Nreuse, solv.Best().Val)
+	if m.Nreuse == 0 {
+		t.Errorf("no cached points were reused")
+	}
+	if solv.Best().Val > 1e-8 {
+		t.Errorf("want < 1e-8, got %v", solv.Best().Val)
+	}
+}
+
+func TestCacheInitSet(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 3}
+	obj := optim.Func(fn.Eval)
+	ev := optim.NewCacheEvaler(optim.SerialEvaler{})
+
+	// cache points slightly off the initial interpolation points
+	start := &optim.Point{Pos: []float64{0, 0, 0}, Val: math.Inf(1)}
+	rho := 0.5
+	near := []*optim.Point{{Pos: []float64{0.01, 0, 0}, Val: math.Inf(1)}}
+	for i := range start.Pos {
+		for _, step := range []float64{rho, -rho} {
+			pos := []float64{0.01, -0.02, 0.01}
+			pos[i] += step
+			near = append(near, &optim.Point{Pos: pos, Val: math.Inf(1)})
+		}
+	}
+	if _, _, err := ev.Eval(obj, near...); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(start, Evaler(ev), RhoBeg(rho))
+	_, n, err := m.Iterate(obj, &optim.InfMesh{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want 0 evals, got %v", n)
+	}
+	if m.Nreuse != len(near) {
+		t.Errorf("want %v points reused, got %v", len(near), m.Nreuse)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblIter).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblIter, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblIter, count, solv.Niter())
+	}
+}
@@ -0,0 +1,311 @@
+// Package cem provides a cross-entropy method - an estimation of
+// distribution algorithm that repeatedly samples a population from a
+// parametric distribution and refits the distribution to the best (elite)
+// fraction of the samples as described in:
+//
+//     De Boer, Pieter-Tjerk, et al. "A tutorial on the cross-entropy
+//     method." Annals of Operations Research 134.1 (2005): 19-67.
+//
+// Continuous dimensions are sampled from independent normal distributions
+// and integer dimensions (see the Integer option) from categorical
+// distributions over the integers within the bounds.  Distribution updates
+// are smoothed - new parameters are a weighted average of the elite estimate
+// and the previous parameters - which prevents categories from being lost
+// after a single unlucky generation and slows the collapse of the normal
+// distributions.  Each population is evaluated as a single batch through the
+// method's optim.Evaler.
+package cem
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblDist is the name of the sql database table that contains the mean
+	// and standard deviation of each continuous dimension for each
+	// iteration.
+	TblDist = "cemdist"
+	// TblProbs is the name of the sql database table that contains the
+	// probability of each value of each integer dimension for each
+	// iteration.
+	TblProbs = "cemprobs"
+	// TblBest is the name of the sql database table that contains the best
+	// point found after each iteration.
+	TblBest = "cembest"
+)
+
+const (
+	DefaultPopSize   = 100
+	DefaultEliteFrac = 0.1
+	DefaultSmoothing = 0.7
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// PopSize sets the number of points sampled each iteration.
+func PopSize(n int) Option { return func(m *Method) { m.PopSize = n } }
+
+// EliteFrac sets the fraction of each population used to refit the
+// distribution.
+func EliteFrac(frac float64) Option { return func(m *Method) { m.EliteFrac = frac } }
+
+// Smoothing sets the weight (between 0 and 1) of the elite estimate in each
+// distribution update - one means no smoothing.
+func Smoothing(alpha float64) Option { return func(m *Method) { m.Smoothing = alpha } }
+
+// Integer makes the given dimensions integer valued with a categorical
+// distribution over the integers within the bounds.  It is initially
+// uniform.
+func Integer(dims ...int) Option {
+	return func(m *Method) { m.intdims = append(m.intdims, dims...) }
+}
+
+// Normal sets the initial mean and standard deviation of the continuous
+// dimensions.  By default the mean is the center of the bounds and the
+// standard deviation is half their width.
+func Normal(mean, std []float64) Option {
+	return func(m *Method) {
+		m.Mean = append([]float64{}, mean...)
+		m.Std = append([]float64{}, std...)
+	}
+}
+
+type Method struct {
+	// Mean and Std are the parameters of the normal distribution of each
+	// continuous dimension.  Their entries for integer dimensions are
+	// unused.
+	Mean, Std []float64
+	// Probs holds the categorical distribution of each integer dimension -
+	// Probs[i][k] is the probability of value Low[i]+k.  Probs[i] is nil for
+	// continuous dimensions.
+	Probs     [][]float64
+	Low, Up   []float64
+	PopSize   int
+	EliteFrac float64
+	Smoothing float64
+	Db        *sql.DB
+
+	intdims []int
+	ev      optim.Evaler
+	best    *optim.Point
+	pop     []*optim.Point
+	iter    int
+}
+
+// New creates a cross-entropy method searching within the bounds low and up.
+// Sampled continuous values are clipped to the bounds.
+func New(low, up []float64, opts ...Option) *Method {
+	m := &Method{
+		Low:       append([]float64{}, low...),
+		Up:        append([]float64{}, up...),
+		PopSize:   DefaultPopSize,
+		EliteFrac: DefaultEliteFrac,
+		Smoothing: DefaultSmoothing,
+		ev:        optim.SerialEvaler{},
+		best:      &optim.Point{Val: math.Inf(1)},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.Mean == nil {
+		m.Mean = make([]float64, len(low))
+		m.Std = make([]float64, len(low))
+		for i := range low {
+			m.Mean[i] = (low[i] + up[i]) / 2
+			m.Std[i] = (up[i] - low[i]) / 2
+		}
+	}
+
+	m.Probs = make([][]float64, len(low))
+	for _, i := range m.intdims {
+		ncat := int(math.Floor(up[i])-math.Ceil(low[i])) + 1
+		m.Low[i], m.Up[i] = math.Ceil(low[i]), math.Floor(up[i])
+		m.Probs[i] = make([]float64, ncat)
+		for k := range m.Probs[i] {
+			m.Probs[i][k] = 1 / float64(ncat)
+		}
+	}
+
+	m.initdb()
+	return m
+}
+
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	m.pop = make([]*optim.Point, m.PopSize)
+	for k := range m.pop {
+		pos := m.sample()
+		if mesh != nil {
+			pos = mesh.Nearest(pos)
+		}
+		m.pop[k] = &optim.Point{Pos: pos, Val: math.Inf(1)}
+	}
+
+	_, n, err = m.ev.Eval(obj, m.pop...)
+	if err != nil {
+		return m.best, n, err
+	}
+
+	sort.Sort(byval(m.pop))
+	if m.pop[0].Val < m.best.Val {
+		m.best = m.pop[0]
+	}
+
+	nelite := int(math.Ceil(m.EliteFrac * float64(m.PopSize)))
+	if nelite < 2 {
+		nelite = 2
+	} else if nelite > len(m.pop) {
+		nelite = len(m.pop)
+	}
+	m.refit(m.pop[:nelite])
+
+	m.updateDb()
+	return m.best, n, nil
+}
+
+// sample draws a position from the current distribution.
+func (m *Method) sample() []float64 {
+	pos := make([]float64, len(m.Mean))
+	for i := range pos {
+		if m.Probs[i] != nil {
+			u := optim.RandFloat()
+			k := 0
+			for cum := m.Probs[i][0]; u > cum && k < len(m.Probs[i])-1; cum += m.Probs[i][k] {
+				k++
+			}
+			pos[i] = m.Low[i] + float64(k)
+			continue
+		}
+		pos[i] = m.Mean[i] + m.Std[i]*optim.RandNorm()
+		pos[i] = math.Min(m.Up[i], math.Max(m.Low[i], pos[i]))
+	}
+	return pos
+}
+
+// refit moves the distribution parameters toward their maximum likelihood
+// estimates for the elite points.
+func (m *Method) refit(elite []*optim.Point) {
+	a := m.Smoothing
+	nelite := float64(len(elite))
+	for i := range m.Mean {
+		if m.Probs[i] != nil {
+			freq := make([]float64, len(m.Probs[i]))
+			for _, p := range elite {
+				k := int(math.Floor(p.Pos[i] - m.Low[i] + 0.5))
+				if k >= 0 && k < len(freq) {
+					freq[k] += 1 / nelite
+				}
+			}
+			for k := range freq {
+				m.Probs[i][k] = a*freq[k] + (1-a)*m.Probs[i][k]
+			}
+			continue
+		}
+
+		mean, variance := 0.0, 0.0
+		for _, p := range elite {
+			mean += p.Pos[i] / nelite
+		}
+		for _, p := range elite {
+			variance += (p.Pos[i] - mean) * (p.Pos[i] - mean) / nelite
+		}
+		m.Mean[i] = a*mean + (1-a)*m.Mean[i]
+		m.Std[i] = a*math.Sqrt(variance) + (1-a)*m.Std[i]
+	}
+}
+
+type byval []*optim.Point
+
+func (b byval) Len() int           { return len(b) }
+func (b byval) Less(i, j int) bool { return b[i].Val < b[j].Val }
+func (b byval) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblDist + " (iter INTEGER,dim INTEGER,mean REAL,std REAL);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblProbs + " (iter INTEGER,dim INTEGER,value REAL,prob REAL);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblBest + " (iter INTEGER,val REAL,posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s1 := "INSERT INTO " + TblDist + " (iter,dim,mean,std) VALUES (?,?,?,?);"
+	s2 := "INSERT INTO " + TblProbs + " (iter,dim,value,prob) VALUES (?,?,?,?);"
+	for i := range m.Mean {
+		if m.Probs[i] == nil {
+			_, err := tx.Exec(s1, m.iter, i, m.Mean[i], m.Std[i])
+			if checkdberr(err) {
+				return
+			}
+			continue
+		}
+		for k, prob := range m.Probs[i] {
+			_, err := tx.Exec(s2, m.iter, i, m.Low[i]+float64(k), prob)
+			if checkdberr(err) {
+				return
+			}
+		}
+	}
+
+	s3 := "INSERT INTO " + TblBest + " (iter,val,posid) VALUES (?,?,?);"
+	_, err = tx.Exec(s3, m.iter, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.best)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("cem: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package cem
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestContinuous(t *testing.T) {
+	fn := bench.Styblinski{NDim: 4}
+	low, up := fn.Bounds()
+	m := New(low, up)
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 200,
+	}
+	for solv.Next() && solv.Best().Val > fn.Optima()[0].Val+1e-6 {
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if want := fn.Optima()[0].Val + 1e-6; solv.Best().Val > want {
+		t.Errorf("want < %v, got %v", want, solv.Best().Val)
+	}
+	for i, std := range m.Std {
+		if std > 0.1 {
+			t.Errorf("dimension %v: distribution did not contract: std %v", i, std)
+		}
+	}
+}
+
+func TestInteger(t *testing.T) {
+	// x1 and x2 are integers - optimum at (2.3, 3, -1)
+	obj := optim.Func(func(x []float64) float64 {
+		a, b, c := x[0]-2.3, x[1]-3, x[2]+1.4
+		return a*a + b*b + c*c
+	})
+	low, up := []float64{-10, -10, -5}, []float64{10, 10, 5}
+	m := New(low, up, Integer(1, 2), Evaler(optim.ParallelEvaler{}))
+	if len(m.Probs[1]) != 21 || len(m.Probs[2]) != 11 || m.Probs[0] != nil {
+		t.Fatalf("wrong categorical distribution sizes: %v, %v, %v", len(m.Probs[0]), len(m.Probs[1]), len(m.Probs[2]))
+	}
+
+	solv := &optim.Solver{Method: m, Obj: obj, Mesh: &optim.InfMesh{}, MaxIter: 50}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	got := solv.Best().Pos
+	if got[1] != 3 || got[2] != -1 || math.Abs(got[0]-2.3) > 1e-3 {
+		t.Errorf("want [2.3 3 -1], got %v", got)
+	}
+	if p := m.Probs[1][3-int(low[1])]; p < 0.9 {
+		t.Errorf("dimension 1: want probability of 3 > 0.9, got %v", p)
+	}
+	if p := m.Probs[2][-1-int(low[2])]; p < 0.9 {
+		t.Errorf("dimension 2: want probability of -1 > 0.9, got %v", p)
+	}
+	for k, p := range m.Probs[1] {
+		if p < 0 || p > 1 {
+			t.Errorf("invalid probability %v for value %v", p, low[1]+float64(k))
+		}
+	}
+}
+
+func TestSmoothing(t *testing.T) {
+	m := New([]float64{-1, 0}, []float64{1, 2}, Integer(1), Smoothing(0.5))
+	elite := []*optim.Point{
+		{Pos: []float64{0.5, 2}, Val: 0},
+		{Pos: []float64{0.1, 2}, Val: 0},
+	}
+	m.refit(elite)
+
+	if want := 0.5 * 0.3; math.Abs(m.Mean[0]-want) > 1e-12 {
+		t.Errorf("mean: want %v, got %v", want, m.Mean[0])
+	}
+	if want := 0.5*0.2 + 0.5*1; math.Abs(m.Std[0]-want) > 1e-12 {
+		t.Errorf("std: want %v, got %v", want, m.Std[0])
+	}
+	want := []float64{1.0 / 6, 1.0 / 6, 0.5 + 1.0/6}
+	for k := range want {
+		if math.Abs(m.Probs[1][k]-want[k]) > 1e-12 {
+			t.Errorf("probs: want %v, got %v", want, m.Probs[1])
+			break
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	low, up := []float64{-5, 0}, []float64{5, 3}
+	obj := optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1] })
+	solv := &optim.Solver{
+		Method:  New(low, up, Integer(1), DB(db), PopSize(20)),
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 5,
+	}
+	solv.Run()
+
+	counts := map[string]int{TblDist: 5, TblProbs: 5 * 4, TblBest: 5}
+	for tbl, want := range counts {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count != want {
+			t.Errorf("[ERROR] %v table has %v rows, want %v", tbl, count, want)
+		}
+	}
+}
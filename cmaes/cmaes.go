@@ -0,0 +1,410 @@
+// Package cmaes provides a covariance matrix adaptation evolution strategy
+// (CMA-ES) method.  Each iteration samples a population from a multivariate
+// normal distribution whose mean, step size and covariance matrix are adapted
+// (using cumulative step-size adaptation and rank-one plus rank-mu covariance
+// updates) as described in:
+//
+//     Hansen, Nikolaus. "The CMA evolution strategy: A tutorial." arXiv
+//     preprint arXiv:1604.00772 (2016).
+//
+// The method can optionally restart with increasing population sizes (IPOP)
+// or by alternating large and small population regimes (BIPOP) as described
+// in:
+//
+//     Auger, Anne, and Nikolaus Hansen. "A restart CMA evolution strategy with
+//     increasing population size." Evolutionary Computation, 2005. The 2005
+//     IEEE Congress on. Vol. 2. IEEE, 2005.
+//
+//     Hansen, Nikolaus. "Benchmarking a BI-population CMA-ES on the BBOB-2009
+//     function testbed." Proceedings of the 11th Annual Conference Companion
+//     on Genetic and Evolutionary Computation Conference. ACM, 2009.
+//
+// Each population is evaluated as a single batch through the method's
+// optim.Evaler, so using optim.ParallelEvaler evaluates the population
+// concurrently.
+package cmaes
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblGen is the name of the sql database table that contains the step
+	// size, population size and best point for each generation.
+	TblGen = "cmaesgen"
+	// TblPoints is the name of the sql database table that contains every
+	// point evaluated in each generation.
+	TblPoints = "cmaespoints"
+)
+
+// RestartStrategy specifies how a Method restarts after its distribution has
+// converged or degenerated.
+type RestartStrategy int
+
+const (
+	// NoRestart never restarts.
+	NoRestart RestartStrategy = iota
+	// IPOP restarts with the population size multiplied by IncPopSize.
+	IPOP
+	// BIPOP alternates between restarts using the IPOP regime and restarts
+	// with small populations and small initial step sizes.  The regime that
+	// has used fewer evaluations so far is chosen on each restart.
+	BIPOP
+)
+
+const (
+	DefaultTolFun     = 1e-12
+	DefaultTolX       = 1e-12
+	DefaultIncPopSize = 2
+	// MaxCond is the covariance matrix condition number above which a run
+	// is considered degenerate and restarted.
+	MaxCond = 1e14
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// PopSize sets the (initial) number of points sampled each generation.
+func PopSize(n int) Option { return func(m *Method) { m.Lambda = n } }
+
+// Bounds sets box bounds for the search.  Sampled points outside the bounds
+// are evaluated at the closest point on the boundary and ranked with a
+// penalty proportional to their squared distance from it.  Restarts draw new
+// means uniformly from within the bounds.
+func Bounds(low, up []float64) Option {
+	return func(m *Method) { m.Lower, m.Upper = low, up }
+}
+
+// Restart sets the restart strategy.
+func Restart(s RestartStrategy) Option { return func(m *Method) { m.Restart = s } }
+
+// Tols sets the function value and step size tolerances used to detect
+// convergence for restarts.
+func Tols(tolfun, tolx float64) Option {
+	return func(m *Method) { m.TolFun, m.TolX = tolfun, tolx }
+}
+
+type Method struct {
+	// Mean is the current mean of the sampling distribution.
+	Mean []float64
+	// Sigma is the current step size.
+	Sigma float64
+	// Lambda is the current population size.  If zero, 4+3*ln(ndim) is
+	// used.
+	Lambda int
+	// Lower and Upper are optional box bounds.
+	Lower, Upper []float64
+	Restart      RestartStrategy
+	// IncPopSize is the factor the population size is multiplied by for
+	// IPOP restarts.
+	IncPopSize float64
+	// TolFun is the range of best objective values over recent generations
+	// below which a run is considered converged.
+	TolFun float64
+	// TolX is the step size (relative to the initial step size) below which
+	// a run is considered converged.
+	TolX float64
+	// Nrestart is the number of restarts performed so far.
+	Nrestart int
+	Db       *sql.DB
+
+	start      []float64
+	sigma0     float64
+	lambda0    int // default population size
+	largepop   int // population size of the most recent large regime
+	nevallarge int // evaluations used by large population runs
+	nevalsmall int // evaluations used by small population runs
+	small      bool
+	st         *state
+	ev         optim.Evaler
+	best       *optim.Point
+	pop        []*optim.Point
+	iter       int
+}
+
+// New creates a CMA-ES method with its initial distribution centered at
+// start with step size sigma.  sigma should be roughly a third of the size of
+// the region expected to contain the optimum.
+func New(start *optim.Point, sigma float64, opts ...Option) *Method {
+	ndim := start.Len()
+	m := &Method{
+		Mean:       append([]float64{}, start.Pos...),
+		Sigma:      sigma,
+		IncPopSize: DefaultIncPopSize,
+		TolFun:     DefaultTolFun,
+		TolX:       DefaultTolX,
+		start:      append([]float64{}, start.Pos...),
+		sigma0:     sigma,
+		lambda0:    4 + int(3*math.Log(float64(ndim))),
+		ev:         optim.SerialEvaler{},
+		best:       start.Clone(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.Lambda == 0 {
+		m.Lambda = m.lambda0
+	}
+	m.largepop = m.Lambda
+
+	m.initdb()
+	return m
+}
+
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	if m.st == nil {
+		m.st = newState(len(m.Mean), m.Lambda)
+	}
+	st := m.st
+
+	// sample the population
+	xs := make([][]float64, st.lambda)
+	m.pop = make([]*optim.Point, st.lambda)
+	for k := range xs {
+		xs[k] = st.sample(m.Mean, m.Sigma)
+		pos := m.clip(xs[k])
+		if mesh != nil {
+			pos = mesh.Nearest(pos)
+		}
+		m.pop[k] = &optim.Point{Pos: pos, Val: math.Inf(1)}
+	}
+
+	n, err = m.evaluate(obj, m.pop)
+	if m.small {
+		m.nevalsmall += n
+	} else {
+		m.nevallarge += n
+	}
+
+	fit := m.fitness(xs)
+	for _, p := range m.pop {
+		if p.Val < m.best.Val {
+			m.best = p
+		}
+	}
+
+	order := make([]int, st.lambda)
+	for k := range order {
+		order[k] = k
+	}
+	sort.Sort(byfit{order, fit})
+
+	m.Sigma = st.update(m.Mean, m.Sigma, xs, order)
+	st.record(fit[order[0]], fit[order[len(order)-1]])
+
+	m.updateDb()
+	if m.Restart != NoRestart && m.converged() {
+		m.restart()
+	}
+	return m.best, n, err
+}
+
+// evaluate evaluates pts using the method's evaler.  Evalers skip duplicate
+// points - which are common on coarse or integer meshes - so their values
+// are shared with every copy.
+func (m *Method) evaluate(obj optim.Objectiver, pts []*optim.Point) (n int, err error) {
+	results, n, err := m.ev.Eval(obj, pts...)
+	vals := map[[sha1.Size]byte]float64{}
+	for _, p := range results {
+		vals[p.Hash()] = p.Val
+	}
+	for _, p := range pts {
+		if v, ok := vals[p.Hash()]; ok {
+			p.Val = v
+		}
+	}
+	return n, err
+}
+
+// clip returns x moved inside the box bounds (if any).
+func (m *Method) clip(x []float64) []float64 {
+	pos := append([]float64{}, x...)
+	if m.Lower == nil {
+		return pos
+	}
+	for i := range pos {
+		pos[i] = math.Min(m.Upper[i], math.Max(m.Lower[i], pos[i]))
+	}
+	return pos
+}
+
+// fitness returns the objective values of the population penalized by the
+// squared distance of each sample from the point it was evaluated at (within
+// the bounds).  The penalty weight is scaled to the spread of the
+// population's objective values.
+func (m *Method) fitness(xs [][]float64) []float64 {
+	fit := make([]float64, len(m.pop))
+	dists := make([]float64, len(m.pop))
+	finite := []float64{}
+	for k, p := range m.pop {
+		fit[k] = p.Val
+		if math.IsNaN(fit[k]) {
+			fit[k] = math.Inf(1)
+		}
+		if !math.IsInf(fit[k], 0) {
+			finite = append(finite, fit[k])
+		}
+		clipped := m.clip(xs[k])
+		for i := range clipped {
+			d := xs[k][i] - clipped[i]
+			dists[k] += d * d
+		}
+	}
+
+	if m.Lower == nil {
+		return fit
+	}
+
+	iqr := 1.0
+	if len(finite) > 1 {
+		sort.Float64s(finite)
+		if d := finite[3*len(finite)/4] - finite[len(finite)/4]; d > 0 {
+			iqr = d
+		}
+	}
+	weight := 2 * iqr / (m.Sigma * m.Sigma * m.st.meandiag())
+	for k := range fit {
+		fit[k] += weight * dists[k] / float64(len(xs[k]))
+	}
+	return fit
+}
+
+// converged returns true if the current run should be stopped.
+func (m *Method) converged() bool {
+	st := m.st
+	if len(st.history) >= st.histlen() {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range st.history {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		if hi-lo < m.TolFun && st.worst-st.history[len(st.history)-1] < m.TolFun {
+			return true
+		}
+	}
+
+	maxstd := 0.0
+	for i := range st.pc {
+		maxstd = math.Max(maxstd, math.Max(math.Abs(st.pc[i]), math.Sqrt(st.C[i][i])))
+	}
+	if m.Sigma*maxstd < m.TolX*m.sigma0 {
+		return true
+	}
+
+	dmin, dmax := math.Inf(1), 0.0
+	for _, d := range st.D {
+		dmin, dmax = math.Min(dmin, d), math.Max(dmax, d)
+	}
+	return dmax*dmax > MaxCond*dmin*dmin
+}
+
+func (m *Method) restart() {
+	m.Nrestart++
+	ndim := len(m.Mean)
+
+	if m.Restart == BIPOP && m.largepop > m.lambda0 && m.nevalsmall < m.nevallarge {
+		u := optim.RandFloat()
+		m.Lambda = int(float64(m.lambda0) * math.Pow(0.5*float64(m.largepop)/float64(m.lambda0), u*u))
+		m.Sigma = m.sigma0 * math.Pow(10, -2*optim.RandFloat())
+		m.small = true
+	} else {
+		m.largepop = int(float64(m.largepop) * m.IncPopSize)
+		m.Lambda = m.largepop
+		m.Sigma = m.sigma0
+		m.small = false
+	}
+
+	if m.Lower != nil {
+		for i := range m.Mean {
+			m.Mean[i] = m.Lower[i] + optim.RandFloat()*(m.Upper[i]-m.Lower[i])
+		}
+	} else {
+		copy(m.Mean, m.start)
+	}
+	m.st = newState(ndim, m.Lambda)
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblGen + " (iter INTEGER,restart INTEGER,lambda INTEGER,sigma REAL,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblPoints + " (iter INTEGER,val REAL,posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s1 := "INSERT INTO " + TblPoints + " (iter,val,posid) VALUES (?,?,?);"
+	for _, p := range m.pop {
+		_, err := tx.Exec(s1, m.iter, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+	}
+
+	glob := m.best
+	s2 := "INSERT INTO " + TblGen + " (iter,restart,lambda,sigma,val,posid) VALUES (?,?,?,?,?,?);"
+	_, err = tx.Exec(s2, m.iter, m.Nrestart, len(m.pop), m.Sigma, glob.Val, glob.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	pts := append([]*optim.Point{}, m.pop...)
+	pts = append(pts, glob)
+	err = optim.RecordPointPos(tx, pts...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+type byfit struct {
+	order []int
+	fit   []float64
+}
+
+func (b byfit) Len() int           { return len(b.order) }
+func (b byfit) Less(i, j int) bool { return b.fit[b.order[i]] < b.fit[b.order[j]] }
+func (b byfit) Swap(i, j int)      { b.order[i], b.order[j] = b.order[j], b.order[i] }
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("cmaes: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package cmaes
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestEigsym(t *testing.T) {
+	a := [][]float64{
+		{4, 1, -2, 2},
+		{1, 2, 0, 1},
+		{-2, 0, 3, -2},
+		{2, 1, -2, -1},
+	}
+	vals, vecs := eigsym(a)
+	for k, val := range vals {
+		for i := range a {
+			av := 0.0
+			for j := range a {
+				av += a[i][j] * vecs[j][k]
+			}
+			if math.Abs(av-val*vecs[i][k]) > 1e-9 {
+				t.Errorf("eigenpair %v: (Av)[%v]=%v, want %v", k, i, av, val*vecs[i][k])
+			}
+		}
+	}
+}
+
+// rotellipsoid is an ill-conditioned, non-separable quadratic.
+func rotellipsoid(x []float64) float64 {
+	n := len(x)
+	tot := 0.0
+	for i := 0; i < n; i++ {
+		// rotate by mixing neighboring coordinates
+		y := x[i] + x[(i+1)%n]
+		tot += math.Pow(1e6, float64(i)/float64(n-1)) * y * y
+	}
+	return tot
+}
+
+func TestIllConditioned(t *testing.T) {
+	ndim := 10
+	start := &optim.Point{Pos: make([]float64, ndim), Val: math.Inf(1)}
+	for i := range start.Pos {
+		start.Pos[i] = 1
+	}
+
+	solv := &optim.Solver{
+		Method:  New(start, 0.5),
+		Obj:     optim.Func(rotellipsoid),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 20000,
+	}
+	for solv.Next() {
+		if solv.Best().Val < 1e-10 {
+			break
+		}
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best().Val)
+	if solv.Best().Val > 1e-10 {
+		t.Errorf("want < 1e-10, got %v", solv.Best().Val)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	fn := bench.Rastrigin{NDim: 5}
+	low, up := fn.Bounds()
+
+	for _, strat := range []RestartStrategy{IPOP, BIPOP} {
+		start := &optim.Point{Pos: []float64{3, 3, 3, 3, 3}, Val: math.Inf(1)}
+		m := New(start, 2, Bounds(low, up), Restart(strat))
+		solv := &optim.Solver{
+			Method:  m,
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 50000,
+		}
+		for solv.Next() {
+			if solv.Best().Val < 1e-8 {
+				break
+			}
+		}
+
+		t.Logf("[INFO] strategy %v: %v evals, %v restarts (lambda=%v): got %v", strat, solv.Neval(), m.Nrestart, m.Lambda, solv.Best().Val)
+		if m.Nrestart == 0 {
+			t.Errorf("strategy %v: no restarts", strat)
+		}
+		if solv.Best().Val > 1e-8 {
+			t.Errorf("strategy %v: want < 1e-8, got %v", strat, solv.Best().Val)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	// unconstrained optimum at (-3, -3) lies outside the bounds
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		a, b := x[0]+3, x[1]+3
+		return a*a + b*b
+	})
+
+	start := &optim.Point{Pos: []float64{0.5, 0.5}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, 0.5, Bounds(low, up)),
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 2000,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if outside > 0 {
+		t.Errorf("%v points evaluated outside bounds", outside)
+	}
+	if math.Abs(solv.Best().Pos[0]+1) > 1e-6 || math.Abs(solv.Best().Pos[1]+1) > 1e-6 {
+		t.Errorf("want [-1 -1], got %v", solv.Best().Pos)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 5}
+	start := &optim.Point{Pos: make([]float64, 5), Val: math.Inf(1)}
+	m := New(start, 0.5, Evaler(optim.ParallelEvaler{}), PopSize(20))
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 1000,
+	}
+	for solv.Next() {
+		if solv.Best().Val < 1e-8 {
+			break
+		}
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best().Val)
+	if solv.Neval() != 20*solv.Niter() {
+		t.Errorf("want %v evals per iteration, got %v", 20, float64(solv.Neval())/float64(solv.Niter()))
+	}
+	if solv.Best().Val > 1e-8 {
+		t.Errorf("want < 1e-8, got %v", solv.Best().Val)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	// most samples round to the same few integer points
+	obj := optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1]*x[1] })
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	m := New(start, 0.5)
+	mesh := &optim.IntMesh{Mesh: &optim.InfMesh{StepSize: 1}}
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Iterate(obj, mesh); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.pop {
+			if want, _ := obj.Objective(p.Pos); p.Val != want {
+				t.Fatalf("gen %v: want %v for sample %v, got %v", i, want, p.Pos, p.Val)
+			}
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, 0.5, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 20,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblGen, TblPoints} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package cmaes
+
+import (
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// state holds the strategy parameters and evolution paths for a single
+// CMA-ES run.
+type state struct {
+	n, lambda, mu int
+	weights       []float64
+	mueff         float64
+	cc, cs        float64 // time constants for the evolution paths
+	c1, cmu       float64 // learning rates for rank-one and rank-mu updates
+	damps, chiN   float64
+
+	pc, ps   []float64
+	C, B     [][]float64 // covariance matrix and its eigenvectors
+	D        []float64   // square roots of the eigenvalues of C
+	gen      int
+	eigengen int       // generation of the most recent eigendecomposition
+	history  []float64 // best fitness of recent generations
+	worst    float64   // worst fitness of the most recent generation
+}
+
+func newState(n, lambda int) *state {
+	st := &state{n: n, lambda: lambda, mu: lambda / 2}
+	if st.mu < 1 {
+		st.mu = 1
+	}
+
+	st.weights = make([]float64, st.mu)
+	tot, tot2 := 0.0, 0.0
+	for i := range st.weights {
+		st.weights[i] = math.Log(float64(st.mu)+0.5) - math.Log(float64(i+1))
+		tot += st.weights[i]
+	}
+	for i := range st.weights {
+		st.weights[i] /= tot
+		tot2 += st.weights[i] * st.weights[i]
+	}
+	st.mueff = 1 / tot2
+
+	N := float64(n)
+	st.cc = (4 + st.mueff/N) / (N + 4 + 2*st.mueff/N)
+	st.cs = (st.mueff + 2) / (N + st.mueff + 5)
+	st.c1 = 2 / ((N+1.3)*(N+1.3) + st.mueff)
+	st.cmu = math.Min(1-st.c1, 2*(st.mueff-2+1/st.mueff)/((N+2)*(N+2)+st.mueff))
+	st.damps = 1 + 2*math.Max(0, math.Sqrt((st.mueff-1)/(N+1))-1) + st.cs
+	st.chiN = math.Sqrt(N) * (1 - 1/(4*N) + 1/(21*N*N))
+
+	st.pc = make([]float64, n)
+	st.ps = make([]float64, n)
+	st.C = identity(n)
+	st.B = identity(n)
+	st.D = make([]float64, n)
+	for i := range st.D {
+		st.D[i] = 1
+	}
+	return st
+}
+
+// sample returns a random point from the distribution N(mean, sigma^2*C).
+func (st *state) sample(mean []float64, sigma float64) []float64 {
+	z := make([]float64, st.n)
+	for i := range z {
+		z[i] = st.D[i] * optim.RandNorm()
+	}
+	x := make([]float64, st.n)
+	for i := range x {
+		x[i] = mean[i] + sigma*dot(st.B[i], z)
+	}
+	return x
+}
+
+// update moves mean (in-place) and adapts the evolution paths and covariance
+// matrix using the samples xs ranked by order.  It returns the new step size.
+func (st *state) update(mean []float64, sigma float64, xs [][]float64, order []int) float64 {
+	st.gen++
+	n := st.n
+	old := append([]float64{}, mean...)
+	for i := range mean {
+		mean[i] = 0
+		for k, w := range st.weights {
+			mean[i] += w * xs[order[k]][i]
+		}
+	}
+
+	// step normalized mean shift
+	y := make([]float64, n)
+	for i := range y {
+		y[i] = (mean[i] - old[i]) / sigma
+	}
+
+	// ps update uses C^(-1/2) = B*D^-1*B^T
+	bty := make([]float64, n)
+	for j := range bty {
+		for i := range y {
+			bty[j] += st.B[i][j] * y[i]
+		}
+		bty[j] /= st.D[j]
+	}
+	csn := math.Sqrt(st.cs * (2 - st.cs) * st.mueff)
+	for i := range st.ps {
+		st.ps[i] = (1-st.cs)*st.ps[i] + csn*dot(st.B[i], bty)
+	}
+
+	psnorm := math.Sqrt(dot(st.ps, st.ps))
+	hsig := 0.0
+	if psnorm/math.Sqrt(1-math.Pow(1-st.cs, 2*float64(st.gen)))/st.chiN < 1.4+2/float64(n+1) {
+		hsig = 1
+	}
+
+	ccn := math.Sqrt(st.cc * (2 - st.cc) * st.mueff)
+	for i := range st.pc {
+		st.pc[i] = (1-st.cc)*st.pc[i] + hsig*ccn*y[i]
+	}
+
+	// rank-one and rank-mu covariance update
+	steps := make([][]float64, st.mu)
+	for k := range steps {
+		steps[k] = make([]float64, n)
+		for i := range steps[k] {
+			steps[k][i] = (xs[order[k]][i] - old[i]) / sigma
+		}
+	}
+	decay := 1 - st.c1 - st.cmu
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			rankmu := 0.0
+			for k, w := range st.weights {
+				rankmu += w * steps[k][i] * steps[k][j]
+			}
+			c := decay*st.C[i][j] +
+				st.c1*(st.pc[i]*st.pc[j]+(1-hsig)*st.cc*(2-st.cc)*st.C[i][j]) +
+				st.cmu*rankmu
+			st.C[i][j], st.C[j][i] = c, c
+		}
+	}
+
+	sigma *= math.Exp((st.cs / st.damps) * (psnorm/st.chiN - 1))
+
+	// the eigendecomposition is O(n^3) so only update it every so often
+	if float64(st.gen-st.eigengen) > float64(st.lambda)/(st.c1+st.cmu)/float64(n)/10 {
+		st.eigengen = st.gen
+		vals, vecs := eigsym(st.C)
+		st.B = vecs
+		for i, v := range vals {
+			st.D[i] = math.Sqrt(math.Max(v, 1e-300))
+		}
+	}
+	return sigma
+}
+
+// record adds the best fitness of a generation to the history.
+func (st *state) record(best, worst float64) {
+	st.history = append(st.history, best)
+	if len(st.history) > st.histlen() {
+		st.history = st.history[1:]
+	}
+	st.worst = worst
+}
+
+// histlen returns the number of generations considered for the TolFun
+// convergence criterion.
+func (st *state) histlen() int {
+	return 10 + int(math.Ceil(30*float64(st.n)/float64(st.lambda)))
+}
+
+func (st *state) meandiag() float64 {
+	tot := 0.0
+	for i := range st.C {
+		tot += st.C[i][i]
+	}
+	return tot / float64(st.n)
+}
+
+// eigsym returns the eigenvalues and eigenvectors (as columns) of the
+// symmetric matrix a using the cyclic Jacobi method.
+func eigsym(a [][]float64) (vals []float64, vecs [][]float64) {
+	n := len(a)
+	s := make([][]float64, n)
+	for i := range s {
+		s[i] = append([]float64{}, a[i]...)
+	}
+	vecs = identity(n)
+
+	for sweep := 0; sweep < 100; sweep++ {
+		off, diag := 0.0, 0.0
+		for i := 0; i < n; i++ {
+			diag += s[i][i] * s[i][i]
+			for j := i + 1; j < n; j++ {
+				off += s[i][j] * s[i][j]
+			}
+		}
+		if off <= 1e-30*diag {
+			break
+		}
+
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if s[p][q] == 0 {
+					continue
+				}
+				theta := (s[q][q] - s[p][p]) / (2 * s[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				sn := t * c
+
+				for k := 0; k < n; k++ {
+					skp, skq := s[k][p], s[k][q]
+					s[k][p] = c*skp - sn*skq
+					s[k][q] = sn*skp + c*skq
+				}
+				for k := 0; k < n; k++ {
+					spk, sqk := s[p][k], s[q][k]
+					s[p][k] = c*spk - sn*sqk
+					s[q][k] = sn*spk + c*sqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := vecs[k][p], vecs[k][q]
+					vecs[k][p] = c*vkp - sn*vkq
+					vecs[k][q] = sn*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	vals = make([]float64, n)
+	for i := range vals {
+		vals[i] = s[i][i]
+	}
+	return vals, vecs
+}
+
+func identity(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	return m
+}
+
+func dot(a, b []float64) float64 {
+	tot := 0.0
+	for i := range a {
+		tot += a[i] * b[i]
+	}
+	return tot
+}
@@ -0,0 +1,318 @@
+// Package coord provides a coordinate search method that performs a line
+// search along each of a set of directions in turn.  Each iteration is one
+// sweep through the directions generated by a pattern.Spanner - in the
+// spanner's order (cyclic) or in a random order for every sweep (randomized).
+// Opposite directions (e.g. from pattern.Compass2N) share a single line
+// search.
+//
+// Line searches take steps of 1, 2, 4, ... mesh steps forward while they
+// keep improving and, if the first forward step fails, backward.  A final
+// parabolic interpolation step through the best point and its neighbors
+// refines the result.  The solver's optim.Mesh sets the base step and is
+// contracted after sweeps that make no progress.
+package coord
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/pattern"
+)
+
+// TblInfo is the name of the sql database table that contains the best
+// point and mesh step for each iteration.
+const TblInfo = "coordinfo"
+
+const (
+	// DefaultStepMult is the default factor by which the mesh step is
+	// multiplied after a sweep without improvement.
+	DefaultStepMult = 0.5
+	// MaxExpand is the maximum number of step doublings in each direction
+	// of a line search.
+	MaxExpand = 30
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Spanner sets the line search directions.  The default is the n coordinate
+// axes.
+func Spanner(s pattern.Spanner) Option { return func(m *Method) { m.Spanner = s } }
+
+// Randomized searches the directions of each sweep in a random order.
+func Randomized() Option { return func(m *Method) { m.Randomized = true } }
+
+// StepMult sets the factor (between 0 and 1) by which the mesh step is
+// multiplied after a sweep without improvement.
+func StepMult(mult float64) Option { return func(m *Method) { m.StepMult = mult } }
+
+type Method struct {
+	Curr       *optim.Point
+	Spanner    pattern.Spanner
+	Randomized bool
+	StepMult   float64
+	Db         *sql.DB
+	ev         optim.Evaler
+	count      int
+}
+
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		Curr:     start,
+		Spanner:  pattern.Compass2N{},
+		StepMult: DefaultStepMult,
+		ev:       optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.initdb()
+	return m
+}
+
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.Curr.Val {
+		m.Curr = p
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.count++
+	defer func() { m.updateDb(mesh.Step()) }()
+
+	if math.IsInf(m.Curr.Val, 1) {
+		m.Curr = &optim.Point{Pos: mesh.Nearest(m.Curr.Pos), Val: math.Inf(1)}
+		_, n, err = m.ev.Eval(obj, m.Curr)
+		if err != nil {
+			return m.Curr, n, err
+		}
+	}
+	mesh.SetOrigin(m.Curr.Pos)
+
+	var dirs [][]int
+	if ps, ok := m.Spanner.(pattern.PointSpanner); ok {
+		dirs = ps.SpanAt(m.Curr, mesh)
+	} else {
+		dirs = m.Spanner.Span(m.Curr.Len())
+	}
+	dirs = unique(dirs)
+	if m.Randomized {
+		perm := optim.Rand.Perm(len(dirs))
+		shuffled := make([][]int, len(dirs))
+		for i, j := range perm {
+			shuffled[j] = dirs[i]
+		}
+		dirs = shuffled
+	} else {
+		sort.Sort(bydir(dirs))
+	}
+
+	start := m.Curr
+	for _, dir := range dirs {
+		x, ni, err := m.linesearch(obj, mesh, dir)
+		n += ni
+		if err != nil {
+			return m.Curr, n, err
+		}
+		m.Curr = x
+	}
+
+	success := m.Curr != start
+	m.Spanner.Update(mesh.Step(), success)
+	if !success {
+		mesh.SetStep(mesh.Step() * m.StepMult)
+	}
+	mesh.SetOrigin(m.Curr.Pos)
+	return m.Curr, n, nil
+}
+
+// linesearch searches along dir from the current point.  Probes are placed
+// at t mesh steps along dir for t = 1, 2, 4, ... (or -1, -2, -4, ... if the
+// first forward probe fails) until a probe fails to improve.  The best probe
+// is then refined by the vertex of the parabola through it and its nearest
+// probed neighbors on either side.
+func (m *Method) linesearch(obj optim.Objectiver, mesh optim.Mesh, dir []int) (best *optim.Point, n int, err error) {
+	from := m.Curr
+	steps := optim.Steps(mesh, from.Len())
+	at := func(t float64) *optim.Point {
+		pos := make([]float64, from.Len())
+		for i := range pos {
+			pos[i] = from.Pos[i] + t*float64(dir[i])*steps[i]
+		}
+		return &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+	}
+
+	best = from
+	tbest := 0.0
+	probes := map[float64]float64{0: from.Val}
+	for _, sign := range []float64{1, -1} {
+		for t, k := sign, 0; k < MaxExpand; t, k = 2*t, k+1 {
+			p := at(t)
+			if samepos(p.Pos, best.Pos) {
+				// stuck against a bound or the mesh
+				break
+			}
+			_, ni, err := m.ev.Eval(obj, p)
+			n += ni
+			if err != nil {
+				return best, n, err
+			}
+			probes[t] = p.Val
+			if p.Val >= best.Val {
+				break
+			}
+			best, tbest = p, t
+		}
+		if best != from {
+			break
+		}
+	}
+
+	// parabolic interpolation through the best probe and its neighbors
+	lo, hi := math.Inf(-1), math.Inf(1)
+	for t := range probes {
+		if t < tbest && t > lo {
+			lo = t
+		} else if t > tbest && t < hi {
+			hi = t
+		}
+	}
+	if math.IsInf(lo, 0) || math.IsInf(hi, 0) {
+		return best, n, nil
+	}
+
+	flo, fmid, fhi := probes[lo], probes[tbest], probes[hi]
+	num := (tbest-lo)*(tbest-lo)*(fmid-fhi) - (tbest-hi)*(tbest-hi)*(fmid-flo)
+	den := (tbest-lo)*(fmid-fhi) - (tbest-hi)*(fmid-flo)
+	if den == 0 || math.IsInf(flo, 0) || math.IsInf(fhi, 0) {
+		return best, n, nil
+	}
+	p := at(tbest - 0.5*num/den)
+	for t := range probes {
+		if samepos(p.Pos, at(t).Pos) {
+			return best, n, nil
+		}
+	}
+	_, ni, err := m.ev.Eval(obj, p)
+	n += ni
+	if err != nil {
+		return best, n, err
+	} else if p.Val < best.Val {
+		best = p
+	}
+	return best, n, nil
+}
+
+// unique returns dirs with directions that are the negative of an earlier
+// direction removed.  Remaining directions are flipped if necessary so that
+// their first nonzero component is positive.
+func unique(dirs [][]int) [][]int {
+	uniq := [][]int{}
+	for _, d := range dirs {
+		d = append([]int{}, d...)
+		for _, v := range d {
+			if v < 0 {
+				for i := range d {
+					d[i] = -d[i]
+				}
+				break
+			} else if v > 0 {
+				break
+			}
+		}
+
+		dup := false
+		for _, u := range uniq {
+			if equal(u, d) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			uniq = append(uniq, d)
+		}
+	}
+	return uniq
+}
+
+// bydir orders directions so that coordinate axes come in dimension order.
+type bydir [][]int
+
+func (b bydir) Len() int      { return len(b) }
+func (b bydir) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b bydir) Less(i, j int) bool {
+	for k := range b[i] {
+		if b[i][k] != b[j][k] {
+			return b[i][k] > b[j][k]
+		}
+	}
+	return false
+}
+
+func equal(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func samepos(a, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblInfo + " (iter INTEGER,step REAL,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb(step float64) {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblInfo + " (iter,step,val,posid) VALUES (?,?,?,?);"
+	_, err = tx.Exec(s, m.count, step, m.Curr.Val, m.Curr.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.Curr)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("coord: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package coord
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestSeparable(t *testing.T) {
+	// a separable quadratic is solved by the first sweep up to the mesh
+	// resolution
+	obj := optim.Func(func(x []float64) float64 {
+		tot := 0.0
+		for i, v := range x {
+			d := v - float64(i+1)
+			tot += float64(i+1) * d * d
+		}
+		return tot
+	})
+	start := &optim.Point{Pos: make([]float64, 5), Val: math.Inf(1)}
+	m := New(start)
+	mesh := &optim.InfMesh{StepSize: 0.25}
+	best, n, err := m.Iterate(obj, mesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("[INFO] first sweep: %v evals: got %v", n, best)
+	for i, v := range best.Pos {
+		if v != float64(i+1) {
+			t.Errorf("want %v, got %v", []float64{1, 2, 3, 4, 5}, best.Pos)
+			break
+		}
+	}
+}
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 3}
+	for _, random := range []bool{false, true} {
+		start := &optim.Point{Pos: []float64{-1.2, 1, -1.2}, Val: math.Inf(1)}
+		opts := []Option{}
+		if random {
+			opts = append(opts, Randomized())
+		}
+		m := New(start, opts...)
+		solv := &optim.Solver{
+			Method:  m,
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{StepSize: 0.5},
+			MaxEval: 200000,
+			MinStep: 1e-10,
+		}
+		solv.Run()
+
+		t.Logf("[INFO] random=%v: %v evals: got %v", random, solv.Neval(), solv.Best().Val)
+		if solv.Best().Val > 1e-6 {
+			t.Errorf("random=%v: want < 1e-6, got %v", random, solv.Best().Val)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	dirs := [][]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}, {1, -1}}
+	got := unique(dirs)
+	want := [][]int{{0, 1}, {1, 0}, {1, -1}}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for i := range want {
+		if !equal(got[i], want[i]) {
+			t.Errorf("want %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestBoxMesh(t *testing.T) {
+	// the optimum (5, -5) lies outside the box
+	obj := optim.Func(func(x []float64) float64 {
+		a, b := x[0]-5, x[1]+5
+		return a*a + b*b
+	})
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	mesh := &optim.BoxMesh{Mesh: &optim.InfMesh{StepSize: 0.25}, Lower: low, Upper: up}
+	solv := &optim.Solver{Method: New(start), Obj: obj, Mesh: mesh, MaxIter: 100, MinStep: 1e-6}
+	solv.Run()
+
+	want := []float64{1, -1}
+	if got := solv.Best().Pos; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{StepSize: 0.5},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblInfo).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblInfo, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblInfo, count, solv.Niter())
+	}
+}
@@ -0,0 +1,146 @@
+package optim
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sync"
+)
+
+// TblSeeds is the name of the sql database table that contains the seed used
+// for each objective evaluation by a CRN objective along with the resulting
+// value.
+const TblSeeds = "seeds"
+
+// SeededObjectiver is implemented by stochastic objectives (e.g. Monte Carlo
+// simulators) whose randomness can be controlled by a seed.  Evaluating the
+// same variables with the same seed must give the same value.
+type SeededObjectiver interface {
+	Objectiver
+	// SeededObjective evaluates the variables in v using seed for all
+	// random number generation.
+	SeededObjective(v []float64, seed int64) (float64, error)
+}
+
+// SeedMode determines when a CRN objective draws a new seed.
+type SeedMode int
+
+const (
+	// SeedPerIter draws a new seed after every solver iteration.
+	SeedPerIter SeedMode = iota
+	// SeedPerBatch draws a new seed for every batch of points evaluated
+	// through the Evaler returned by CRN.Evaler.
+	SeedPerBatch
+	// SeedFixed always uses the same seed - turning the objective into a
+	// deterministic one.
+	SeedFixed
+)
+
+// CRN wraps a SeededObjectiver to evaluate points with common random numbers
+// - i.e. the same seed - so that differences between their values reflect
+// differences in the variables rather than in the random draws.  Comparing
+// the points of a poll or population this way needs far fewer evaluations
+// than comparing independently sampled values.  CRN implements Adapter, so
+// a Solver advances the seed after every iteration in SeedPerIter mode.
+// Note that values computed with different seeds are not directly
+// comparable - Solver re-scores its best point after each new seed, but the
+// incumbent of a method that doesn't implement Rescorer may have been
+// evaluated with a previous seed.  Wrapping a ResampleEvaler around
+// CRN.Evaler in SeedPerBatch mode resamples the incumbent with every round of
+// points.
+type CRN struct {
+	Obj  SeededObjectiver
+	Mode SeedMode
+	// Seed is the seed used for evaluations until the next seed is drawn.
+	Seed int64
+	// Db is used, if non-nil, to record the seed, position and value of
+	// every evaluation in TblSeeds.
+	Db *sql.DB
+	mu sync.Mutex
+}
+
+// NewCRN creates a CRN objective using the given seed mode with its first
+// seed drawn from optim.Rand.
+func NewCRN(obj SeededObjectiver, mode SeedMode) *CRN {
+	return &CRN{Obj: obj, Mode: mode, Seed: newSeed()}
+}
+
+func newSeed() int64 { return int64(Rand.Intn(math.MaxInt32)) }
+
+func (c *CRN) Objective(v []float64) (float64, error) {
+	seed := c.Seed
+	val, err := c.Obj.SeededObjective(v, seed)
+	c.record(v, seed, val)
+	return val, err
+}
+
+// Adapt draws a new seed in SeedPerIter mode.
+func (c *CRN) Adapt(best *Point) (changed bool) {
+	if c.Mode == SeedPerIter {
+		c.Seed = newSeed()
+		return true
+	}
+	return false
+}
+
+// Evaler returns an Evaler that evaluates points using ev, drawing a new seed
+// before each batch in SeedPerBatch mode.  Batches must not be evaluated
+// concurrently.  If ev is an IncumbentSetter, so is the returned Evaler.
+func (c *CRN) Evaler(ev Evaler) Evaler {
+	e := &crnEvaler{crn: c, ev: ev}
+	if is, ok := ev.(IncumbentSetter); ok {
+		return &crnIncumbentEvaler{crnEvaler: e, is: is}
+	}
+	return e
+}
+
+type crnEvaler struct {
+	crn *CRN
+	ev  Evaler
+}
+
+// crnIncumbentEvaler forwards incumbents to the wrapped Evaler.  It is a
+// separate type so that wrapping an Evaler without incumbents doesn't make
+// the CRN Evaler look noise-aware.
+type crnIncumbentEvaler struct {
+	*crnEvaler
+	is IncumbentSetter
+}
+
+func (e *crnIncumbentEvaler) SetIncumbent(p *Point) { e.is.SetIncumbent(p) }
+
+func (e *crnEvaler) Eval(obj Objectiver, points ...*Point) (results []*Point, n int, err error) {
+	if e.crn.Mode == SeedPerBatch {
+		e.crn.Seed = newSeed()
+	}
+	return e.ev.Eval(obj, points...)
+}
+
+func (c *CRN) record(v []float64, seed int64, val float64) {
+	if c.Db == nil {
+		return
+	}
+
+	// evaluations may be concurrent (e.g. ParallelEvaler)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tx, err := c.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	p := &Point{Pos: v, Val: val}
+	s := "CREATE TABLE IF NOT EXISTS " + TblSeeds + " (seed INTEGER,val REAL,posid BLOB);"
+	if _, err = tx.Exec(s); err == nil {
+		s = "INSERT INTO " + TblSeeds + " (seed,val,posid) VALUES (?,?,?);"
+		_, err = tx.Exec(s, seed, val, p.HashSlice())
+	}
+	if err == nil {
+		err = RecordPointPos(tx, p)
+	}
+	if err != nil {
+		log.Print("optim: db write failed -", err)
+	}
+}
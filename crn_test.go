@@ -0,0 +1,129 @@
+package optim
+
+import (
+	"database/sql"
+	"math"
+	"math/rand"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+)
+
+// simObj is a sphere with noise drawn from a generator seeded by the
+// evaluation's seed.
+type simObj struct{}
+
+func (o simObj) Objective(v []float64) (float64, error) {
+	return o.SeededObjective(v, rand.Int63())
+}
+
+func (o simObj) SeededObjective(v []float64, seed int64) (float64, error) {
+	r := rand.New(rand.NewSource(seed))
+	tot := 0.0
+	for _, x := range v {
+		tot += x*x + r.NormFloat64()
+	}
+	return tot, nil
+}
+
+func TestCRNModes(t *testing.T) {
+	pts := func() []*Point {
+		return []*Point{
+			{Pos: []float64{0}, Val: math.Inf(1)},
+			{Pos: []float64{1}, Val: math.Inf(1)},
+			{Pos: []float64{2}, Val: math.Inf(1)},
+		}
+	}
+
+	tests := []struct {
+		Mode                    SeedMode
+		NewPerIter, NewPerBatch bool
+	}{
+		{SeedPerIter, true, false},
+		{SeedPerBatch, false, true},
+		{SeedFixed, false, false},
+	}
+
+	for _, test := range tests {
+		crn := NewCRN(simObj{}, test.Mode)
+		ev := crn.Evaler(SerialEvaler{})
+
+		batch := pts()
+		if _, _, err := ev.Eval(crn, batch...); err != nil {
+			t.Fatal(err)
+		}
+		// common random numbers make differences within a batch exact
+		if d1, d2 := batch[1].Val-batch[0].Val, batch[2].Val-batch[0].Val; math.Abs(d1-1) > 1e-12 || math.Abs(d2-4) > 1e-12 {
+			t.Errorf("mode %v: want differences 1 and 4 within a batch, got %v and %v", test.Mode, d1, d2)
+		}
+
+		seed := crn.Seed
+		ev.Eval(crn, pts()...)
+		if changed := crn.Seed != seed; changed != test.NewPerBatch {
+			t.Errorf("mode %v: new seed per batch: want %v, got %v", test.Mode, test.NewPerBatch, changed)
+		}
+
+		seed = crn.Seed
+		crn.Adapt(batch[0])
+		if changed := crn.Seed != seed; changed != test.NewPerIter {
+			t.Errorf("mode %v: new seed per iteration: want %v, got %v", test.Mode, test.NewPerIter, changed)
+		}
+	}
+}
+
+func TestCRNIncumbent(t *testing.T) {
+	c := NewCRN(simObj{}, SeedPerBatch)
+	if _, ok := c.Evaler(SerialEvaler{}).(IncumbentSetter); ok {
+		t.Errorf("CRN evaler claims incumbents without a wrapped IncumbentSetter")
+	}
+
+	re := NewResampleEvaler(SerialEvaler{})
+	is, ok := c.Evaler(re).(IncumbentSetter)
+	if !ok {
+		t.Fatalf("CRN evaler wrapping a ResampleEvaler doesn't accept incumbents")
+	}
+	inc := &Point{Pos: []float64{1}, Val: 2}
+	is.SetIncumbent(inc)
+	if re.Incumbent != inc {
+		t.Errorf("incumbent not forwarded to the wrapped evaler")
+	}
+}
+
+func TestCRNDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	crn := NewCRN(simObj{}, SeedPerBatch)
+	crn.Db = db
+	ev := crn.Evaler(ParallelEvaler{})
+
+	seeds := map[int64]bool{}
+	for i := 0; i < 3; i++ {
+		pts := []*Point{{Pos: []float64{1, 2}}, {Pos: []float64{3, float64(i)}}}
+		if _, _, err := ev.Eval(crn, pts...); err != nil {
+			t.Fatal(err)
+		}
+		seeds[crn.Seed] = true
+	}
+
+	var count, nseed int
+	err = db.QueryRow("SELECT COUNT(*),COUNT(DISTINCT seed) FROM "+TblSeeds).Scan(&count, &nseed)
+	if err != nil {
+		t.Fatalf("[ERROR] %v table query failed: %v", TblSeeds, err)
+	} else if count != 6 || nseed != len(seeds) {
+		t.Errorf("[ERROR] %v table: want 6 rows with %v seeds, got %v rows with %v seeds", TblSeeds, len(seeds), count, nseed)
+	}
+
+	var val float64
+	var seed int64
+	err = db.QueryRow("SELECT val,seed FROM "+TblSeeds+" LIMIT 1").Scan(&val, &seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seeds[seed] {
+		t.Errorf("recorded seed %v was never used", seed)
+	}
+}
@@ -0,0 +1,530 @@
+// Package de provides a differential evolution method.  The classic
+// rand/1/bin and best/1/bin strategies are described in:
+//
+//     Storn, Rainer, and Kenneth Price. "Differential evolution - a simple and
+//     efficient heuristic for global optimization over continuous spaces."
+//     Journal of Global Optimization 11.4 (1997): 341-359.
+//
+// The current-to-pbest/1 strategy with an external archive and adaptation of
+// the mutation and crossover parameters is described in:
+//
+//     Zhang, Jingqiao, and Arthur C. Sanderson. "JADE: adaptive differential
+//     evolution with optional external archive." IEEE Transactions on
+//     Evolutionary Computation 13.5 (2009): 945-958.
+//
+//     Tanabe, Ryoji, and Alex Fukunaga. "Success-history based parameter
+//     adaptation for differential evolution." Evolutionary Computation (CEC),
+//     2013 IEEE Congress on. IEEE, 2013.
+package de
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblMembers is the name of the sql database table that contains
+	// positions and values for population members for each iteration.
+	TblMembers = "demembers"
+	// TblTrials is the name of the sql database table that contains the
+	// trial positions and values evaluated for each member for each
+	// iteration.
+	TblTrials = "detrials"
+	// TblBest is the name of the sql database table that contains the best
+	// position for the entire population at each iteration.
+	TblBest = "debest"
+)
+
+// Strategy specifies how mutant vectors are generated.
+type Strategy int
+
+const (
+	// Rand1Bin mutates a random member: v = x_r1 + F*(x_r2 - x_r3).
+	Rand1Bin Strategy = iota
+	// Best1Bin mutates the best member: v = x_best + F*(x_r1 - x_r2).
+	Best1Bin
+	// CurrToPBest1 mutates each member toward a random member of the best
+	// P fraction of the population: v = x_i + F*(x_pbest - x_i) + F*(x_r1 -
+	// x_r2) where x_r2 may also be drawn from an archive of recently
+	// replaced members.  F and CR are sampled for each member and adapted
+	// using successful values (JADE, or SHADE if Memory > 0).
+	CurrToPBest1
+)
+
+const (
+	DefaultF  = 0.8
+	DefaultCR = 0.9
+	// DefaultP is the default fraction of best members used by
+	// CurrToPBest1.
+	DefaultP = 0.1
+	// DefaultC is the default JADE parameter adaptation learning rate.
+	DefaultC = 0.1
+)
+
+// ErrPopSize is returned by New if the population has fewer members than the
+// mutation strategy needs distinct vectors - four for Rand1Bin and Best1Bin
+// and three for CurrToPBest1.
+var ErrPopSize = errors.New("de: population too small for mutation strategy")
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Mutation sets the strategy used to generate mutant vectors.
+func Mutation(s Strategy) Option { return func(m *Method) { m.Strategy = s } }
+
+// Params sets the mutation scale factor F and the crossover rate CR.  For
+// CurrToPBest1, these are the initial means of the adapted distributions.
+func Params(f, cr float64) Option { return func(m *Method) { m.F, m.CR = f, cr } }
+
+// Memory sets the number of historical parameter means kept for SHADE style
+// adaptation with the CurrToPBest1 strategy.  If zero, JADE style adaptation
+// is used.
+func Memory(h int) Option { return func(m *Method) { m.Memory = h } }
+
+// PBest sets the fraction of best members used by CurrToPBest1.
+func PBest(p float64) Option { return func(m *Method) { m.P = p } }
+
+// Bounds sets box bounds for trial vectors.  Mutant components outside the
+// bounds are set midway between the bound and the parent's component.
+func Bounds(low, up []float64) Option {
+	return func(m *Method) { m.Lower, m.Upper = low, up }
+}
+
+type Method struct {
+	Pop      []*optim.Point
+	Strategy Strategy
+	// F is the mutation scale factor (or its adapted mean for
+	// CurrToPBest1).
+	F float64
+	// CR is the crossover rate (or its adapted mean for CurrToPBest1).
+	CR float64
+	// P is the fraction of best members used by CurrToPBest1.
+	P float64
+	// C is the JADE learning rate for the parameter means.
+	C float64
+	// Memory is the number of historical parameter means used for SHADE.
+	Memory int
+	// Archive holds members recently replaced by better trial vectors.
+	Archive []*optim.Point
+	// Lower and Upper are optional box bounds.
+	Lower, Upper []float64
+	Db           *sql.DB
+
+	mf, mcr []float64 // SHADE memories
+	kmem    int       // next SHADE memory slot to update
+	trials  []*optim.Point
+	ev      optim.Evaler
+	best    *optim.Point
+	iter    int
+}
+
+// New creates a differential evolution method with the given initial
+// population (e.g. from optim.RandPop).  Members with infinite values are
+// evaluated on the first iteration.  ErrPopSize is returned if the population
+// is too small for the mutation strategy.
+func New(pop []*optim.Point, opts ...Option) (*Method, error) {
+	m := &Method{
+		Pop: pop,
+		F:   DefaultF,
+		CR:  DefaultCR,
+		P:   DefaultP,
+		C:   DefaultC,
+		ev:  optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if len(m.Pop) < minpop(m.Strategy) {
+		return nil, ErrPopSize
+	}
+
+	for i := 0; i < m.Memory; i++ {
+		m.mf = append(m.mf, m.F)
+		m.mcr = append(m.mcr, m.CR)
+	}
+	m.best = m.Pop[m.bestIndex()]
+
+	m.initdb()
+	return m, nil
+}
+
+// minpop returns the number of members strategy s draws distinct vectors
+// from (including the member being mutated).
+func minpop(s Strategy) int {
+	if s == CurrToPBest1 {
+		return 3
+	}
+	return 4
+}
+
+// AddPoint replaces the worst member of the population with p if p is better
+// than the population's best member.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val >= m.best.Val {
+		return
+	}
+	h := p.Hash()
+	for _, member := range m.Pop {
+		if member.Hash() == h {
+			return
+		}
+	}
+
+	m.best = p
+	worst := 0
+	for i, member := range m.Pop {
+		if member.Val > m.Pop[worst].Val {
+			worst = i
+		}
+	}
+	m.Pop[worst] = p.Clone()
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	// evaluate the initial population - later members with infinite values
+	// are infeasible rather than unevaluated
+	unknown := []*optim.Point{}
+	for _, p := range m.Pop {
+		if m.iter == 1 && math.IsInf(p.Val, 1) {
+			if mesh != nil {
+				p.Pos = mesh.Nearest(p.Pos)
+			}
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		_, n, err = m.ev.Eval(obj, unknown...)
+		m.best = m.Pop[m.bestIndex()]
+		m.trials = nil
+		return m.best, n, err
+	}
+
+	np := len(m.Pop)
+	fs := make([]float64, np)
+	crs := make([]float64, np)
+	m.trials = make([]*optim.Point, np)
+	ibest := m.bestIndex()
+	ranked := m.ranked()
+	for i := range m.Pop {
+		fs[i], crs[i] = m.sampleParams()
+		v := m.mutant(i, ibest, ranked, fs[i])
+		u := m.crossover(m.Pop[i].Pos, v, crs[i])
+		if mesh != nil {
+			u = mesh.Nearest(u)
+		}
+		m.trials[i] = &optim.Point{Pos: u, Val: math.Inf(1)}
+	}
+
+	_, n, err = m.ev.Eval(obj, m.trials...)
+
+	// selection
+	var sf, scr, weights []float64
+	for i, trial := range m.trials {
+		parent := m.Pop[i]
+		if trial.Val > parent.Val || math.IsNaN(trial.Val) {
+			continue
+		} else if trial.Val < parent.Val && m.Strategy == CurrToPBest1 {
+			m.Archive = append(m.Archive, parent)
+			sf = append(sf, fs[i])
+			scr = append(scr, crs[i])
+			weights = append(weights, parent.Val-trial.Val)
+		}
+		m.Pop[i] = trial
+	}
+
+	if m.Strategy == CurrToPBest1 {
+		for len(m.Archive) > np {
+			k := optim.Rand.Intn(len(m.Archive))
+			m.Archive[k] = m.Archive[len(m.Archive)-1]
+			m.Archive = m.Archive[:len(m.Archive)-1]
+		}
+		m.adapt(sf, scr, weights)
+	}
+
+	if pbest := m.Pop[m.bestIndex()]; pbest.Val < m.best.Val {
+		m.best = pbest
+	}
+	return m.best, n, err
+}
+
+// sampleParams returns F and CR values for generating a single trial vector.
+func (m *Method) sampleParams() (f, cr float64) {
+	if m.Strategy != CurrToPBest1 {
+		return m.F, m.CR
+	}
+
+	muf, mucr := m.F, m.CR
+	if m.Memory > 0 {
+		k := optim.Rand.Intn(m.Memory)
+		muf, mucr = m.mf[k], m.mcr[k]
+	}
+
+	cr = math.Min(1, math.Max(0, mucr+0.1*optim.RandNorm()))
+	for f <= 0 {
+		// cauchy distributed
+		f = muf + 0.1*math.Tan(math.Pi*(optim.RandFloat()-0.5))
+	}
+	return math.Min(1, f), cr
+}
+
+// adapt updates the parameter means using the successful F and CR values
+// weighted by the improvements they produced.
+func (m *Method) adapt(sf, scr, weights []float64) {
+	if len(sf) == 0 {
+		return
+	}
+
+	if m.Memory == 0 {
+		m.CR = (1-m.C)*m.CR + m.C*wmean(scr, nil)
+		m.F = (1-m.C)*m.F + m.C*lehmer(sf, nil)
+		return
+	}
+
+	m.mcr[m.kmem] = wmean(scr, weights)
+	m.mf[m.kmem] = lehmer(sf, weights)
+	m.kmem = (m.kmem + 1) % m.Memory
+}
+
+func (m *Method) mutant(i, ibest int, ranked []int, f float64) []float64 {
+	np := len(m.Pop)
+	x := m.Pop[i].Pos
+	v := make([]float64, len(x))
+
+	switch m.Strategy {
+	case Best1Bin:
+		r := m.distinct(np, 2, i, ibest)
+		b, x1, x2 := m.Pop[ibest].Pos, m.Pop[r[0]].Pos, m.Pop[r[1]].Pos
+		for j := range v {
+			v[j] = b[j] + f*(x1[j]-x2[j])
+		}
+	case CurrToPBest1:
+		npbest := int(math.Max(1, math.Floor(m.P*float64(np)+0.5)))
+		pb := m.Pop[ranked[optim.Rand.Intn(npbest)]].Pos
+		r1 := m.distinct(np, 1, i)[0]
+		x1 := m.Pop[r1].Pos
+
+		// r2 is drawn from the union of the population and archive
+		var x2 []float64
+		for x2 == nil {
+			r2 := optim.Rand.Intn(np + len(m.Archive))
+			if r2 == i || r2 == r1 {
+				continue
+			} else if r2 < np {
+				x2 = m.Pop[r2].Pos
+			} else {
+				x2 = m.Archive[r2-np].Pos
+			}
+		}
+		for j := range v {
+			v[j] = x[j] + f*(pb[j]-x[j]) + f*(x1[j]-x2[j])
+		}
+	default:
+		r := m.distinct(np, 3, i)
+		x1, x2, x3 := m.Pop[r[0]].Pos, m.Pop[r[1]].Pos, m.Pop[r[2]].Pos
+		for j := range v {
+			v[j] = x1[j] + f*(x2[j]-x3[j])
+		}
+	}
+
+	if m.Lower != nil {
+		for j := range v {
+			if v[j] < m.Lower[j] {
+				v[j] = (m.Lower[j] + x[j]) / 2
+			} else if v[j] > m.Upper[j] {
+				v[j] = (m.Upper[j] + x[j]) / 2
+			}
+		}
+	}
+	return v
+}
+
+// crossover performs binomial crossover between x and v - at least one
+// component is always taken from v.
+func (m *Method) crossover(x, v []float64, cr float64) []float64 {
+	u := make([]float64, len(x))
+	jrand := optim.Rand.Intn(len(x))
+	for j := range u {
+		if j == jrand || optim.RandFloat() < cr {
+			u[j] = v[j]
+		} else {
+			u[j] = x[j]
+		}
+	}
+	return u
+}
+
+// distinct returns n distinct random indices in [0,np) that are different
+// from all the excluded indices.  If the population is too small, indices may
+// be repeated.
+func (m *Method) distinct(np, n int, exclude ...int) []int {
+	used := map[int]bool{}
+	for _, i := range exclude {
+		used[i] = true
+	}
+	r := make([]int, 0, n)
+	for len(r) < n {
+		i := optim.Rand.Intn(np)
+		if used[i] && len(used) < np {
+			continue
+		}
+		used[i] = true
+		r = append(r, i)
+	}
+	return r
+}
+
+func (m *Method) bestIndex() int {
+	best := 0
+	for i, p := range m.Pop {
+		if p.Val < m.Pop[best].Val {
+			best = i
+		}
+	}
+	return best
+}
+
+// ranked returns the population indices sorted from best to worst.
+func (m *Method) ranked() []int {
+	idx := make([]int, len(m.Pop))
+	for i := range idx {
+		idx[i] = i
+	}
+	// insertion sort - populations are small
+	for i := 1; i < len(idx); i++ {
+		for j := i; j > 0 && m.Pop[idx[j]].Val < m.Pop[idx[j-1]].Val; j-- {
+			idx[j], idx[j-1] = idx[j-1], idx[j]
+		}
+	}
+	return idx
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblMembers + " (member INTEGER, iter INTEGER, val REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblTrials + " (member INTEGER, iter INTEGER, val REAL, posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblBest + " (iter INTEGER, val REAL, posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s0, err := tx.Prepare("INSERT INTO " + TblMembers + " (member,iter,val,posid) VALUES (?,?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+	s1, err := tx.Prepare("INSERT INTO " + TblTrials + " (member,iter,val,posid) VALUES (?,?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+
+	pts := []*optim.Point{}
+	for i, p := range m.Pop {
+		_, err := s0.Exec(i, m.iter, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+		pts = append(pts, p)
+	}
+	for i, p := range m.trials {
+		_, err := s1.Exec(i, m.iter, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+		pts = append(pts, p)
+	}
+
+	s2 := "INSERT INTO " + TblBest + " (iter,val,posid) VALUES (?,?,?);"
+	_, err = tx.Exec(s2, m.iter, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	pts = append(pts, m.best)
+	err = optim.RecordPointPos(tx, pts...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+// wmean returns the mean of vals weighted by weights (nil for equal
+// weights).
+func wmean(vals, weights []float64) float64 {
+	tot, wtot := 0.0, 0.0
+	for i, v := range vals {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		tot += w * v
+		wtot += w
+	}
+	if wtot == 0 && weights != nil {
+		return wmean(vals, nil)
+	}
+	return tot / wtot
+}
+
+// lehmer returns the Lehmer mean of vals weighted by weights (nil for equal
+// weights).
+func lehmer(vals, weights []float64) float64 {
+	num, den := 0.0, 0.0
+	for i, v := range vals {
+		w := 1.0
+		if weights != nil {
+			w = weights[i]
+		}
+		num += w * v * v
+		den += w * v
+	}
+	if den == 0 && weights != nil {
+		return lehmer(vals, nil)
+	} else if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("de: db write failed -", err)
+		return true
+	}
+	return false
+}
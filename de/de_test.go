@@ -0,0 +1,207 @@
+package de
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+	"github.com/baaaaam/optim/pattern"
+)
+
+func TestStrategies(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 2}
+	low, up := fn.Bounds()
+
+	cases := []struct {
+		name string
+		opts []Option
+	}{
+		{"rand/1/bin", []Option{Mutation(Rand1Bin)}},
+		{"best/1/bin", []Option{Mutation(Best1Bin)}},
+		{"JADE", []Option{Mutation(CurrToPBest1)}},
+		{"SHADE", []Option{Mutation(CurrToPBest1), Memory(10)}},
+	}
+
+	for _, c := range cases {
+		opts := append(c.opts, Bounds(low, up))
+		m, err := New(optim.RandPop(20, low, up), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		solv := &optim.Solver{
+			Method:  m,
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 20000,
+		}
+		for solv.Next() {
+			if solv.Best().Val < 1e-6 {
+				break
+			}
+		}
+
+		t.Logf("[INFO] %v: %v evals: got %v", c.name, solv.Neval(), solv.Best().Val)
+		if solv.Best().Val > 1e-6 {
+			t.Errorf("%v: want < 1e-6, got %v", c.name, solv.Best().Val)
+		}
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	fn := bench.Rastrigin{NDim: 5}
+	low, up := fn.Bounds()
+	m, err := New(optim.RandPop(40, low, up), Mutation(CurrToPBest1), Memory(5), Bounds(low, up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 300,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v, memory F=%v CR=%v, archive size %v", solv.Neval(), solv.Best().Val, m.mf, m.mcr, len(m.Archive))
+	if len(m.Archive) > len(m.Pop) {
+		t.Errorf("archive size %v exceeds population size %v", len(m.Archive), len(m.Pop))
+	}
+	changed := false
+	for i := range m.mf {
+		changed = changed || m.mf[i] != DefaultF || m.mcr[i] != DefaultCR
+	}
+	if !changed {
+		t.Errorf("parameter memory was never updated")
+	}
+	if solv.Best().Val > fn.Tol() {
+		t.Errorf("want < %v, got %v", fn.Tol(), solv.Best().Val)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		a, b := x[0]+3, x[1]+3
+		return a*a + b*b
+	})
+
+	m, err := New(optim.RandPop(20, low, up), Bounds(low, up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 200,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if outside > 0 {
+		t.Errorf("%v points evaluated outside bounds", outside)
+	}
+	if math.Abs(solv.Best().Pos[0]+1) > 1e-4 || math.Abs(solv.Best().Pos[1]+1) > 1e-4 {
+		t.Errorf("want [-1 -1], got %v", solv.Best().Pos)
+	}
+}
+
+func TestSearcher(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 3}
+	low, up := fn.Bounds()
+	start := &optim.Point{Pos: []float64{-1, 2, -1}, Val: math.Inf(1)}
+	mesh := &optim.InfMesh{StepSize: 1}
+	mesh.SetOrigin(start.Pos)
+
+	search, err := New(optim.RandPop(20, low, up), Bounds(low, up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solv := &optim.Solver{
+		Method:  pattern.New(start, pattern.SearchMethod(search, pattern.Share)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    mesh,
+		MaxEval: 50000,
+		MinStep: 1e-10,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if solv.Best().Val > 1e-6 {
+		t.Errorf("want < 1e-6, got %v", solv.Best().Val)
+	}
+}
+
+func TestPopSize(t *testing.T) {
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	cases := []struct {
+		Strategy Strategy
+		Min      int
+	}{
+		{Rand1Bin, 4},
+		{Best1Bin, 4},
+		{CurrToPBest1, 3},
+	}
+
+	for _, c := range cases {
+		for np := 0; np <= c.Min; np++ {
+			m, err := New(optim.RandPop(np, low, up), Mutation(c.Strategy))
+			if np < c.Min {
+				if err != ErrPopSize {
+					t.Errorf("strategy %v with %v members: want ErrPopSize, got %v", c.Strategy, np, err)
+				}
+				continue
+			} else if err != nil {
+				t.Fatalf("strategy %v with %v members: %v", c.Strategy, np, err)
+			}
+
+			solv := &optim.Solver{
+				Method:  m,
+				Obj:     optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1]*x[1] }),
+				MaxIter: 5,
+			}
+			solv.Run()
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Basic[0]
+	low, up := fn.Bounds()
+	m, err := New(optim.RandPop(20, low, up), DB(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 20,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblMembers, TblTrials, TblBest} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
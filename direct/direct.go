@@ -0,0 +1,420 @@
+// Package direct provides the DIRECT (DIviding RECTangles) global optimizer
+// and its locally biased DIRECT-L variant as described in:
+//
+//     Jones, Donald R., Cary D. Perttunen, and Bruce E. Stuckman. "Lipschitzian
+//     optimization without the Lipschitz constant." Journal of Optimization
+//     Theory and Applications 79.1 (1993): 157-181.
+//
+//     Gablonsky, Joerg M., and Carl T. Kelley. "A locally-biased form of the
+//     DIRECT algorithm." Journal of Global Optimization 21.1 (2001): 27-37.
+//
+// The search space is the box defined by lower and upper bounds.  Each call
+// to Iterate divides all potentially optimal rectangles and evaluates the
+// centers of the newly created rectangles as a single batch.  The method is
+// deterministic and ignores the mesh step - evaluated points are only
+// projected onto the mesh (e.g. to respect a BoxMesh or IntMesh).
+package direct
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblRects is the name of the sql database table that contains every
+	// rectangle created along with the iteration it was created in and its
+	// parent's id.
+	TblRects = "directrects"
+	// TblBest is the name of the sql database table that contains the best
+	// position found at each iteration.
+	TblBest = "directbest"
+)
+
+// DefaultEps is the default minimum relative improvement over the current
+// best value a rectangle must be able to achieve to be potentially optimal.
+const DefaultEps = 1e-4
+
+// Rect is a node in DIRECT's rectangle tree.  Dividing a rectangle turns it
+// into an interior node whose children partition it.  The first child always
+// shares its parent's center point.
+type Rect struct {
+	ID     int
+	Parent *Rect
+	// Children is nil for rectangles that have not been divided.
+	Children []*Rect
+	// Center is the rectangle's center in the original coordinates.
+	Center []float64
+	// Levels holds the number of times each side has been trisected - the
+	// side length in dimension i is (up[i]-low[i]) * 3^-Levels[i].
+	Levels []int
+	// Point is the evaluated center (possibly projected onto the mesh).
+	Point *optim.Point
+	// Iter is the iteration in which the rectangle was created.
+	Iter int
+}
+
+// Leaf returns true if r has not been divided.
+func (r *Rect) Leaf() bool { return len(r.Children) == 0 }
+
+// Leaves returns all undivided rectangles in r's subtree.
+func (r *Rect) Leaves() []*Rect {
+	if r.Leaf() {
+		return []*Rect{r}
+	}
+	leaves := []*Rect{}
+	for _, c := range r.Children {
+		leaves = append(leaves, c.Leaves()...)
+	}
+	return leaves
+}
+
+// Volume returns r's volume as a fraction of the entire search space.
+func (r *Rect) Volume() float64 {
+	v := 1.0
+	for _, l := range r.Levels {
+		v *= math.Pow(3, -float64(l))
+	}
+	return v
+}
+
+// size returns r's size measure.  DIRECT uses the distance from the center
+// to the vertices of the normalized rectangle while DIRECT-L uses the
+// longest side.
+func (r *Rect) size(local bool) float64 {
+	if local {
+		return math.Pow(3, -float64(minlevel(r.Levels)))
+	}
+	tot := 0.0
+	for _, l := range r.Levels {
+		s := math.Pow(3, -float64(l))
+		tot += s * s
+	}
+	return math.Sqrt(tot) / 2
+}
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Local enables the DIRECT-L variant which divides at most one rectangle of
+// each size per iteration and measures sizes by their longest side.  This
+// speeds convergence for problems with few local minima.
+func Local() Option { return func(m *Method) { m.Local = true } }
+
+// Eps sets the minimum relative improvement a rectangle must be able to
+// achieve to be potentially optimal.  Larger values make the search more
+// global.
+func Eps(eps float64) Option { return func(m *Method) { m.Eps = eps } }
+
+type Method struct {
+	// Root is the rectangle covering the entire search space.
+	Root  *Rect
+	Low   []float64
+	Up    []float64
+	Local bool
+	Eps   float64
+	Db    *sql.DB
+	ev    optim.Evaler
+	best  *optim.Point
+	iter  int
+	nrect int
+	// added holds rectangles created in the current iteration.
+	added []*Rect
+}
+
+// New creates a DIRECT method searching the box defined by low and up.
+func New(low, up []float64, opts ...Option) *Method {
+	m := &Method{
+		Low:  append([]float64{}, low...),
+		Up:   append([]float64{}, up...),
+		Eps:  DefaultEps,
+		ev:   optim.SerialEvaler{},
+		best: &optim.Point{Val: math.Inf(1)},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint updates the best point if p is better.  Points found by other
+// methods do not affect the rectangle partition.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p.Clone()
+	}
+}
+
+// Leaves returns the current (undivided) rectangles.
+func (m *Method) Leaves() []*Rect {
+	if m.Root == nil {
+		return nil
+	}
+	return m.Root.Leaves()
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	m.added = nil
+	defer m.updateDb()
+
+	if m.Root == nil {
+		center := make([]float64, len(m.Low))
+		for i := range center {
+			center[i] = (m.Low[i] + m.Up[i]) / 2
+		}
+		m.Root = m.newRect(nil, center, make([]int, len(center)), mesh)
+		_, n, err = m.ev.Eval(obj, m.Root.Point)
+		m.updateBest(m.Root.Point)
+		return m.best, n, err
+	}
+
+	// sample along the longest sides of each potentially optimal rectangle
+	type division struct {
+		rect *Rect
+		dims []int
+		// plus and minus hold the new rectangles for each dim.
+		plus, minus []*Rect
+	}
+	divs := []*division{}
+	points := []*optim.Point{}
+	for _, r := range m.potentiallyOptimal() {
+		d := &division{rect: r}
+		lmin := minlevel(r.Levels)
+		for i, l := range r.Levels {
+			if l != lmin {
+				continue
+			}
+			delta := (m.Up[i] - m.Low[i]) * math.Pow(3, -float64(l+1))
+			for _, sign := range []float64{1, -1} {
+				c := append([]float64{}, r.Center...)
+				c[i] += sign * delta
+				child := m.newRect(r, c, nil, mesh)
+				if sign > 0 {
+					d.plus = append(d.plus, child)
+				} else {
+					d.minus = append(d.minus, child)
+				}
+				points = append(points, child.Point)
+			}
+			d.dims = append(d.dims, i)
+		}
+		divs = append(divs, d)
+	}
+
+	_, n, err = m.ev.Eval(obj, points...)
+	for _, p := range points {
+		m.updateBest(p)
+	}
+
+	// divide along dims in order of their best samples so the best samples
+	// end up in the largest rectangles
+	for _, d := range divs {
+		order := make([]int, len(d.dims))
+		w := make([]float64, len(d.dims))
+		for k := range order {
+			order[k] = k
+			w[k] = math.Min(d.plus[k].Point.Val, d.minus[k].Point.Val)
+		}
+		sort.Stable(byweight{order, w})
+
+		r := d.rect
+		levels := append([]int{}, r.Levels...)
+		children := []*Rect{}
+		for _, k := range order {
+			levels[d.dims[k]]++
+			d.plus[k].Levels = append([]int{}, levels...)
+			d.minus[k].Levels = append([]int{}, levels...)
+			children = append(children, d.plus[k], d.minus[k])
+		}
+		center := m.newRect(r, r.Center, levels, nil)
+		center.Point = r.Point
+		r.Children = append([]*Rect{center}, children...)
+	}
+
+	return m.best, n, err
+}
+
+// newRect creates a rectangle with an unevaluated center point projected
+// onto mesh.  If mesh is nil, the rectangle has no point.
+func (m *Method) newRect(parent *Rect, center []float64, levels []int, mesh optim.Mesh) *Rect {
+	r := &Rect{
+		ID:     m.nrect,
+		Parent: parent,
+		Center: center,
+		Levels: levels,
+		Iter:   m.iter,
+	}
+	if mesh != nil {
+		r.Point = &optim.Point{Pos: mesh.Nearest(center), Val: math.Inf(1)}
+	}
+	m.nrect++
+	m.added = append(m.added, r)
+	return r
+}
+
+func (m *Method) updateBest(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p
+	}
+}
+
+// potentiallyOptimal returns the leaves that could contain the global
+// minimum for some Lipschitz constant K > 0, i.e. the rectangles on the lower
+// right convex hull of the (size, value) diagram that are also able to
+// improve on the best value by at least Eps.
+func (m *Method) potentiallyOptimal() []*Rect {
+	// group leaves by size, keeping only the best in each group
+	groups := map[float64]*group{}
+	fmin := math.Inf(1)
+	for _, r := range m.Leaves() {
+		s := r.size(m.Local)
+		// round off floating point noise so equal sizes group together
+		s = float64(float32(s))
+		g, ok := groups[s]
+		if !ok {
+			g = &group{size: s, val: math.Inf(1)}
+			groups[s] = g
+		}
+		v := r.Point.Val
+		if v < g.val {
+			g.val, g.rs = v, []*Rect{r}
+		} else if v == g.val && !m.Local {
+			g.rs = append(g.rs, r)
+		}
+		fmin = math.Min(fmin, v)
+	}
+
+	sorted := bysize{}
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Sort(sorted)
+
+	opt := []*Rect{}
+	for j, g := range sorted {
+		klow, khigh := math.Inf(-1), math.Inf(1)
+		for i, o := range sorted {
+			if i < j {
+				klow = math.Max(klow, (g.val-o.val)/(g.size-o.size))
+			} else if i > j {
+				khigh = math.Min(khigh, (o.val-g.val)/(o.size-g.size))
+			}
+		}
+		if khigh < 0 || klow > khigh {
+			continue
+		}
+		if !math.IsInf(khigh, 1) && g.val-khigh*g.size > fmin-m.Eps*math.Abs(fmin) {
+			continue
+		}
+		opt = append(opt, g.rs...)
+	}
+	return opt
+}
+
+// group holds the best rectangles with a common size.
+type group struct {
+	size float64
+	val  float64
+	rs   []*Rect
+}
+
+type bysize []*group
+
+func (s bysize) Len() int           { return len(s) }
+func (s bysize) Less(i, j int) bool { return s[i].size < s[j].size }
+func (s bysize) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type byweight struct {
+	order []int
+	w     []float64
+}
+
+func (s byweight) Len() int           { return len(s.order) }
+func (s byweight) Less(i, j int) bool { return s.w[s.order[i]] < s.w[s.order[j]] }
+func (s byweight) Swap(i, j int)      { s.order[i], s.order[j] = s.order[j], s.order[i] }
+
+func minlevel(levels []int) int {
+	min := levels[0]
+	for _, l := range levels[1:] {
+		if l < min {
+			min = l
+		}
+	}
+	return min
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblRects + " (id INTEGER, parent INTEGER, iter INTEGER, volume REAL, val REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblBest + " (iter INTEGER, val REAL, posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s0, err := tx.Prepare("INSERT INTO " + TblRects + " (id,parent,iter,volume,val,posid) VALUES (?,?,?,?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+	pts := []*optim.Point{m.best}
+	for _, r := range m.added {
+		parent := -1
+		if r.Parent != nil {
+			parent = r.Parent.ID
+		}
+		_, err := s0.Exec(r.ID, parent, r.Iter, r.Volume(), r.Point.Val, r.Point.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+		pts = append(pts, r.Point)
+	}
+
+	s1 := "INSERT INTO " + TblBest + " (iter,val,posid) VALUES (?,?,?);"
+	_, err = tx.Exec(s1, m.iter, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, pts...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("direct: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package direct
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestBench(t *testing.T) {
+	fns := []bench.Func{
+		bench.Styblinski{NDim: 3},
+		bench.HolderTable{},
+		bench.Eggholder{},
+	}
+
+	for _, fn := range fns {
+		for _, local := range []bool{false, true} {
+			low, up := fn.Bounds()
+			opts := []Option{}
+			if local {
+				opts = append(opts, Local())
+			}
+			solv := &optim.Solver{
+				Method:  New(low, up, opts...),
+				Obj:     optim.Func(fn.Eval),
+				Mesh:    &optim.InfMesh{},
+				MaxEval: 5000,
+			}
+			for solv.Next() {
+				if solv.Best().Val < fn.Tol() {
+					break
+				}
+			}
+
+			t.Logf("[INFO] %v (local=%v): %v evals: got %v", fn.Name(), local, solv.Neval(), solv.Best())
+			if solv.Best().Val > fn.Tol() {
+				t.Errorf("%v (local=%v): want < %v, got %v", fn.Name(), local, fn.Tol(), solv.Best().Val)
+			}
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	m := New(low, up, Evaler(optim.ParallelEvaler{}))
+	obj := optim.Func(fn.Eval)
+	mesh := &optim.InfMesh{}
+
+	neval := 0
+	for i := 0; i < 20; i++ {
+		_, n, err := m.Iterate(obj, mesh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		neval += n
+	}
+
+	// leaves partition the search space and each evaluated point belongs to
+	// exactly one leaf
+	leaves := m.Leaves()
+	vol := 0.0
+	for _, r := range leaves {
+		vol += r.Volume()
+		for i, c := range r.Center {
+			half := (up[i] - low[i]) * math.Pow(3, -float64(r.Levels[i])) / 2
+			if c-half < low[i]-1e-12 || c+half > up[i]+1e-12 {
+				t.Errorf("rect %v extends outside bounds in dim %v", r.ID, i)
+			}
+		}
+		if math.IsInf(r.Point.Val, 1) {
+			t.Errorf("rect %v center not evaluated", r.ID)
+		}
+	}
+	if math.Abs(vol-1) > 1e-9 {
+		t.Errorf("leaf volumes sum to %v, want 1", vol)
+	}
+	if len(leaves) != neval {
+		t.Errorf("got %v leaves for %v evaluations", len(leaves), neval)
+	}
+
+	for _, r := range leaves {
+		if r.Parent == nil {
+			continue
+		}
+		parentvol := 0.0
+		for _, c := range r.Parent.Children {
+			parentvol += c.Volume()
+		}
+		if math.Abs(parentvol-r.Parent.Volume()) > 1e-12 {
+			t.Errorf("children of rect %v have volume %v, want %v", r.Parent.ID, parentvol, r.Parent.Volume())
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	// DIRECT-L should need fewer evaluations on a smooth, unimodal function
+	obj := optim.Func(func(x []float64) float64 {
+		tot := 0.0
+		for i, v := range x {
+			d := v - 0.1*float64(i+1)
+			tot += d * d
+		}
+		return tot
+	})
+	low, up := []float64{-1, -1, -1, -1}, []float64{1, 1, 1, 1}
+
+	evals := func(opts ...Option) int {
+		solv := &optim.Solver{
+			Method:  New(low, up, opts...),
+			Obj:     obj,
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 20000,
+		}
+		for solv.Next() {
+			if solv.Best().Val < 1e-4 {
+				break
+			}
+		}
+		return solv.Neval()
+	}
+
+	global, local := evals(), evals(Local())
+	t.Logf("[INFO] DIRECT: %v evals, DIRECT-L: %v evals", global, local)
+	if local >= global {
+		t.Errorf("DIRECT-L used %v evals, DIRECT used %v", local, global)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Styblinski{NDim: 2}
+	low, up := fn.Bounds()
+	solv := &optim.Solver{
+		Method:  New(low, up, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblRects, TblBest} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
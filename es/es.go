@@ -0,0 +1,297 @@
+// Package es provides simple evolution strategies intended as cheap
+// baselines.  By default a (1+1)-ES is used: each iteration mutates the
+// parent with an isotropic normal step and the offspring replaces the parent
+// if it is at least as good.  The step size is adapted with the 1/5th success
+// rule as described in:
+//
+//     Kern, Stefan, et al. "Learning probability distributions in continuous
+//     evolutionary algorithms - a comparative review." Natural Computing 3.1
+//     (2004): 77-112.
+//
+// The MuLambda option selects a (mu/mu_w, lambda)-ES instead.  Each offspring
+// carries its own step size which is mutated log-normally before the
+// offspring is sampled (self-adaptation).  The mu best offspring are
+// recombined - both positions and (geometrically) step sizes - using
+// logarithmically decreasing weights to form the next parent as described
+// in:
+//
+//     Beyer, Hans-Georg, and Hans-Paul Schwefel. "Evolution strategies - A
+//     comprehensive introduction." Natural Computing 1.1 (2002): 3-52.
+//
+// Each (mu/mu_w, lambda) generation is evaluated as a single batch through
+// the method's optim.Evaler.  Sampled points are projected onto the solver's
+// mesh before evaluation.
+package es
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+// TblIter is the name of the sql database table that contains the step size
+// and best point for each iteration.
+const TblIter = "esiter"
+
+// TargetSuccess is the success rate the 1/5th success rule maintains by
+// growing the step size after successful mutations and shrinking it after
+// failed ones.
+const TargetSuccess = 0.2
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// MuLambda selects a (mu/mu_w, lambda)-ES with self-adaptive step sizes that
+// samples lambda offspring per generation and recombines the best mu.  If
+// lambda is zero, 4+3*ln(ndim) is used, and if mu is zero, lambda/2 is used.
+func MuLambda(mu, lambda int) Option {
+	return func(m *Method) {
+		m.Mu, m.Lambda = mu, lambda
+		m.comma = true
+	}
+}
+
+type Method struct {
+	// Mean is the parent position.  For the (1+1)-ES it is the best point
+	// found.
+	Mean []float64
+	// Sigma is the current (parent) step size.
+	Sigma float64
+	// Mu and Lambda are the number of parents and offspring for the
+	// (mu/mu_w, lambda)-ES.  Both are one for the (1+1)-ES.
+	Mu, Lambda int
+	// Tau is the learning rate for step size self-adaptation - 1/sqrt(2n) by
+	// default.
+	Tau float64
+	// Nsuccess counts the (1+1)-ES mutations that replaced the parent.
+	Nsuccess int
+	Db       *sql.DB
+
+	comma   bool
+	weights []float64
+	sigmas  []float64
+	parent  *optim.Point
+	best    *optim.Point
+	pop     []*optim.Point
+	ev      optim.Evaler
+	iter    int
+}
+
+// New creates an evolution strategy with its parent at start and initial
+// step size sigma.  If start's value is +infinity, it is evaluated on the
+// first iteration.
+func New(start *optim.Point, sigma float64, opts ...Option) *Method {
+	ndim := start.Len()
+	m := &Method{
+		Mean:   append([]float64{}, start.Pos...),
+		Sigma:  sigma,
+		Mu:     1,
+		Lambda: 1,
+		Tau:    1 / math.Sqrt(2*float64(ndim)),
+		parent: start.Clone(),
+		best:   start.Clone(),
+		ev:     optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.comma {
+		if m.Lambda <= 0 {
+			m.Lambda = 4 + int(3*math.Log(float64(ndim)))
+		}
+		if m.Mu <= 0 || m.Mu > m.Lambda {
+			m.Mu = m.Lambda / 2
+		}
+		if m.Mu < 1 {
+			m.Mu = 1
+		}
+		m.weights = make([]float64, m.Mu)
+		sum := 0.0
+		for i := range m.weights {
+			m.weights[i] = math.Log(float64(m.Mu)+0.5) - math.Log(float64(i+1))
+			sum += m.weights[i]
+		}
+		for i := range m.weights {
+			m.weights[i] /= sum
+		}
+	}
+
+	m.initdb()
+	return m
+}
+
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p.Clone()
+		if !m.comma {
+			m.parent = m.best
+			m.Mean = append([]float64{}, p.Pos...)
+		}
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+	if m.comma {
+		return m.iterateComma(obj, mesh)
+	}
+
+	child := m.sample(m.Mean, m.Sigma, mesh)
+	m.pop = []*optim.Point{child}
+	if math.IsInf(m.parent.Val, 1) {
+		m.parent.Pos = m.project(m.parent.Pos, mesh)
+		m.pop = append(m.pop, m.parent)
+	}
+	n, err = m.evaluate(obj, m.pop)
+	if err != nil {
+		return m.best, n, err
+	}
+
+	// the step size is stationary when a fraction TargetSuccess of the
+	// mutations succeed - damped by sqrt(n+1)
+	d := math.Sqrt(float64(len(m.Mean)) + 1)
+	if child.Val <= m.parent.Val {
+		m.Nsuccess++
+		m.parent = child
+		m.Mean = append([]float64{}, child.Pos...)
+		m.Sigma *= math.Exp(1 / d)
+	} else {
+		m.Sigma *= math.Exp(-TargetSuccess / (1 - TargetSuccess) / d)
+	}
+	if m.parent.Val < m.best.Val {
+		m.best = m.parent
+	}
+	return m.best, n, nil
+}
+
+func (m *Method) iterateComma(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.pop = make([]*optim.Point, m.Lambda)
+	m.sigmas = make([]float64, m.Lambda)
+	for k := range m.pop {
+		m.sigmas[k] = m.Sigma * math.Exp(m.Tau*optim.RandNorm())
+		m.pop[k] = m.sample(m.Mean, m.sigmas[k], mesh)
+	}
+
+	n, err = m.evaluate(obj, m.pop)
+	if err != nil {
+		return m.best, n, err
+	}
+
+	order := make([]int, m.Lambda)
+	for k := range order {
+		order[k] = k
+	}
+	sort.Sort(byval{order, m.pop})
+	if p := m.pop[order[0]]; p.Val < m.best.Val {
+		m.best = p
+	}
+
+	mean := make([]float64, len(m.Mean))
+	logsigma := 0.0
+	for i, w := range m.weights {
+		p := m.pop[order[i]]
+		for j := range mean {
+			mean[j] += w * p.Pos[j]
+		}
+		logsigma += w * math.Log(m.sigmas[order[i]])
+	}
+	m.Mean = mean
+	m.Sigma = math.Exp(logsigma)
+	return m.best, n, nil
+}
+
+// evaluate evaluates pts using the method's evaler.  Evalers skip duplicate
+// points - which are common on coarse or integer meshes - so their values
+// are shared with every copy.
+func (m *Method) evaluate(obj optim.Objectiver, pts []*optim.Point) (n int, err error) {
+	results, n, err := m.ev.Eval(obj, pts...)
+	vals := map[[sha1.Size]byte]float64{}
+	for _, p := range results {
+		vals[p.Hash()] = p.Val
+	}
+	for _, p := range pts {
+		if v, ok := vals[p.Hash()]; ok {
+			p.Val = v
+		}
+	}
+	return n, err
+}
+
+// sample returns an unevaluated point normally distributed around mean with
+// standard deviation sigma in each dimension.
+func (m *Method) sample(mean []float64, sigma float64, mesh optim.Mesh) *optim.Point {
+	pos := make([]float64, len(mean))
+	for i := range pos {
+		pos[i] = mean[i] + sigma*optim.RandNorm()
+	}
+	return &optim.Point{Pos: m.project(pos, mesh), Val: math.Inf(1)}
+}
+
+func (m *Method) project(pos []float64, mesh optim.Mesh) []float64 {
+	if mesh == nil {
+		return pos
+	}
+	return mesh.Nearest(pos)
+}
+
+type byval struct {
+	order []int
+	pop   []*optim.Point
+}
+
+func (b byval) Len() int           { return len(b.order) }
+func (b byval) Less(i, j int) bool { return b.pop[b.order[i]].Val < b.pop[b.order[j]].Val }
+func (b byval) Swap(i, j int)      { b.order[i], b.order[j] = b.order[j], b.order[i] }
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblIter + " (iter INTEGER,sigma REAL,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblIter + " (iter,sigma,val,posid) VALUES (?,?,?,?);"
+	_, err = tx.Exec(s, m.iter, m.Sigma, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.best)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("es: db write failed -", err)
+		return true
+	}
+	return false
+}
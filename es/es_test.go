@@ -0,0 +1,154 @@
+package es
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+var sphere = optim.Func(func(x []float64) float64 {
+	tot := 0.0
+	for _, v := range x {
+		tot += v * v
+	}
+	return tot
+})
+
+func start(ndim int, v float64) *optim.Point {
+	pos := make([]float64, ndim)
+	for i := range pos {
+		pos[i] = v
+	}
+	return &optim.Point{Pos: pos, Val: math.Inf(1)}
+}
+
+func TestOnePlusOne(t *testing.T) {
+	m := New(start(10, 3), 1)
+	solv := &optim.Solver{Method: m, Obj: sphere, Mesh: &optim.InfMesh{}, MaxEval: 20000}
+	for solv.Next() && solv.Best().Val > 1e-10 {
+	}
+
+	rate := float64(m.Nsuccess) / float64(solv.Niter())
+	t.Logf("[INFO] %v evals, success rate %.3f: got %v", solv.Neval(), rate, solv.Best().Val)
+	if solv.Best().Val > 1e-10 {
+		t.Errorf("want < 1e-10, got %v", solv.Best().Val)
+	}
+	// the 1/5th rule keeps the success rate near its target
+	if rate < 0.1 || rate > 0.3 {
+		t.Errorf("success rate: want about %v, got %v", TargetSuccess, rate)
+	}
+}
+
+func TestMuLambda(t *testing.T) {
+	m := New(start(10, 3), 1, MuLambda(5, 20), Evaler(optim.ParallelEvaler{}))
+	mesh := &optim.InfMesh{}
+	_, n, err := m.Iterate(sphere, mesh)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 20 {
+		t.Errorf("first generation: want 20 evals, got %v", n)
+	}
+
+	solv := &optim.Solver{Method: m, Obj: sphere, Mesh: mesh, MaxEval: 40000}
+	for solv.Next() && solv.Best().Val > 1e-10 {
+	}
+	t.Logf("[INFO] %v evals: got %v, sigma %v", solv.Neval(), solv.Best().Val, m.Sigma)
+	if solv.Best().Val > 1e-10 {
+		t.Errorf("want < 1e-10, got %v", solv.Best().Val)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	m := New(start(10, 0), 1, MuLambda(0, 0))
+	if want := 4 + int(3*math.Log(10)); m.Lambda != want || m.Mu != want/2 {
+		t.Errorf("want (%v, %v), got (%v, %v)", want/2, want, m.Mu, m.Lambda)
+	}
+	sum := 0.0
+	for i, w := range m.weights {
+		sum += w
+		if i > 0 && w >= m.weights[i-1] {
+			t.Errorf("weights not decreasing: %v", m.weights)
+		}
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("weights sum to %v, want 1", sum)
+	}
+}
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 2}
+	for _, opts := range [][]Option{nil, {MuLambda(3, 12)}} {
+		m := New(start(2, -1), 0.5, opts...)
+		solv := &optim.Solver{Method: m, Obj: optim.Func(fn.Eval), Mesh: &optim.InfMesh{}, MaxEval: 50000}
+		for solv.Next() && solv.Best().Val > 1e-6 {
+		}
+		t.Logf("[INFO] (%v,%v): %v evals: got %v", m.Mu, m.Lambda, solv.Neval(), solv.Best().Val)
+		if solv.Best().Val > 1e-6 {
+			t.Errorf("(%v,%v): want < 1e-6, got %v", m.Mu, m.Lambda, solv.Best().Val)
+		}
+	}
+}
+
+func TestIntMesh(t *testing.T) {
+	obj := optim.Func(func(x []float64) float64 {
+		a, b := x[0]-3.3, x[1]+7.8
+		return a*a + b*b
+	})
+	for _, opts := range [][]Option{nil, {MuLambda(0, 0)}} {
+		m := New(start(2, 20), 5, opts...)
+		mesh := &optim.IntMesh{Mesh: &optim.InfMesh{StepSize: 1}}
+		solv := &optim.Solver{Method: m, Obj: obj, Mesh: mesh, MaxEval: 2000}
+		for solv.Next() && solv.Best().Val > 0.14 {
+		}
+
+		want := []float64{3, -8}
+		if got := solv.Best().Pos; got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("(%v,%v): want %v, got %v", m.Mu, m.Lambda, want, got)
+		}
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	// most offspring round to the same few integer points
+	obj := optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1]*x[1] })
+	m := New(start(2, 0), 0.5, MuLambda(0, 0))
+	mesh := &optim.IntMesh{Mesh: &optim.InfMesh{StepSize: 1}}
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Iterate(obj, mesh); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.pop {
+			if want, _ := obj.Objective(p.Pos); p.Val != want {
+				t.Fatalf("iter %v: want %v for offspring %v, got %v", i, want, p.Pos, p.Val)
+			}
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	solv := &optim.Solver{
+		Method:  New(start(3, 1), 0.5, DB(db), MuLambda(0, 0)),
+		Obj:     sphere,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblIter).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblIter, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblIter, count, solv.Niter())
+	}
+}
@@ -0,0 +1,150 @@
+package optim
+
+import (
+	"errors"
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// ErrInfeasible is returned when no point satisfying a set of constraints
+// can be found.
+var ErrInfeasible = errors.New("optim: constraints are infeasible")
+
+// ErrNotConverged is returned when the search for a feasible point stops
+// before finding one without having proven the constraints infeasible.
+var ErrNotConverged = errors.New("optim: feasible point search did not converge")
+
+// interiorMargins are the fractions of the box bounds' size tried (largest
+// first) as minimum distances from constraint boundaries when searching for
+// an interior point.
+var interiorMargins = []float64{1e-2, 1e-4, 1e-6, 0}
+
+// FeasiblePoint finds a point satisfying the box bounds lb <= x <= ub and
+// the linear constraints low <= Ax <= up.  A may be nil for box bounds only.
+// The point is found by projecting the center of the box onto the polytope
+// (see PolyMesh) tightened such that the point lies in the polytope's
+// interior where possible.  ErrInfeasible is returned if the constraints are
+// proven infeasible and ErrNotConverged if no feasible point is found
+// otherwise.
+func FeasiblePoint(lb, ub []float64, low, A, up *mat64.Dense) ([]float64, error) {
+	poly := &PolyMesh{Mesh: &InfMesh{}, A: A, Low: low, Up: up, Lower: lb, Upper: ub}
+	return interiorPoint(poly, lb, ub)
+}
+
+func interiorPoint(poly *PolyMesh, lb, ub []float64) ([]float64, error) {
+	poly.init()
+	center := make([]float64, len(lb))
+	scale := 0.0
+	for i := range lb {
+		center[i] = (lb[i] + ub[i]) / 2
+		scale = math.Max(scale, ub[i]-lb[i])
+	}
+
+	var lambda []float64
+	for _, frac := range interiorMargins {
+		margins := make([]float64, len(poly.rows))
+		for i := range margins {
+			margins[i] = frac * scale * math.Sqrt(poly.norm2[i])
+		}
+		var x []float64
+		x, lambda = poly.project(center, margins)
+		if poly.Feasible(x) {
+			return x, nil
+		}
+	}
+
+	// lambda holds the multipliers of the untightened projection.
+	if farkas(poly, lambda, lb, ub) {
+		return nil, ErrInfeasible
+	}
+	return nil, ErrNotConverged
+}
+
+// farkas returns true if the constraint multipliers lambda prove the
+// polytope empty.  Every feasible x satisfies r*x <= y where
+// r = sum(lambda[i]*rows[i]) and y = sum(lambda[i]*b[i]) - so if r*x > y for
+// every x inside the box bounds lb, ub, no feasible point exists.
+func farkas(poly *PolyMesh, lambda, lb, ub []float64) bool {
+	r := make([]float64, len(lb))
+	y, mag := 0.0, 0.0
+	for i, row := range poly.rows {
+		for j, a := range row {
+			r[j] += lambda[i] * a
+		}
+		y += lambda[i] * poly.b[i]
+		mag += math.Abs(lambda[i] * poly.b[i])
+	}
+
+	min := 0.0
+	for j := range r {
+		min += math.Min(r[j]*lb[j], r[j]*ub[j])
+	}
+	return min-y > 1e-9*mag
+}
+
+// FeasiblePop generates n points uniformly distributed inside the polytope
+// defined by the box bounds lb <= x <= ub and the linear constraints
+// low <= Ax <= up.  A may be nil for box bounds only.  Points are sampled
+// using a hit-and-run random walk started from FeasiblePoint.  The walk
+// takes HitAndRunBurn*ndim steps before the first sample and
+// HitAndRunThin*ndim steps between samples.  Returned points have their
+// values initialized to +infinity.  Errors are returned as for
+// FeasiblePoint.  If the polytope has no interior, all points are identical.
+func FeasiblePop(n int, lb, ub []float64, low, A, up *mat64.Dense) ([]*Point, error) {
+	if len(lb) != len(ub) {
+		panic("lb and ub vectors are not same length")
+	}
+
+	poly := &PolyMesh{Mesh: &InfMesh{}, A: A, Low: low, Up: up, Lower: lb, Upper: ub}
+	x, err := interiorPoint(poly, lb, ub)
+	if err != nil {
+		return nil, err
+	}
+
+	ndim := len(lb)
+	points := make([]*Point, 0, n)
+	for i := 0; len(points) < n; i++ {
+		hitAndRun(poly, x)
+		if i >= HitAndRunBurn*ndim && (i-HitAndRunBurn*ndim)%(HitAndRunThin*ndim) == 0 {
+			points = append(points, &Point{Pos: append([]float64{}, x...), Val: math.Inf(1)})
+		}
+	}
+	return points, nil
+}
+
+// Hit-and-run walk lengths used by FeasiblePop (multiplied by the number of
+// dimensions).
+var (
+	HitAndRunBurn = 100
+	HitAndRunThin = 10
+)
+
+// hitAndRun moves x (in-place) to a uniformly distributed random location on
+// the chord through x in a random direction inside poly.
+func hitAndRun(poly *PolyMesh, x []float64) {
+	// random direction uniformly distributed on the unit sphere
+	d := make([]float64, len(x))
+	for i := range d {
+		d[i] = RandNorm()
+	}
+
+	tmin, tmax := math.Inf(-1), math.Inf(1)
+	for i, row := range poly.rows {
+		ad := dot(row, d)
+		slack := math.Max(0, poly.b[i]-dot(row, x))
+		if ad > 1e-14 {
+			tmax = math.Min(tmax, slack/ad)
+		} else if ad < -1e-14 {
+			tmin = math.Max(tmin, slack/ad)
+		}
+	}
+	if math.IsInf(tmin, 0) || math.IsInf(tmax, 0) || tmax <= tmin {
+		return
+	}
+
+	t := tmin + RandFloat()*(tmax-tmin)
+	for i := range x {
+		x[i] += t * d[i]
+	}
+}
@@ -0,0 +1,86 @@
+package optim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestFeasiblePoint(t *testing.T) {
+	lb := []float64{-10, -10}
+	ub := []float64{10, 10}
+
+	// x + y >= 15 excludes the box center
+	A := mat64.NewDense(1, 2, []float64{1, 1})
+	low := mat64.NewDense(1, 1, []float64{15})
+	up := mat64.NewDense(1, 1, []float64{100})
+
+	x, err := FeasiblePoint(lb, ub, low, A, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x[0]+x[1] < 15 || x[0] > 10 || x[1] > 10 {
+		t.Errorf("infeasible point %v", x)
+	}
+
+	// x + y >= 25 can't be satisfied inside the box
+	low = mat64.NewDense(1, 1, []float64{25})
+	if _, err := FeasiblePoint(lb, ub, low, A, up); err != ErrInfeasible {
+		t.Errorf("want ErrInfeasible, got %v", err)
+	}
+}
+
+func TestFeasiblePop(t *testing.T) {
+	lb := []float64{0, 0}
+	ub := []float64{1, 1}
+
+	// sample the triangle x + y <= 1
+	A := mat64.NewDense(1, 2, []float64{1, 1})
+	low := mat64.NewDense(1, 1, []float64{-1})
+	up := mat64.NewDense(1, 1, []float64{1})
+
+	n := 2000
+	pop, err := FeasiblePop(n, lb, ub, low, A, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pop) != n {
+		t.Fatalf("want %v points, got %v", n, len(pop))
+	}
+
+	mean := make([]float64, 2)
+	for _, p := range pop {
+		if !math.IsInf(p.Val, 1) {
+			t.Fatalf("point's initial value is not infinity")
+		}
+		x, y := p.Pos[0], p.Pos[1]
+		if x < -1e-9 || y < -1e-9 || x+y > 1+1e-9 {
+			t.Fatalf("infeasible point %v", p.Pos)
+		}
+		mean[0] += x / float64(n)
+		mean[1] += y / float64(n)
+	}
+
+	// the triangle's centroid is (1/3, 1/3)
+	for i, m := range mean {
+		if math.Abs(m-1.0/3) > 0.03 {
+			t.Errorf("mean[%v]: want %v, got %v", i, 1.0/3, m)
+		}
+	}
+}
+
+func TestFeasiblePointNotConverged(t *testing.T) {
+	lb := []float64{-10, -10}
+	ub := []float64{10, 10}
+
+	// y >= 0 and y <= 0.001*(x - 9) form a feasible sliver that alternating
+	// projections from the box center approach too slowly to reach
+	A := mat64.NewDense(2, 2, []float64{0, 1, -0.001, 1})
+	low := mat64.NewDense(2, 1, []float64{0, -100})
+	up := mat64.NewDense(2, 1, []float64{100, -0.009})
+
+	if _, err := FeasiblePoint(lb, ub, low, A, up); err != ErrNotConverged {
+		t.Errorf("want ErrNotConverged, got %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+// Package ga provides a generational genetic algorithm supporting real,
+// integer and categorical variables.  Real variables use simulated binary
+// crossover (SBX) and polynomial mutation as described in:
+//
+//     Deb, Kalyanmoy, and Ram Bhushan Agrawal. "Simulated binary crossover for
+//     continuous search space." Complex Systems 9.2 (1995): 115-148.
+//
+//     Deb, Kalyanmoy, and Mayank Goyal. "A combined genetic adaptive search
+//     (GeneAS) for engineering design." Computer Science and Informatics 26.4
+//     (1996): 30-45.
+//
+// Selection, crossover and mutation operators can be replaced by custom
+// implementations of the Selector, Crossover and Mutator interfaces.
+package ga
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblPop is the name of the sql database table that contains positions
+	// and values for population members for each iteration.
+	TblPop = "gapop"
+	// TblBest is the name of the sql database table that contains the best
+	// position for the entire population at each iteration.
+	TblBest = "gabest"
+)
+
+const (
+	DefaultCrossProb  = 0.9
+	DefaultEtaCross   = 15
+	DefaultEtaMutate  = 20
+	DefaultTournament = 2
+	DefaultElite      = 1
+)
+
+// VarType specifies the kind of values a variable can take.
+type VarType int
+
+const (
+	// Real variables take any value between Low and Up.
+	Real VarType = iota
+	// Integer variables take integer values between Low and Up.  Values are
+	// ordered, so nearby values are considered similar.
+	Integer
+	// Categorical variables take integer values between Low and Up that
+	// identify unordered categories.
+	Categorical
+)
+
+// Var describes a single optimization variable.
+type Var struct {
+	Type    VarType
+	Low, Up float64
+}
+
+// Reals returns real variable descriptions for the given bounds.
+func Reals(low, up []float64) []Var {
+	vars := make([]Var, len(low))
+	for i := range vars {
+		vars[i] = Var{Real, low[i], up[i]}
+	}
+	return vars
+}
+
+// Repair moves x (in-place) inside the variable bounds and rounds integer
+// and categorical variables.
+func Repair(x []float64, vars []Var) {
+	for i, v := range vars {
+		if v.Type != Real {
+			x[i] = math.Floor(x[i] + 0.5)
+		}
+		x[i] = math.Min(v.Up, math.Max(v.Low, x[i]))
+	}
+}
+
+// RandPop returns n points with uniformly distributed random values for each
+// variable.  Point values are initialized to +infinity.
+func RandPop(n int, vars []Var) []*optim.Point {
+	pop := make([]*optim.Point, n)
+	for i := range pop {
+		pos := make([]float64, len(vars))
+		for j, v := range vars {
+			if v.Type == Real {
+				pos[j] = v.Low + optim.RandFloat()*(v.Up-v.Low)
+			} else {
+				pos[j] = v.Low + float64(optim.Rand.Intn(int(v.Up-v.Low)+1))
+			}
+		}
+		pop[i] = &optim.Point{Pos: pos, Val: math.Inf(1)}
+	}
+	return pop
+}
+
+// Selector chooses parents for reproduction.
+type Selector interface {
+	// Select returns a parent chosen from pop.
+	Select(pop []*optim.Point) *optim.Point
+}
+
+// Crossover combines two parents into two children.
+type Crossover interface {
+	// Cross returns two children created from parents x1 and x2.  The
+	// parents must not be modified.
+	Cross(x1, x2 []float64, vars []Var) (c1, c2 []float64)
+}
+
+// Mutator randomly perturbs individuals.
+type Mutator interface {
+	// Mutate modifies x in-place.
+	Mutate(x []float64, vars []Var)
+}
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Select sets the parent selection operator.
+func Select(s Selector) Option { return func(m *Method) { m.Selector = s } }
+
+// Cross sets the crossover operator.
+func Cross(c Crossover) Option { return func(m *Method) { m.Crossover = c } }
+
+// Mutate sets the mutation operator.
+func Mutate(mu Mutator) Option { return func(m *Method) { m.Mutator = mu } }
+
+// Elite sets the number of best individuals copied unchanged into each new
+// generation.
+func Elite(n int) Option { return func(m *Method) { m.Elite = n } }
+
+type Method struct {
+	Vars      []Var
+	Pop       []*optim.Point
+	Selector  Selector
+	Crossover Crossover
+	Mutator   Mutator
+	// Elite is the number of best individuals that survive unchanged into
+	// the next generation.
+	Elite int
+	Db    *sql.DB
+	ev    optim.Evaler
+	best  *optim.Point
+	iter  int
+}
+
+// New creates a genetic algorithm for the variables described by vars with
+// the given initial population (e.g. from RandPop).  Members with infinite
+// values are evaluated on the first iteration.  By default, binary
+// tournament selection, MixedCrossover and MixedMutation are used.
+func New(vars []Var, pop []*optim.Point, opts ...Option) *Method {
+	m := &Method{
+		Vars:      vars,
+		Pop:       pop,
+		Selector:  Tournament{DefaultTournament},
+		Crossover: MixedCrossover{Prob: DefaultCrossProb, Eta: DefaultEtaCross},
+		Mutator:   MixedMutation{Prob: 1 / float64(len(vars)), Eta: DefaultEtaMutate},
+		Elite:     DefaultElite,
+		ev:        optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.best = pop[0]
+	for _, p := range pop {
+		if p.Val < m.best.Val {
+			m.best = p
+		}
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint replaces the worst member of the population with p if p is better
+// than the population's best member.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val >= m.best.Val {
+		return
+	}
+	m.best = p
+	worst := 0
+	for i, member := range m.Pop {
+		if member.Val > m.Pop[worst].Val {
+			worst = i
+		}
+	}
+	m.Pop[worst] = p.Clone()
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	// evaluate the initial population
+	unknown := []*optim.Point{}
+	for _, p := range m.Pop {
+		if math.IsInf(p.Val, 1) {
+			p.Pos = m.project(p.Pos, mesh)
+			unknown = append(unknown, p)
+		}
+	}
+	if len(unknown) > 0 {
+		n, err = m.evaluate(obj, unknown)
+		m.updateBest()
+		return m.best, n, err
+	}
+
+	ranked := sorted(m.Pop)
+	nelite := m.Elite
+	if nelite > len(ranked) {
+		nelite = len(ranked)
+	}
+	next := append([]*optim.Point{}, ranked[:nelite]...)
+
+	children := []*optim.Point{}
+	for len(next)+len(children) < len(m.Pop) {
+		p1, p2 := m.Selector.Select(m.Pop), m.Selector.Select(m.Pop)
+		c1, c2 := m.Crossover.Cross(p1.Pos, p2.Pos, m.Vars)
+		for _, c := range [][]float64{c1, c2} {
+			if len(next)+len(children) == len(m.Pop) {
+				break
+			}
+			m.Mutator.Mutate(c, m.Vars)
+			children = append(children, &optim.Point{Pos: m.project(c, mesh), Val: math.Inf(1)})
+		}
+	}
+
+	n, err = m.evaluate(obj, children)
+	m.Pop = append(next, children...)
+	m.updateBest()
+	return m.best, n, err
+}
+
+// evaluate evaluates pts using the method's evaler.  Evalers skip duplicate
+// points - which are common on coarse or integer meshes - so their values
+// are shared with every copy.
+func (m *Method) evaluate(obj optim.Objectiver, pts []*optim.Point) (n int, err error) {
+	results, n, err := m.ev.Eval(obj, pts...)
+	vals := map[[sha1.Size]byte]float64{}
+	for _, p := range results {
+		vals[p.Hash()] = p.Val
+	}
+	for _, p := range pts {
+		if v, ok := vals[p.Hash()]; ok {
+			p.Val = v
+		}
+	}
+	return n, err
+}
+
+// project repairs x and projects it onto the mesh.
+func (m *Method) project(x []float64, mesh optim.Mesh) []float64 {
+	pos := append([]float64{}, x...)
+	Repair(pos, m.Vars)
+	if mesh != nil {
+		pos = mesh.Nearest(pos)
+	}
+	return pos
+}
+
+func (m *Method) updateBest() {
+	for _, p := range m.Pop {
+		if p.Val < m.best.Val {
+			m.best = p
+		}
+	}
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblPop + " (member INTEGER, iter INTEGER, val REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblBest + " (iter INTEGER, val REAL, posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s0, err := tx.Prepare("INSERT INTO " + TblPop + " (member,iter,val,posid) VALUES (?,?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+	for i, p := range m.Pop {
+		_, err := s0.Exec(i, m.iter, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+	}
+
+	s1 := "INSERT INTO " + TblBest + " (iter,val,posid) VALUES (?,?,?);"
+	_, err = tx.Exec(s1, m.iter, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	pts := append([]*optim.Point{}, m.Pop...)
+	pts = append(pts, m.best)
+	err = optim.RecordPointPos(tx, pts...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+// sorted returns a copy of pop sorted from best to worst.
+func sorted(pop []*optim.Point) []*optim.Point {
+	s := append([]*optim.Point{}, pop...)
+	for i := 1; i < len(s); i++ {
+		for j := i; j > 0 && s[j].Val < s[j-1].Val; j-- {
+			s[j], s[j-1] = s[j-1], s[j]
+		}
+	}
+	return s
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("ga: db write failed -", err)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package ga
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestReal(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 2}
+	low, up := fn.Bounds()
+	vars := Reals(low, up)
+	solv := &optim.Solver{
+		Method:  New(vars, RandPop(50, vars)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 20000,
+	}
+	for solv.Next() {
+		if solv.Best().Val < 1e-3 {
+			break
+		}
+	}
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best().Val)
+	if solv.Best().Val > 1e-3 {
+		t.Errorf("want < 1e-3, got %v", solv.Best().Val)
+	}
+}
+
+// mixed has a real, an integer and a categorical variable with the optimum
+// at (0.5, 3, 2).
+func mixed(x []float64) float64 {
+	cat := []float64{4, 2, 0, 3}
+	return (x[0]-0.5)*(x[0]-0.5) + (x[1]-3)*(x[1]-3) + cat[int(x[2])]
+}
+
+func TestMixed(t *testing.T) {
+	vars := []Var{{Real, -5, 5}, {Integer, -10, 10}, {Categorical, 0, 3}}
+	invalid := 0
+	obj := optim.Func(func(x []float64) float64 {
+		if x[1] != math.Floor(x[1]) || x[2] != math.Floor(x[2]) || x[2] < 0 || x[2] > 3 {
+			invalid++
+			return math.Inf(1)
+		}
+		return mixed(x)
+	})
+
+	solv := &optim.Solver{
+		Method:  New(vars, RandPop(30, vars)),
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 100,
+	}
+	solv.Run()
+
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), best)
+	if invalid > 0 {
+		t.Errorf("%v points evaluated with invalid integer/categorical values", invalid)
+	}
+	if best.Pos[1] != 3 || best.Pos[2] != 2 || math.Abs(best.Pos[0]-0.5) > 1e-2 {
+		t.Errorf("want [0.5 3 2], got %v", best.Pos)
+	}
+}
+
+func TestMutate(t *testing.T) {
+	vars := []Var{{Real, 0, 1}, {Integer, 0, 5}, {Categorical, 0, 2}}
+	mu := MixedMutation{Prob: 1, Eta: DefaultEtaMutate}
+	for i := 0; i < 1000; i++ {
+		x := []float64{0.5, 5, 1}
+		mu.Mutate(x, vars)
+		if x[0] < 0 || x[0] > 1 {
+			t.Fatalf("real variable out of bounds: %v", x[0])
+		}
+		if x[1] == 5 || x[1] < 0 || x[1] != math.Floor(x[1]) {
+			t.Fatalf("bad integer mutation 5 -> %v", x[1])
+		}
+		if x[2] == 1 || (x[2] != 0 && x[2] != 2) {
+			t.Fatalf("bad categorical mutation 1 -> %v", x[2])
+		}
+	}
+}
+
+func TestElite(t *testing.T) {
+	vars := Reals([]float64{-5, -5}, []float64{5, 5})
+	m := New(vars, RandPop(20, vars), Elite(3))
+	obj := optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1]*x[1] })
+	mesh := &optim.InfMesh{}
+
+	prev := math.Inf(1)
+	for i := 0; i < 30; i++ {
+		best, _, err := m.Iterate(obj, mesh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if best.Val > prev {
+			t.Fatalf("iter %v: best value got worse: %v -> %v", i, prev, best.Val)
+		}
+		prev = best.Val
+
+		found := false
+		for _, p := range m.Pop {
+			found = found || p.Val == best.Val
+		}
+		if !found {
+			t.Fatalf("iter %v: best point not kept in population", i)
+		}
+	}
+}
+
+func TestMesh(t *testing.T) {
+	vars := Reals([]float64{-5, -5}, []float64{5, 5})
+	mesh := &optim.InfMesh{StepSize: 0.25}
+	offmesh := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for _, v := range x {
+			if v/0.25 != math.Floor(v/0.25) {
+				offmesh++
+			}
+		}
+		return x[0]*x[0] + x[1]*x[1]
+	})
+
+	solv := &optim.Solver{
+		Method:  New(vars, RandPop(20, vars)),
+		Obj:     obj,
+		Mesh:    mesh,
+		MaxIter: 20,
+	}
+	solv.Run()
+
+	if offmesh > 0 {
+		t.Errorf("%v coordinates evaluated off the mesh", offmesh)
+	}
+	if solv.Best().Val != 0 {
+		t.Errorf("want 0, got %v", solv.Best().Val)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	// identical children are common with few integer values
+	vars := []Var{{Integer, 0, 2}, {Integer, 0, 2}}
+	obj := optim.Func(func(x []float64) float64 { return x[0] + x[1] })
+	m := New(vars, RandPop(20, vars))
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Iterate(obj, &optim.InfMesh{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.Pop {
+			if want, _ := obj.Objective(p.Pos); p.Val != want {
+				t.Fatalf("iter %v: want %v for member %v, got %v", i, want, p.Pos, p.Val)
+			}
+		}
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	vars := Reals(fn.Bounds())
+	solv := &optim.Solver{
+		Method:  New(vars, RandPop(20, vars), DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblPop, TblBest} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
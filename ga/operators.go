@@ -0,0 +1,142 @@
+package ga
+
+import (
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// Tournament selects the best of Size randomly chosen population members.
+type Tournament struct {
+	Size int
+}
+
+func (t Tournament) Select(pop []*optim.Point) *optim.Point {
+	best := pop[optim.Rand.Intn(len(pop))]
+	for i := 1; i < t.Size; i++ {
+		p := pop[optim.Rand.Intn(len(pop))]
+		if p.Val < best.Val {
+			best = p
+		}
+	}
+	return best
+}
+
+// MixedCrossover applies SBX crossover to real and integer variables and
+// uniform crossover to categorical variables.  Parents are recombined with
+// probability Prob - otherwise the children are copies of the parents.  Eta
+// is the SBX distribution index - larger values create children closer to
+// their parents.
+type MixedCrossover struct {
+	Prob float64
+	Eta  float64
+}
+
+func (c MixedCrossover) Cross(x1, x2 []float64, vars []Var) (c1, c2 []float64) {
+	c1 = append([]float64{}, x1...)
+	c2 = append([]float64{}, x2...)
+	if optim.RandFloat() >= c.Prob {
+		return c1, c2
+	}
+
+	for i, v := range vars {
+		if v.Type == Categorical {
+			if optim.RandFloat() < 0.5 {
+				c1[i], c2[i] = c2[i], c1[i]
+			}
+			continue
+		}
+		if optim.RandFloat() < 0.5 {
+			c1[i], c2[i] = sbx(x1[i], x2[i], v.Low, v.Up, c.Eta)
+		}
+	}
+	return c1, c2
+}
+
+// sbx performs bounded simulated binary crossover on a single variable.
+func sbx(a, b, low, up, eta float64) (float64, float64) {
+	if math.Abs(a-b) < 1e-14 {
+		return a, b
+	}
+	y1, y2 := math.Min(a, b), math.Max(a, b)
+	u := optim.RandFloat()
+
+	child := func(beta float64) float64 {
+		alpha := 2 - math.Pow(beta, -(eta+1))
+		var betaq float64
+		if u <= 1/alpha {
+			betaq = math.Pow(u*alpha, 1/(eta+1))
+		} else {
+			betaq = math.Pow(1/(2-u*alpha), 1/(eta+1))
+		}
+		return betaq
+	}
+
+	betaq := child(1 + 2*(y1-low)/(y2-y1))
+	ch1 := 0.5 * ((y1 + y2) - betaq*(y2-y1))
+	betaq = child(1 + 2*(up-y2)/(y2-y1))
+	ch2 := 0.5 * ((y1 + y2) + betaq*(y2-y1))
+
+	ch1 = math.Min(up, math.Max(low, ch1))
+	ch2 = math.Min(up, math.Max(low, ch2))
+	if optim.RandFloat() < 0.5 {
+		ch1, ch2 = ch2, ch1
+	}
+	return ch1, ch2
+}
+
+// MixedMutation mutates each variable with probability Prob.  Real variables
+// use polynomial mutation with distribution index Eta.  Integer variables
+// also use polynomial mutation but always move by at least one.  Categorical
+// variables are reset to a different, uniformly chosen category.
+type MixedMutation struct {
+	Prob float64
+	Eta  float64
+}
+
+func (mu MixedMutation) Mutate(x []float64, vars []Var) {
+	for i, v := range vars {
+		if optim.RandFloat() >= mu.Prob || v.Up <= v.Low {
+			continue
+		}
+
+		switch v.Type {
+		case Real:
+			x[i] = polymut(x[i], v.Low, v.Up, mu.Eta)
+		case Integer:
+			y := math.Floor(polymut(x[i], v.Low, v.Up, mu.Eta) + 0.5)
+			if y == x[i] {
+				if (optim.RandFloat() < 0.5 && y > v.Low) || y == v.Up {
+					y--
+				} else {
+					y++
+				}
+			}
+			x[i] = y
+		case Categorical:
+			n := int(v.Up-v.Low) + 1
+			k := optim.Rand.Intn(n - 1)
+			if v.Low+float64(k) >= x[i] {
+				k++
+			}
+			x[i] = v.Low + float64(k)
+		}
+	}
+}
+
+// polymut performs bounded polynomial mutation on a single variable.
+func polymut(y, low, up, eta float64) float64 {
+	d1, d2 := (y-low)/(up-low), (up-y)/(up-low)
+	u := optim.RandFloat()
+	pow := 1 / (eta + 1)
+
+	var dq float64
+	if u < 0.5 {
+		val := 2*u + (1-2*u)*math.Pow(1-d1, eta+1)
+		dq = math.Pow(val, pow) - 1
+	} else {
+		val := 2*(1-u) + 2*(u-0.5)*math.Pow(1-d2, eta+1)
+		dq = 1 - math.Pow(val, pow)
+	}
+	return math.Min(up, math.Max(low, y+dq*(up-low)))
+}
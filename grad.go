@@ -0,0 +1,327 @@
+package optim
+
+import (
+	"errors"
+	"math"
+)
+
+// Gradienter is an optional interface for objectives that can compute their
+// own gradient.  Gradient-based methods detect it with a type assertion and
+// fall back to finite differences otherwise.
+type Gradienter interface {
+	// Gradient returns the partial derivatives of the objective function at
+	// v.  If the evaluation fails, an error should be returned.
+	Gradient(v []float64) ([]float64, error)
+}
+
+// ObjectiveGradienter is an optional interface for objectives that can
+// compute their value and gradient together more cheaply than separately
+// (e.g. by reusing intermediate results or via adjoint methods).
+type ObjectiveGradienter interface {
+	// ObjectiveGradient returns the objective value and its partial
+	// derivatives at v.  If the evaluation fails, an error should be
+	// returned.
+	ObjectiveGradient(v []float64) (float64, []float64, error)
+}
+
+// Hessianer is an optional interface for objectives that can compute their
+// own matrix of second partial derivatives.
+type Hessianer interface {
+	// Hessian returns the symmetric matrix of second partial derivatives of
+	// the objective function at v with h[i][j] = d2f/dv_i dv_j.  If the
+	// evaluation fails, an error should be returned.
+	Hessian(v []float64) ([][]float64, error)
+}
+
+// Default finite difference steps relative to max(1, |v[i]|).  These are
+// approximately eps^(1/2), eps^(1/3) and eps^(1/4) for machine epsilon eps
+// which balance truncation and roundoff error for each formula.
+const (
+	FDForward = 1.5e-8
+	FDCentral = 6e-6
+	FDHessian = 1.2e-4
+)
+
+var ErrNoGradient = errors.New("optim: objective does not provide a gradient")
+
+// Grad returns the gradient of obj at v using the Gradienter or
+// ObjectiveGradienter interface if obj implements either and central finite
+// differences otherwise.
+func Grad(obj Objectiver, v []float64) ([]float64, error) {
+	if hasGrad(obj) {
+		return analyticGrad(obj, v)
+	}
+	return FiniteDiff{Obj: obj, Central: true}.Gradient(v)
+}
+
+func hasGrad(obj Objectiver) bool {
+	switch obj.(type) {
+	case Gradienter, ObjectiveGradienter:
+		return true
+	}
+	return false
+}
+
+func analyticGrad(obj Objectiver, v []float64) ([]float64, error) {
+	switch o := obj.(type) {
+	case Gradienter:
+		return o.Gradient(v)
+	case ObjectiveGradienter:
+		_, g, err := o.ObjectiveGradient(v)
+		return g, err
+	}
+	return nil, ErrNoGradient
+}
+
+// FuncGrad is an Objectiver with an analytic gradient built from plain
+// functions.  Obj and Grad are always called separately.
+type FuncGrad struct {
+	Obj  func([]float64) float64
+	Grad func([]float64) []float64
+}
+
+func (f FuncGrad) Objective(v []float64) (float64, error) { return f.Obj(v), nil }
+
+func (f FuncGrad) Gradient(v []float64) ([]float64, error) { return f.Grad(v), nil }
+
+func (f FuncGrad) ObjectiveGradient(v []float64) (float64, []float64, error) {
+	return f.Obj(v), f.Grad(v), nil
+}
+
+// FiniteDiff wraps an objective adding Gradienter, ObjectiveGradienter and
+// Hessianer implementations computed by finite differences.  All points
+// required for a derivative are evaluated together in a single batch with
+// Ev, so a ParallelEvaler can compute them concurrently.
+type FiniteDiff struct {
+	Obj Objectiver
+	// Ev evaluates the finite difference points.  If nil, a SerialEvaler is
+	// used.
+	Ev Evaler
+	// Step is the difference step relative to max(1, |v[i]|).  If zero,
+	// FDForward, FDCentral or FDHessian is used as appropriate.
+	Step float64
+	// Central selects central differences for gradients - twice as many
+	// evaluations as forward differences but much more accurate.
+	Central bool
+	// Low and Up optionally bound the points used for gradients.  Either
+	// may be nil.
+	Low, Up []float64
+}
+
+func (f FiniteDiff) Objective(v []float64) (float64, error) { return f.Obj.Objective(v) }
+
+func (f FiniteDiff) Gradient(v []float64) ([]float64, error) {
+	_, g, err := f.ObjectiveGradient(v)
+	return g, err
+}
+
+func (f FiniteDiff) ObjectiveGradient(v []float64) (float64, []float64, error) {
+	center := &Point{Pos: append([]float64{}, v...), Val: math.Inf(1)}
+	g, _, err := f.PointGradient(center)
+	if err != nil {
+		return math.Inf(1), nil, err
+	}
+	return center.Val, g, nil
+}
+
+// PointGradient returns the gradient at p and the number of evaluations
+// performed.  If p's value is +infinity, p is evaluated in the same batch as
+// the difference points - otherwise its value is reused.  Where a step would
+// cross Low or Up, a one-sided difference pointing into the bounds is used.
+func (f FiniteDiff) PointGradient(p *Point) (g []float64, n int, err error) {
+	step := f.Step
+	if step == 0 && f.Central {
+		step = FDCentral
+	} else if step == 0 {
+		step = FDForward
+	}
+
+	ndim := len(p.Pos)
+	plus := make([]*Point, ndim)
+	minus := make([]*Point, ndim)
+	steps := make([]float64, ndim)
+	pts := []*Point{}
+	if math.IsInf(p.Val, 1) {
+		pts = append(pts, p)
+	}
+	for i, v := range p.Pos {
+		h := step * math.Max(1, math.Abs(v))
+		canplus := f.Up == nil || v+h <= f.Up[i]
+		canminus := f.Low == nil || v-h >= f.Low[i]
+		if !canplus && !canminus {
+			// the bounds are narrower than the step - use the larger side
+			h = math.Max(f.Up[i]-v, v-f.Low[i])
+			canplus = f.Up[i]-v >= v-f.Low[i]
+			canminus = !canplus
+		}
+		if h == 0 {
+			continue
+		}
+
+		steps[i] = h
+		if canplus {
+			plus[i] = offset(p.Pos, h, i, 0, -1)
+			pts = append(pts, plus[i])
+		}
+		if canminus && (f.Central || !canplus) {
+			minus[i] = offset(p.Pos, -h, i, 0, -1)
+			pts = append(pts, minus[i])
+		}
+	}
+
+	ev := f.Ev
+	if ev == nil {
+		ev = SerialEvaler{}
+	}
+	if _, n, err = ev.Eval(f.Obj, pts...); err != nil {
+		return nil, n, err
+	}
+
+	g = make([]float64, ndim)
+	for i, h := range steps {
+		switch {
+		case h == 0:
+		case plus[i] != nil && minus[i] != nil:
+			g[i] = (plus[i].Val - minus[i].Val) / (2 * h)
+		case plus[i] != nil:
+			g[i] = (plus[i].Val - p.Val) / h
+		default:
+			g[i] = (p.Val - minus[i].Val) / h
+		}
+	}
+	return g, n, nil
+}
+
+// Hessian returns a finite difference approximation of the Hessian at v.  If
+// the wrapped objective provides an analytic gradient, the Hessian is
+// computed from central differences of the gradient (2n gradient
+// evaluations).  Otherwise it is computed from second differences of the
+// objective value (2n^2+1 evaluations).  The result is symmetrized.
+func (f FiniteDiff) Hessian(v []float64) ([][]float64, error) {
+	step := f.Step
+	if step == 0 {
+		step = FDHessian
+	}
+	ndim := len(v)
+	steps := make([]float64, ndim)
+	for i := range v {
+		steps[i] = step * math.Max(1, math.Abs(v[i]))
+	}
+
+	h := make([][]float64, ndim)
+	for i := range h {
+		h[i] = make([]float64, ndim)
+	}
+
+	if hasGrad(f.Obj) {
+		for j := range v {
+			gplus, err := analyticGrad(f.Obj, offset(v, steps[j], j, 0, -1).Pos)
+			if err != nil {
+				return nil, err
+			}
+			gminus, err := analyticGrad(f.Obj, offset(v, -steps[j], j, 0, -1).Pos)
+			if err != nil {
+				return nil, err
+			}
+			for i := range v {
+				h[i][j] = (gplus[i] - gminus[i]) / (2 * steps[j])
+			}
+		}
+	} else {
+		// d2f/dxi dxj ~= [f(++) - f(+-) - f(-+) + f(--)] / (4 hi hj) which
+		// for i == j reduces to [f(+2h) - 2f + f(-2h)] / (4h^2).
+		center := &Point{Pos: append([]float64{}, v...), Val: math.Inf(1)}
+		pts := []*Point{center}
+		type quad struct{ pp, pm, mp, mm *Point }
+		diffs := make([][]quad, ndim)
+		for i := range v {
+			diffs[i] = make([]quad, i+1)
+			for j := 0; j <= i; j++ {
+				if i == j {
+					q := quad{pp: offset(v, 2*steps[i], i, 0, -1), mm: offset(v, -2*steps[i], i, 0, -1)}
+					diffs[i][j] = q
+					pts = append(pts, q.pp, q.mm)
+					continue
+				}
+				q := quad{
+					pp: offset(v, steps[i], i, steps[j], j),
+					pm: offset(v, steps[i], i, -steps[j], j),
+					mp: offset(v, -steps[i], i, steps[j], j),
+					mm: offset(v, -steps[i], i, -steps[j], j),
+				}
+				diffs[i][j] = q
+				pts = append(pts, q.pp, q.pm, q.mp, q.mm)
+			}
+		}
+		if err := f.eval(pts); err != nil {
+			return nil, err
+		}
+		for i := range v {
+			for j := 0; j <= i; j++ {
+				q := diffs[i][j]
+				if i == j {
+					h[i][i] = (q.pp.Val - 2*center.Val + q.mm.Val) / (4 * steps[i] * steps[i])
+					continue
+				}
+				h[i][j] = (q.pp.Val - q.pm.Val - q.mp.Val + q.mm.Val) / (4 * steps[i] * steps[j])
+				h[j][i] = h[i][j]
+			}
+		}
+		return h, nil
+	}
+
+	for i := range h {
+		for j := 0; j < i; j++ {
+			h[i][j] = (h[i][j] + h[j][i]) / 2
+			h[j][i] = h[i][j]
+		}
+	}
+	return h, nil
+}
+
+func (f FiniteDiff) eval(pts []*Point) error {
+	ev := f.Ev
+	if ev == nil {
+		ev = SerialEvaler{}
+	}
+	_, _, err := ev.Eval(f.Obj, pts...)
+	return err
+}
+
+// offset returns an unevaluated copy of v with di added to v[i] and dj added
+// to v[j].  j may be negative to offset only a single dimension.
+func offset(v []float64, di float64, i int, dj float64, j int) *Point {
+	pos := append([]float64{}, v...)
+	pos[i] += di
+	if j >= 0 {
+		pos[j] += dj
+	}
+	return &Point{Pos: pos, Val: math.Inf(1)}
+}
+
+// CheckGradient compares obj's analytic gradient at v against central finite
+// differences.  It returns the largest error |g[i] - fd[i]| / max(1, |g[i]|,
+// |fd[i]|) over all dimensions and the index of the dimension where it
+// occurred - i.e. the error is relative for large derivatives and absolute
+// for small ones.  Correct gradients typically give errors below 1e-5.
+// ErrNoGradient is returned if obj implements neither Gradienter nor
+// ObjectiveGradienter.
+func CheckGradient(obj Objectiver, v []float64) (maxerr float64, worst int, err error) {
+	g, err := analyticGrad(obj, v)
+	if err != nil {
+		return 0, -1, err
+	}
+	fd, err := FiniteDiff{Obj: obj, Central: true}.Gradient(v)
+	if err != nil {
+		return 0, -1, err
+	}
+
+	worst = -1
+	for i := range g {
+		scale := math.Max(1, math.Max(math.Abs(g[i]), math.Abs(fd[i])))
+		if e := math.Abs(g[i]-fd[i]) / scale; worst < 0 || e > maxerr {
+			maxerr, worst = e, i
+		}
+	}
+	return maxerr, worst, nil
+}
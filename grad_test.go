@@ -0,0 +1,145 @@
+package optim
+
+import (
+	"math"
+	"testing"
+)
+
+// cubic is f(x,y) = x^3 + 2xy + 3y^2 with gradient (3x^2 + 2y, 2x + 6y) and
+// Hessian [[6x, 2], [2, 6]].
+func cubic(v []float64) float64 {
+	x, y := v[0], v[1]
+	return x*x*x + 2*x*y + 3*y*y
+}
+
+func cubicgrad(v []float64) []float64 {
+	x, y := v[0], v[1]
+	return []float64{3*x*x + 2*y, 2*x + 6*y}
+}
+
+func TestFiniteDiffGradient(t *testing.T) {
+	v := []float64{1.5, -2}
+	want := cubicgrad(v)
+	for _, central := range []bool{false, true} {
+		for _, ev := range []Evaler{nil, ParallelEvaler{}} {
+			fd := FiniteDiff{Obj: Func(cubic), Ev: ev, Central: central}
+			val, g, err := fd.ObjectiveGradient(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if val != cubic(v) {
+				t.Errorf("central=%v: objective value: want %v, got %v", central, cubic(v), val)
+			}
+			tol := 1e-6
+			if central {
+				tol = 1e-9
+			}
+			for i := range want {
+				if math.Abs(g[i]-want[i]) > tol {
+					t.Errorf("central=%v %T: want %v, got %v", central, ev, want, g)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestFiniteDiffBounds(t *testing.T) {
+	v := []float64{1.5, -2}
+	want := cubicgrad(v)
+	low, up := []float64{0, -2}, []float64{1.5, 0}
+	outside := 0
+	obj := Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		return cubic(x)
+	})
+
+	// both variables sit on a bound so each gets a single inward point and
+	// the already evaluated center is reused
+	p := &Point{Pos: v, Val: cubic(v)}
+	fd := FiniteDiff{Obj: obj, Central: true, Low: low, Up: up}
+	g, n, err := fd.PointGradient(p)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Errorf("want 2 evals, got %v", n)
+	}
+	if outside > 0 {
+		t.Errorf("%v points evaluated outside bounds", outside)
+	}
+	for i := range want {
+		if math.Abs(g[i]-want[i]) > 1e-4 {
+			t.Errorf("want %v, got %v", want, g)
+			break
+		}
+	}
+}
+
+func TestFiniteDiffHessian(t *testing.T) {
+	v := []float64{1.5, -2}
+	want := [][]float64{{6 * v[0], 2}, {2, 6}}
+	objs := []Objectiver{Func(cubic), FuncGrad{Obj: cubic, Grad: cubicgrad}}
+	for _, obj := range objs {
+		h, err := FiniteDiff{Obj: obj}.Hessian(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			for j := range want[i] {
+				if math.Abs(h[i][j]-want[i][j]) > 1e-5 {
+					t.Errorf("%T: want %v, got %v", obj, want, h)
+				}
+			}
+		}
+		if h[0][1] != h[1][0] {
+			t.Errorf("%T: Hessian not symmetric: %v", obj, h)
+		}
+	}
+}
+
+func TestCheckGradient(t *testing.T) {
+	v := []float64{0.3, 0.7}
+	maxerr, _, err := CheckGradient(FuncGrad{Obj: cubic, Grad: cubicgrad}, v)
+	if err != nil {
+		t.Fatal(err)
+	} else if maxerr > 1e-8 {
+		t.Errorf("correct gradient: want error < 1e-8, got %v", maxerr)
+	}
+
+	bad := func(v []float64) []float64 {
+		g := cubicgrad(v)
+		g[1] += 0.01
+		return g
+	}
+	maxerr, worst, err := CheckGradient(FuncGrad{Obj: cubic, Grad: bad}, v)
+	if err != nil {
+		t.Fatal(err)
+	} else if worst != 1 || maxerr < 1e-3 {
+		t.Errorf("wrong gradient: want error > 1e-3 in dimension 1, got %v in dimension %v", maxerr, worst)
+	}
+
+	if _, _, err := CheckGradient(Func(cubic), v); err != ErrNoGradient {
+		t.Errorf("no gradient: want %v, got %v", ErrNoGradient, err)
+	}
+}
+
+func TestGrad(t *testing.T) {
+	v := []float64{-1, 0.5}
+	want := cubicgrad(v)
+	for _, obj := range []Objectiver{Func(cubic), FuncGrad{Obj: cubic, Grad: cubicgrad}} {
+		g, err := Grad(obj, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if math.Abs(g[i]-want[i]) > 1e-8 {
+				t.Errorf("%T: want %v, got %v", obj, want, g)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package hookejeeves provides the classical pattern search method described
+// in:
+//
+//     Hooke, Robert, and T. A. Jeeves. "Direct search solution of numerical
+//     and statistical problems." Journal of the ACM 8.2 (1961): 212-229.
+//
+// Each iteration performs exploratory moves - trying a step along each poll
+// direction in turn and keeping every step that improves the objective.  A
+// successful exploration is followed by a pattern move that extrapolates
+// along the overall direction of improvement.  Exploration then continues
+// from the extrapolated point and the pattern is extended for as long as this
+// keeps improving on the best point.  Step sizes are managed by the solver's
+// optim.Mesh which is contracted after failed explorations, and the
+// exploratory directions are generated by a pattern.Spanner.
+package hookejeeves
+
+import (
+	"database/sql"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/pattern"
+)
+
+// TblInfo is the name of the sql database table that contains the best
+// point and mesh step for each iteration.
+const TblInfo = "hjinfo"
+
+// DefaultStepMult is the default factor by which the mesh step is
+// multiplied after a failed exploration.
+const DefaultStepMult = 0.5
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Spanner sets the exploratory move directions.  The default is the 2n
+// compass directions.
+func Spanner(s pattern.Spanner) Option { return func(m *Method) { m.Spanner = s } }
+
+// StepMult sets the factor (between 0 and 1) by which the mesh step is
+// multiplied after a failed exploration.
+func StepMult(mult float64) Option { return func(m *Method) { m.StepMult = mult } }
+
+type Method struct {
+	// Curr is the base point - the best point found so far.
+	Curr *optim.Point
+	// Pattern is the extrapolated point from which the next exploration
+	// starts - nil if the last exploration around the base point failed.
+	Pattern  *optim.Point
+	Spanner  pattern.Spanner
+	StepMult float64
+	// Npattern counts the pattern moves that led to an improvement.
+	Npattern int
+	Db       *sql.DB
+	ev       optim.Evaler
+	count    int
+}
+
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		Curr:     start,
+		Spanner:  pattern.Compass2N{},
+		StepMult: DefaultStepMult,
+		ev:       optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.initdb()
+	return m
+}
+
+// AddPoint replaces the base point with p if p is better.  Any pending
+// pattern move is abandoned.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.Curr.Val {
+		m.Curr = p
+		m.Pattern = nil
+	}
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.count++
+	usedpattern := m.Pattern != nil
+	defer func() { m.updateDb(mesh.Step(), usedpattern) }()
+
+	if math.IsInf(m.Curr.Val, 1) {
+		m.Curr = &optim.Point{Pos: mesh.Nearest(m.Curr.Pos), Val: math.Inf(1)}
+		_, n, err = m.ev.Eval(obj, m.Curr)
+		if err != nil {
+			return m.Curr, n, err
+		}
+	}
+	mesh.SetOrigin(m.Curr.Pos)
+
+	if m.Pattern != nil {
+		p := m.Pattern
+		m.Pattern = nil
+		_, ni, err := m.ev.Eval(obj, p)
+		n += ni
+		if err != nil {
+			return m.Curr, n, err
+		}
+
+		x, ni, err := m.explore(obj, mesh, p)
+		n += ni
+		if err != nil {
+			return m.Curr, n, err
+		} else if x.Val < m.Curr.Val {
+			m.Npattern++
+			m.move(mesh, x)
+			return m.Curr, n, nil
+		}
+		// the pattern move failed - fall back to exploring around the base
+	}
+
+	x, ni, err := m.explore(obj, mesh, m.Curr)
+	n += ni
+	if err != nil {
+		return m.Curr, n, err
+	}
+	success := x != m.Curr
+	m.Spanner.Update(mesh.Step(), success)
+	if success {
+		m.move(mesh, x)
+	} else {
+		mesh.SetStep(mesh.Step() * m.StepMult)
+	}
+	return m.Curr, n, nil
+}
+
+// move makes x the new base point and sets up the pattern move
+// x + (x - base).
+func (m *Method) move(mesh optim.Mesh, x *optim.Point) {
+	pos := make([]float64, x.Len())
+	for i := range pos {
+		pos[i] = 2*x.Pos[i] - m.Curr.Pos[i]
+	}
+	m.Curr = x
+	mesh.SetOrigin(x.Pos)
+	m.Pattern = &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+}
+
+// explore tries a step along each direction from the spanner in turn
+// starting at from, keeping each step that improves on the best point so
+// far.  The opposite of a successful direction is not tried.
+func (m *Method) explore(obj optim.Objectiver, mesh optim.Mesh, from *optim.Point) (best *optim.Point, n int, err error) {
+	best = from
+	steps := optim.Steps(mesh, from.Len())
+	succeeded := [][]int{}
+	for _, dir := range span(m.Spanner, from, mesh) {
+		if opposes(dir, succeeded) {
+			continue
+		}
+
+		pos := make([]float64, from.Len())
+		for i := range pos {
+			pos[i] = best.Pos[i] + float64(dir[i])*steps[i]
+		}
+		p := &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+		if samepos(p.Pos, best.Pos) {
+			continue
+		}
+
+		_, ni, err := m.ev.Eval(obj, p)
+		n += ni
+		if err != nil {
+			return best, n, err
+		} else if p.Val < best.Val {
+			best = p
+			succeeded = append(succeeded, dir)
+		}
+	}
+	return best, n, nil
+}
+
+func span(s pattern.Spanner, from *optim.Point, mesh optim.Mesh) [][]int {
+	if ps, ok := s.(pattern.PointSpanner); ok {
+		return ps.SpanAt(from, mesh)
+	}
+	return s.Span(from.Len())
+}
+
+// opposes returns true if dir is the negative of any of dirs.
+func opposes(dir []int, dirs [][]int) bool {
+	for _, d := range dirs {
+		opposite := true
+		for i := range d {
+			if d[i] != -dir[i] {
+				opposite = false
+				break
+			}
+		}
+		if opposite {
+			return true
+		}
+	}
+	return false
+}
+
+func samepos(a, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblInfo + " (iter INTEGER,step REAL,pattern INTEGER,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb(step float64, usedpattern bool) {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblInfo + " (iter,step,pattern,val,posid) VALUES (?,?,?,?,?);"
+	_, err = tx.Exec(s, m.count, step, usedpattern, m.Curr.Val, m.Curr.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.Curr)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("hookejeeves: db write failed -", err)
+		return true
+	}
+	return false
+}
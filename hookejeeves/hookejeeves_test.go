@@ -0,0 +1,97 @@
+package hookejeeves
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+	"github.com/baaaaam/optim/pattern"
+)
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 4}
+	for _, span := range []pattern.Spanner{pattern.Compass2N{}, pattern.CompassNp1{}} {
+		start := &optim.Point{Pos: []float64{-1.2, 1, -1.2, 1}, Val: math.Inf(1)}
+		m := New(start, Spanner(span))
+		solv := &optim.Solver{
+			Method:  m,
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{StepSize: 0.5},
+			MaxEval: 50000,
+			MinStep: 1e-9,
+		}
+		solv.Run()
+
+		t.Logf("[INFO] %T: %v evals, %v pattern moves: got %v", span, solv.Neval(), m.Npattern, solv.Best().Val)
+		if solv.Best().Val > 1e-6 {
+			t.Errorf("%T: want < 1e-6, got %v", span, solv.Best().Val)
+		}
+		if m.Npattern == 0 {
+			t.Errorf("%T: no successful pattern moves", span)
+		}
+	}
+}
+
+func TestPatternMove(t *testing.T) {
+	// on a linear slope every pattern move is one step longer than the last
+	obj := optim.Func(func(x []float64) float64 { return -x[0] - x[1] })
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	m := New(start)
+	mesh := &optim.InfMesh{StepSize: 1}
+	for i := 0; i < 6; i++ {
+		if _, _, err := m.Iterate(obj, mesh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// each exploration adds one step to the previous pattern move so the
+	// base moves 1, 3, 6, 10, 15, 21 steps along each axis
+	if want := 21.0; m.Curr.Pos[0] != want || m.Curr.Pos[1] != want {
+		t.Errorf("want position [%v %v], got %v", want, want, m.Curr.Pos)
+	}
+}
+
+func TestIntMesh(t *testing.T) {
+	obj := optim.Func(func(x []float64) float64 {
+		a, b := x[0]-3.3, x[1]+7.8
+		return a*a + b*b
+	})
+	start := &optim.Point{Pos: []float64{20, 20}, Val: math.Inf(1)}
+	m := New(start)
+	mesh := &optim.IntMesh{Mesh: &optim.InfMesh{StepSize: 4}}
+	solv := &optim.Solver{Method: m, Obj: obj, Mesh: mesh, MaxIter: 200, MaxNoImprove: 10}
+	solv.Run()
+
+	want := []float64{3, -8}
+	if got := solv.Best().Pos; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{StepSize: 0.5},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblInfo).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblInfo, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblInfo, count, solv.Niter())
+	}
+}
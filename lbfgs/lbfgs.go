@@ -0,0 +1,406 @@
+// Package lbfgs provides a limited-memory BFGS quasi-Newton method with
+// optional bound constraints as described in:
+//
+//     Nocedal, Jorge. "Updating quasi-Newton matrices with limited storage."
+//     Mathematics of Computation 35.151 (1980): 773-782.
+//
+//     Byrd, Richard H., et al. "A limited memory algorithm for bound
+//     constrained optimization." SIAM Journal on Scientific Computing 16.5
+//     (1995): 1190-1208.
+//
+// Bounds are handled by projection: variables at a bound whose gradient
+// points outward are held fixed and line search points are projected onto
+// the bounds.  If the objective implements optim.Gradienter or
+// optim.ObjectiveGradienter, its gradient is used directly.  Otherwise
+// gradients are computed with optim.FiniteDiff whose points are evaluated as
+// a single batch through the method's Evaler - so an optim.ParallelEvaler
+// computes all partial derivatives concurrently.
+package lbfgs
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// TblIter is the name of the sql database table that contains the current
+// point, gradient norm and accepted step length for each iteration.
+const TblIter = "lbfgsiter"
+
+const (
+	DefaultMemory  = 10
+	DefaultGradTol = 1e-6
+	// Armijo is the sufficient decrease constant for the line search.
+	Armijo = 1e-4
+	// MaxBacktrack is the maximum number of step halvings tried in each line
+	// search.
+	MaxBacktrack = 30
+)
+
+var errLineSearch = errors.New("lbfgs: line search failed")
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Memory sets the number of correction pairs used to approximate the
+// inverse Hessian.
+func Memory(n int) Option { return func(m *Method) { m.Memory = n } }
+
+// Bounds constrains the search to the box defined by low and up.  If no
+// bounds are given and the solver's mesh is an optim.BoxMesh, the mesh's
+// bounds are used.
+func Bounds(low, up []float64) Option {
+	return func(m *Method) {
+		m.Low = append([]float64{}, low...)
+		m.Up = append([]float64{}, up...)
+	}
+}
+
+// Central uses central instead of forward finite differences.  This doubles
+// the evaluations per gradient but gives much more accurate gradients.
+func Central() Option { return func(m *Method) { m.Central = true } }
+
+// FDStep sets the relative finite difference step size.  The step for
+// variable i is h*max(1, |x_i|).  By default optim.FDForward or
+// optim.FDCentral is used.
+func FDStep(h float64) Option { return func(m *Method) { m.FDStep = h } }
+
+// GradTol sets the projected gradient (infinity) norm below which the
+// method is considered converged.
+func GradTol(tol float64) Option { return func(m *Method) { m.GradTol = tol } }
+
+type Method struct {
+	// X is the current iterate.
+	X *optim.Point
+	// Grad is the gradient at X.
+	Grad    []float64
+	Memory  int
+	Low, Up []float64
+	FDStep  float64
+	Central bool
+	GradTol float64
+	// Converged is set once the projected gradient norm falls below GradTol
+	// or no further progress can be made.  Subsequent iterations evaluate
+	// nothing and return optim.ErrConverged.
+	Converged bool
+	// Ngrad counts gradient computations.
+	Ngrad int
+	Db    *sql.DB
+	ev    optim.Evaler
+	// s and y hold the most recent position and gradient changes.
+	s, y [][]float64
+	step float64
+	iter int
+}
+
+// New creates an L-BFGS method starting at start.  If start's value is
+// +infinity, it is evaluated on the first iteration.
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		X:       start.Clone(),
+		Memory:  DefaultMemory,
+		GradTol: DefaultGradTol,
+		ev:      optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint moves the current iterate to p if p is better, discarding the
+// curvature information collected so far.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val >= m.X.Val {
+		return
+	}
+	m.X = p.Clone()
+	m.Grad = nil
+	m.s, m.y = nil, nil
+	m.Converged = false
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	if bm, ok := mesh.(*optim.BoxMesh); ok && m.Low == nil {
+		m.Low, m.Up = bm.Lower, bm.Upper
+	}
+
+	if m.Grad == nil {
+		if math.IsInf(m.X.Val, 1) {
+			m.X.Pos = m.project(m.X.Pos, mesh)
+			_, n, err = m.ev.Eval(obj, m.X)
+			if err != nil {
+				return m.X, n, err
+			}
+		}
+		var ng int
+		m.Grad, ng, err = m.gradient(obj, m.X)
+		return m.X, n + ng, err
+	}
+
+	if m.Converged {
+		return m.X, 0, optim.ErrConverged
+	}
+	if m.projgradnorm() < m.GradTol {
+		m.Converged = true
+		return m.X, 0, optim.ErrConverged
+	}
+
+	d := m.direction()
+	next, n, err := m.linesearch(obj, mesh, d)
+	if err == errLineSearch {
+		// retry from steepest descent before giving up
+		if len(m.s) == 0 {
+			m.Converged = true
+		}
+		m.s, m.y = nil, nil
+		return m.X, n, nil
+	} else if err != nil {
+		return m.X, n, err
+	}
+
+	g, ng, err := m.gradient(obj, next)
+	n += ng
+	if err != nil {
+		return m.X, n, err
+	}
+
+	s := make([]float64, len(g))
+	y := make([]float64, len(g))
+	for i := range g {
+		s[i] = next.Pos[i] - m.X.Pos[i]
+		y[i] = g[i] - m.Grad[i]
+	}
+	// skip updates that would make the inverse Hessian approximation
+	// indefinite
+	if sy := dot(s, y); sy > 1e-10*dot(y, y) {
+		m.s = append(m.s, s)
+		m.y = append(m.y, y)
+		if len(m.s) > m.Memory {
+			m.s, m.y = m.s[1:], m.y[1:]
+		}
+	}
+
+	m.X, m.Grad = next, g
+	return m.X, n, nil
+}
+
+// free returns which variables may move - variables at a bound with the
+// gradient pointing outward are fixed.
+func (m *Method) free() []bool {
+	free := make([]bool, len(m.Grad))
+	for i, g := range m.Grad {
+		free[i] = true
+		if m.Low != nil && m.X.Pos[i] <= m.Low[i] && g > 0 {
+			free[i] = false
+		} else if m.Up != nil && m.X.Pos[i] >= m.Up[i] && g < 0 {
+			free[i] = false
+		}
+	}
+	return free
+}
+
+// direction computes the quasi-Newton search direction restricted to the
+// free variables using the L-BFGS two-loop recursion.
+func (m *Method) direction() []float64 {
+	free := m.free()
+	q := make([]float64, len(m.Grad))
+	for i, g := range m.Grad {
+		if free[i] {
+			q[i] = g
+		}
+	}
+
+	k := len(m.s)
+	alpha := make([]float64, k)
+	for i := k - 1; i >= 0; i-- {
+		alpha[i] = dot(m.s[i], q) / dot(m.s[i], m.y[i])
+		for j := range q {
+			q[j] -= alpha[i] * m.y[i][j]
+		}
+	}
+
+	gamma := 1.0
+	if k > 0 {
+		gamma = dot(m.s[k-1], m.y[k-1]) / dot(m.y[k-1], m.y[k-1])
+	} else if gnorm := math.Sqrt(dot(q, q)); gnorm > 0 {
+		// without curvature information, take a unit length first step
+		gamma = 1 / gnorm
+	}
+	for j := range q {
+		q[j] *= gamma
+	}
+
+	for i := 0; i < k; i++ {
+		beta := dot(m.y[i], q) / dot(m.s[i], m.y[i])
+		for j := range q {
+			q[j] += m.s[i][j] * (alpha[i] - beta)
+		}
+	}
+
+	d := make([]float64, len(q))
+	for i := range d {
+		if free[i] {
+			d[i] = -q[i]
+		}
+	}
+
+	if dot(d, m.Grad) >= 0 && k > 0 {
+		// not a descent direction - fall back to steepest descent
+		m.s, m.y = nil, nil
+		return m.direction()
+	}
+	return d
+}
+
+// linesearch backtracks along the projected path P(x + alpha*d) until the
+// Armijo sufficient decrease condition holds.
+func (m *Method) linesearch(obj optim.Objectiver, mesh optim.Mesh, d []float64) (next *optim.Point, n int, err error) {
+	alpha := 1.0
+	for k := 0; k < MaxBacktrack; k++ {
+		pos := make([]float64, len(d))
+		for i := range pos {
+			pos[i] = m.X.Pos[i] + alpha*d[i]
+		}
+		pos = m.project(pos, mesh)
+
+		moved, decrease := false, 0.0
+		for i := range pos {
+			moved = moved || pos[i] != m.X.Pos[i]
+			decrease += m.Grad[i] * (pos[i] - m.X.Pos[i])
+		}
+		if !moved {
+			break
+		}
+
+		p := &optim.Point{Pos: pos, Val: math.Inf(1)}
+		_, ni, err := m.ev.Eval(obj, p)
+		n += ni
+		if err != nil {
+			return nil, n, err
+		}
+		if p.Val <= m.X.Val+Armijo*decrease {
+			m.step = alpha
+			return p, n, nil
+		}
+		alpha /= 2
+	}
+	return nil, n, errLineSearch
+}
+
+// gradient returns the gradient of obj at p using obj's Gradient method if
+// available or finite differences otherwise.
+func (m *Method) gradient(obj optim.Objectiver, p *optim.Point) (g []float64, n int, err error) {
+	m.Ngrad++
+	switch o := obj.(type) {
+	case optim.Gradienter:
+		g, err = o.Gradient(p.Pos)
+		return g, 0, err
+	case optim.ObjectiveGradienter:
+		_, g, err = o.ObjectiveGradient(p.Pos)
+		return g, 0, err
+	}
+
+	fd := optim.FiniteDiff{
+		Obj:     obj,
+		Ev:      m.ev,
+		Step:    m.FDStep,
+		Central: m.Central,
+		Low:     m.Low,
+		Up:      m.Up,
+	}
+	return fd.PointGradient(p)
+}
+
+// project clips pos to the bounds and then onto the mesh.
+func (m *Method) project(pos []float64, mesh optim.Mesh) []float64 {
+	pos = append([]float64{}, pos...)
+	for i := range pos {
+		if m.Low != nil {
+			pos[i] = math.Max(m.Low[i], pos[i])
+		}
+		if m.Up != nil {
+			pos[i] = math.Min(m.Up[i], pos[i])
+		}
+	}
+	return mesh.Nearest(pos)
+}
+
+// projgradnorm returns the infinity norm of P(x - g) - x.
+func (m *Method) projgradnorm() float64 {
+	norm := 0.0
+	for i, g := range m.Grad {
+		v := m.X.Pos[i] - g
+		if m.Low != nil {
+			v = math.Max(m.Low[i], v)
+		}
+		if m.Up != nil {
+			v = math.Min(m.Up[i], v)
+		}
+		norm = math.Max(norm, math.Abs(v-m.X.Pos[i]))
+	}
+	return norm
+}
+
+func dot(a, b []float64) float64 {
+	tot := 0.0
+	for i := range a {
+		tot += a[i] * b[i]
+	}
+	return tot
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblIter + " (iter INTEGER, val REAL, gradnorm REAL, step REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil || m.Grad == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblIter + " (iter,val,gradnorm,step,posid) VALUES (?,?,?,?,?);"
+	_, err = tx.Exec(s, m.iter, m.X.Val, m.projgradnorm(), m.step, m.X.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.X)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("lbfgs: db write failed -", err)
+		return true
+	}
+	return false
+}
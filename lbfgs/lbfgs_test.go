@@ -0,0 +1,176 @@
+package lbfgs
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 10}
+	for _, central := range []bool{false, true} {
+		start := &optim.Point{Pos: make([]float64, 10), Val: math.Inf(1)}
+		opts := []Option{}
+		if central {
+			opts = append(opts, Central())
+		}
+		m := New(start, opts...)
+		solv := &optim.Solver{
+			Method:  m,
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 20000,
+		}
+		if err := solv.Run(); err != nil {
+			t.Errorf("central=%v: want no error, got %v", central, err)
+		}
+
+		t.Logf("[INFO] central=%v: %v evals, %v gradients: got %v", central, solv.Neval(), m.Ngrad, solv.Best().Val)
+		if solv.Best().Val > 1e-8 {
+			t.Errorf("central=%v: want < 1e-8, got %v", central, solv.Best().Val)
+		}
+	}
+}
+
+// quadgrad is an ill-conditioned quadratic with an analytic gradient that
+// counts its objective evaluations.
+type quadgrad struct{ nobj, ngrad int }
+
+func (q *quadgrad) Objective(x []float64) (float64, error) {
+	q.nobj++
+	tot := 0.0
+	for i, v := range x {
+		tot += math.Pow(10, float64(i)) * (v - 1) * (v - 1)
+	}
+	return tot, nil
+}
+
+func (q *quadgrad) Gradient(x []float64) ([]float64, error) {
+	q.ngrad++
+	g := make([]float64, len(x))
+	for i, v := range x {
+		g[i] = 2 * math.Pow(10, float64(i)) * (v - 1)
+	}
+	return g, nil
+}
+
+func TestGradienter(t *testing.T) {
+	obj := &quadgrad{}
+	start := &optim.Point{Pos: make([]float64, 5), Val: math.Inf(1)}
+	m := New(start)
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 500,
+	}
+	if err := solv.Run(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	t.Logf("[INFO] %v evals, %v gradients: got %v", solv.Neval(), obj.ngrad, solv.Best())
+	if obj.ngrad != m.Ngrad || obj.ngrad == 0 {
+		t.Errorf("analytic gradient called %v times, want %v", obj.ngrad, m.Ngrad)
+	}
+	if obj.nobj != solv.Neval() {
+		t.Errorf("objective evaluated %v times but %v evals reported", obj.nobj, solv.Neval())
+	}
+	if solv.Best().Val > 1e-10 {
+		t.Errorf("want < 1e-10, got %v", solv.Best().Val)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	// unconstrained optimum at (3, -3, 0.5)
+	low, up := []float64{-1, -1, -1}, []float64{1, 1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		a, b, c := x[0]-3, x[1]+3, x[2]-0.5
+		return a*a + b*b + c*c + a*b/2
+	})
+
+	for _, mesh := range []optim.Mesh{&optim.InfMesh{}, &optim.BoxMesh{Mesh: &optim.InfMesh{}, Lower: low, Upper: up}} {
+		opts := []Option{}
+		if _, ok := mesh.(*optim.BoxMesh); !ok {
+			opts = append(opts, Bounds(low, up))
+		}
+		start := &optim.Point{Pos: []float64{0, 0, 0}, Val: math.Inf(1)}
+		m := New(start, opts...)
+		solv := &optim.Solver{Method: m, Obj: obj, Mesh: mesh, MaxIter: 200}
+		if err := solv.Run(); err != nil {
+			t.Errorf("%T: want no error, got %v", mesh, err)
+		}
+
+		t.Logf("[INFO] %T: %v evals: got %v", mesh, solv.Neval(), solv.Best())
+		if outside > 0 {
+			t.Errorf("%T: %v points evaluated outside bounds", mesh, outside)
+		}
+		want := []float64{1, -1, 0.5}
+		for i := range want {
+			if math.Abs(solv.Best().Pos[i]-want[i]) > 1e-5 {
+				t.Errorf("%T: want %v, got %v", mesh, want, solv.Best().Pos)
+				break
+			}
+		}
+	}
+}
+
+func TestParallel(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 6}
+	start := &optim.Point{Pos: make([]float64, 6), Val: math.Inf(1)}
+	m := New(start, Evaler(optim.ParallelEvaler{}), Central())
+	obj := optim.Func(fn.Eval)
+	mesh := &optim.InfMesh{}
+
+	// the first iteration evaluates the start point and a central difference
+	// gradient
+	_, n, err := m.Iterate(obj, mesh)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 1+2*6 {
+		t.Errorf("first iteration: want %v evals, got %v", 1+2*6, n)
+	}
+	for i := 0; i < 300 && !m.Converged; i++ {
+		if _, _, err := m.Iterate(obj, mesh); err != nil && err != optim.ErrConverged {
+			t.Fatal(err)
+		}
+	}
+	if m.X.Val > 1e-8 {
+		t.Errorf("want < 1e-8, got %v", m.X.Val)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblIter).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblIter, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblIter, count, solv.Niter())
+	}
+}
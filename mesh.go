@@ -19,6 +19,59 @@ type Mesh interface {
 	Origin() []float64
 }
 
+// AnisoMesh is a Mesh that supports a separate step size for each
+// dimension.  For anisotropic meshes, Step returns the largest of the
+// per-dimension steps and SetStep rescales all dimensions uniformly such that
+// the largest step equals the given step.
+type AnisoMesh interface {
+	Mesh
+	// Steps returns the step size for each dimension of the mesh.
+	Steps() []float64
+	// SetSteps sets the step size for each dimension of the mesh.
+	SetSteps(steps []float64)
+}
+
+// Steps returns the per-dimension step sizes of m.  If m does not implement
+// AnisoMesh, every dimension has step m.Step().
+func Steps(m Mesh, ndim int) []float64 {
+	if am, ok := m.(AnisoMesh); ok {
+		if steps := am.Steps(); len(steps) == ndim {
+			return steps
+		}
+	}
+	steps := make([]float64, ndim)
+	for i := range steps {
+		steps[i] = m.Step()
+	}
+	return steps
+}
+
+// SetSteps sets the per-dimension step sizes of m.  If m does not implement
+// AnisoMesh, m's step is set to the largest of steps.
+func SetSteps(m Mesh, steps []float64) {
+	if am, ok := m.(AnisoMesh); ok {
+		am.SetSteps(steps)
+		return
+	}
+	m.SetStep(maxof(steps))
+}
+
+// stepsof returns the per-dimension steps for a wrapped mesh.
+func stepsof(m Mesh) []float64 {
+	if am, ok := m.(AnisoMesh); ok {
+		return am.Steps()
+	}
+	return Steps(m, len(m.Origin()))
+}
+
+func maxof(vals []float64) float64 {
+	max := 0.0
+	for _, v := range vals {
+		max = math.Max(max, v)
+	}
+	return max
+}
+
 type MaxStepMesh struct {
 	Mesh
 	MaxStep float64
@@ -30,6 +83,20 @@ func (m *MaxStepMesh) SetStep(step float64) {
 	}
 }
 
+func (m *MaxStepMesh) Steps() []float64 { return stepsof(m.Mesh) }
+
+// SetSteps sets the step for each dimension to the corresponding value in
+// steps except for steps greater than MaxStep which are left unchanged.
+func (m *MaxStepMesh) SetSteps(steps []float64) {
+	curr := Steps(m.Mesh, len(steps))
+	for i, step := range steps {
+		if step <= m.MaxStep {
+			curr[i] = step
+		}
+	}
+	SetSteps(m.Mesh, curr)
+}
+
 type IntMesh struct {
 	Mesh
 }
@@ -46,6 +113,16 @@ func (m *IntMesh) SetStep(step float64) {
 	m.Mesh.SetStep(math.Max(step, 1))
 }
 
+func (m *IntMesh) Steps() []float64 { return stepsof(m.Mesh) }
+
+func (m *IntMesh) SetSteps(steps []float64) {
+	intsteps := make([]float64, len(steps))
+	for i, step := range steps {
+		intsteps[i] = math.Max(step, 1)
+	}
+	SetSteps(m.Mesh, intsteps)
+}
+
 func (m *IntMesh) SetOrigin(origin []float64) {
 	m.Mesh.SetOrigin(m.Nearest(origin))
 }
@@ -55,7 +132,8 @@ func (m *IntMesh) SetOrigin(origin []float64) {
 // mesh. If Origin == nil, the dimensionality is set by the first call to
 // Nearest.  If Basis == nil, a unit basis (the identify matrix) is used.  If
 // Step == 0, then the mesh represents continuous space and the Nearest method
-// just returns the point passed to it.
+// just returns the point passed to it.  If Scales is non-nil, the mesh is
+// anisotropic with a step of StepSize*Scales[i] along mesh axis i.
 type InfMesh struct {
 	Center []float64
 	// Basis contains a set of row vectors defining the directions of each
@@ -63,14 +141,64 @@ type InfMesh struct {
 	Basis *mat64.Dense
 	// Step represents the discretization or grid size of the mesh.
 	StepSize float64
+	// Scales holds a relative step size multiplier for each dimension.  A
+	// nil Scales is equivalent to all multipliers being one.  A zero
+	// multiplier makes the corresponding mesh axis continuous.
+	Scales   []float64
 	inverter *mat64.Dense
 }
 
-func (m *InfMesh) Step() float64              { return m.StepSize }
-func (m *InfMesh) SetStep(step float64)       { m.StepSize = step }
+// Step returns the largest per-dimension step (see AnisoMesh).
+func (m *InfMesh) Step() float64 { return m.StepSize * m.maxScale() }
+
+// SetStep rescales StepSize so that the largest per-dimension step is step.
+func (m *InfMesh) SetStep(step float64) { m.StepSize = step / m.maxScale() }
+
 func (m *InfMesh) Origin() []float64          { return m.Center }
 func (m *InfMesh) SetOrigin(origin []float64) { m.Center = origin }
 
+// maxScale returns the largest of Scales or one if there are no non-zero
+// Scales.
+func (m *InfMesh) maxScale() float64 {
+	if s := maxof(m.Scales); s > 0 {
+		return s
+	}
+	return 1
+}
+
+// Steps returns StepSize*Scales[i] for each dimension i.
+func (m *InfMesh) Steps() []float64 {
+	steps := make([]float64, len(m.Center))
+	if m.Scales != nil {
+		steps = make([]float64, len(m.Scales))
+	}
+	for i := range steps {
+		steps[i] = m.axisStep(i)
+	}
+	return steps
+}
+
+// SetSteps sets StepSize to the largest value in steps and updates Scales
+// so that StepSize*Scales[i] == steps[i].  If all steps are zero, StepSize
+// is set to zero and Scales is left unchanged.
+func (m *InfMesh) SetSteps(steps []float64) {
+	m.StepSize = maxof(steps)
+	if m.StepSize == 0 {
+		return
+	}
+	m.Scales = make([]float64, len(steps))
+	for i, step := range steps {
+		m.Scales[i] = step / m.StepSize
+	}
+}
+
+func (m *InfMesh) axisStep(i int) float64 {
+	if m.Scales == nil {
+		return m.StepSize
+	}
+	return m.StepSize * m.Scales[i]
+}
+
 // Nearest returns the nearest grid point to p by rounding each dimensional
 // position to the nearest grid point.  If the mesh basis is not the identity
 // matrix, then p is transformed to the mesh basis before rounding and then
@@ -108,11 +236,16 @@ func (m *InfMesh) Nearest(p []float64) []float64 {
 	// calculate nearest point
 	nearest := mat64.NewDense(len(p), 1, nil)
 	for i := range m.Center {
-		n, rem := math.Modf(rotv.At(i, 0) / m.StepSize)
-		if rem/m.StepSize > 0.5 {
+		step := m.axisStep(i)
+		if step == 0 {
+			nearest.Set(i, 0, rotv.At(i, 0))
+			continue
+		}
+		n, rem := math.Modf(rotv.At(i, 0) / step)
+		if rem/step > 0.5 {
 			n++
 		}
-		nearest.Set(i, 0, float64(n)*m.StepSize)
+		nearest.Set(i, 0, float64(n)*step)
 	}
 
 	// transform back to standard space
@@ -132,6 +265,9 @@ type BoxMesh struct {
 	Upper []float64
 }
 
+func (m *BoxMesh) Steps() []float64         { return stepsof(m.Mesh) }
+func (m *BoxMesh) SetSteps(steps []float64) { SetSteps(m.Mesh, steps) }
+
 // Nearest returns the nearest bounded grid point to p by sliding each
 // dimensional position to the nearest value inside bounds and then rounding
 // to the nearest grid point.  If the mesh basis is not the identity matrix,
@@ -146,3 +282,336 @@ func (m *BoxMesh) Nearest(p []float64) []float64 {
 	}
 	return m.Mesh.Nearest(pdup)
 }
+
+// PolyMesh wraps a mesh and keeps points inside the polytope defined by the
+// linear constraints low <= Ax <= up and optional box bounds
+// Lower <= x <= Upper.  If A is nil, only the box bounds are used.  Nearest
+// first projects points onto the polytope (tightened by one mesh step of
+// rounding margin) using Dykstra's alternating projection algorithm and then
+// snaps the projection to the underlying mesh grid.  If no grid point can be
+// found inside the polytope, Nearest returns the (off-grid) projection
+// itself.  Returned points are feasible whenever the polytope is non-empty -
+// if it is empty, the result is only approximately feasible.
+type PolyMesh struct {
+	Mesh
+	A       *mat64.Dense
+	Low, Up *mat64.Dense
+	// Lower and Upper are optional box bounds on each dimension.
+	Lower, Upper []float64
+	// MaxIter is the maximum number of projection sweeps through all
+	// constraints.  If zero, 1000 is used.
+	MaxIter int
+	// Tol is the constraint violation tolerated for a point to be considered
+	// feasible.  If zero, 1e-9 is used.
+	Tol   float64
+	rows  [][]float64 // stacked version of A
+	b     []float64   // Low and Up stacked
+	norm2 []float64   // squared L2 norm of each of rows
+}
+
+func (m *PolyMesh) init() {
+	if m.rows != nil {
+		// already initialized
+		return
+	}
+
+	var a, b *mat64.Dense
+	if m.A == nil {
+		// box bounds only
+		n := len(m.Lower)
+		ident := mat64.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			ident.Set(i, i, 1)
+		}
+		lbm := mat64.NewDense(n, 1, append([]float64{}, m.Lower...))
+		ubm := mat64.NewDense(n, 1, append([]float64{}, m.Upper...))
+		a, b, _ = StackConstr(lbm, ident, ubm)
+	} else if m.Lower != nil {
+		a, b, _ = StackConstrBoxed(m.Lower, m.Upper, m.Low, m.A, m.Up)
+	} else {
+		a, b, _ = StackConstr(m.Low, m.A, m.Up)
+	}
+
+	nrow, ncol := a.Dims()
+	for i := 0; i < nrow; i++ {
+		row := make([]float64, ncol)
+		n2 := 0.0
+		for j := range row {
+			row[j] = a.At(i, j)
+			n2 += row[j] * row[j]
+		}
+		if n2 == 0 || math.IsInf(b.At(i, 0), 1) {
+			continue
+		}
+		m.rows = append(m.rows, row)
+		m.b = append(m.b, b.At(i, 0))
+		m.norm2 = append(m.norm2, n2)
+	}
+}
+
+func (m *PolyMesh) Steps() []float64         { return stepsof(m.Mesh) }
+func (m *PolyMesh) SetSteps(steps []float64) { SetSteps(m.Mesh, steps) }
+
+func (m *PolyMesh) Nearest(p []float64) []float64 {
+	m.init()
+
+	// rounding to the nearest grid point moves each dimension by at most
+	// half a step.  Tightening the polytope by a full step leaves room for
+	// wrapping meshes that round a second time (e.g. IntMesh) and keeps the
+	// snapped point feasible for axis-aligned meshes.
+	steps := Steps(m.Mesh, len(p))
+	margins := make([]float64, len(m.rows))
+	for i, row := range m.rows {
+		for j, a := range row {
+			margins[i] += math.Abs(a) * steps[j]
+		}
+	}
+
+	var proj []float64
+	for _, margin := range [][]float64{margins, nil} {
+		proj = m.Project(p, margin)
+		if !m.Feasible(proj) {
+			continue
+		}
+		if snapped := m.Mesh.Nearest(proj); m.Feasible(snapped) {
+			return snapped
+		}
+	}
+	return proj
+}
+
+// Feasible returns true if x satisfies all constraints within Tol.
+func (m *PolyMesh) Feasible(x []float64) bool {
+	m.init()
+	tol := m.Tol
+	if tol == 0 {
+		tol = 1e-9
+	}
+	for i, row := range m.rows {
+		if dot(row, x)-m.b[i] > tol*math.Max(1, math.Abs(m.b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Project returns the euclidean projection of p onto the polytope with each
+// stacked constraint tightened by margins[i] (nil for no tightening).
+func (m *PolyMesh) Project(p []float64, margins []float64) []float64 {
+	x, _ := m.project(p, margins)
+	return x
+}
+
+// project runs Dykstra's algorithm and returns the projection along with the
+// multiplier of each stacked constraint from the final sweep.  The
+// multipliers of an empty polytope grow without bound and approach a Farkas
+// certificate of its infeasibility (see farkas).
+func (m *PolyMesh) project(p []float64, margins []float64) (x, lambda []float64) {
+	m.init()
+	maxiter := m.MaxIter
+	if maxiter == 0 {
+		maxiter = 1000
+	}
+
+	x = append([]float64{}, p...)
+	lambda = make([]float64, len(m.rows))
+	incr := make([][]float64, len(m.rows))
+	for i := range incr {
+		incr[i] = make([]float64, len(p))
+	}
+
+	y := make([]float64, len(p))
+	for iter := 0; iter < maxiter; iter++ {
+		change := 0.0
+		for i, row := range m.rows {
+			for j := range y {
+				y[j] = x[j] + incr[i][j]
+			}
+
+			b := m.b[i]
+			if margins != nil {
+				b -= margins[i]
+			}
+			viol := math.Max(0, dot(row, y)-b) / m.norm2[i]
+			lambda[i] = viol
+
+			for j := range y {
+				next := y[j] - viol*row[j]
+				incr[i][j] = y[j] - next
+				change += (next - x[j]) * (next - x[j])
+				x[j] = next
+			}
+		}
+		if change < 1e-24*(1+dot(x, x)) {
+			break
+		}
+	}
+	return x, lambda
+}
+
+// EqMesh is a mesh restricted to the affine subspace of points satisfying
+// the equality constraints Aeq x = Beq.  The subspace is parameterized by the
+// free (non-pivot) variables of the reduced row echelon form of Aeq: the
+// wrapped Mesh operates on the free variables only and the remaining pivot
+// variables are computed from them such that the constraints hold exactly.
+// This lets methods search only the feasible subspace while the objective is
+// always called with full coordinates.  Pivot variables have a step of zero
+// for anisotropic meshes.  Box bounds are not enforced by EqMesh.  An EqMesh
+// must be created with NewEqMesh.
+type EqMesh struct {
+	Mesh
+	ndim   int
+	free   []int       // indices of the free variables
+	pivots []int       // indices of the pivot variables
+	coeffs [][]float64 // pivots[k] = rhs[k] - dot(coeffs[k], free vars)
+	rhs    []float64
+}
+
+// eqtol is the relative tolerance used to detect linearly dependent and
+// inconsistent equality constraints.
+const eqtol = 1e-10
+
+// NewEqMesh creates an EqMesh wrapping m for the constraints Aeq x = Beq.
+// ErrInfeasible is returned if the constraints are inconsistent.
+func NewEqMesh(m Mesh, Aeq, Beq *mat64.Dense) (*EqMesh, error) {
+	em := &EqMesh{Mesh: m}
+	if err := em.init(Aeq, Beq); err != nil {
+		return nil, err
+	}
+	return em, nil
+}
+
+func (m *EqMesh) init(Aeq, Beq *mat64.Dense) error {
+	nrow, ncol := Aeq.Dims()
+	m.ndim = ncol
+	aug := make([][]float64, nrow)
+	scale := 0.0
+	for i := range aug {
+		aug[i] = make([]float64, ncol+1)
+		for j := 0; j < ncol; j++ {
+			aug[i][j] = Aeq.At(i, j)
+			scale = math.Max(scale, math.Abs(aug[i][j]))
+		}
+		aug[i][ncol] = Beq.At(i, 0)
+	}
+	tol := eqtol * math.Max(1, scale)
+
+	// reduce to row echelon form with partial pivoting
+	rank := 0
+	ispivot := make([]bool, ncol)
+	for j := 0; j < ncol && rank < nrow; j++ {
+		best := rank
+		for i := rank + 1; i < nrow; i++ {
+			if math.Abs(aug[i][j]) > math.Abs(aug[best][j]) {
+				best = i
+			}
+		}
+		if math.Abs(aug[best][j]) <= tol {
+			continue
+		}
+		aug[rank], aug[best] = aug[best], aug[rank]
+
+		piv := aug[rank][j]
+		for k := range aug[rank] {
+			aug[rank][k] /= piv
+		}
+		for i := range aug {
+			if i == rank || aug[i][j] == 0 {
+				continue
+			}
+			f := aug[i][j]
+			for k := range aug[i] {
+				aug[i][k] -= f * aug[rank][k]
+			}
+		}
+		ispivot[j] = true
+		m.pivots = append(m.pivots, j)
+		rank++
+	}
+
+	for i := rank; i < nrow; i++ {
+		if math.Abs(aug[i][ncol]) > tol*math.Max(1, math.Abs(Beq.At(i, 0))) {
+			return ErrInfeasible
+		}
+	}
+
+	m.free = []int{}
+	for j := 0; j < ncol; j++ {
+		if !ispivot[j] {
+			m.free = append(m.free, j)
+		}
+	}
+	for k := range m.pivots {
+		coeffs := make([]float64, len(m.free))
+		for i, j := range m.free {
+			coeffs[i] = aug[k][j]
+		}
+		m.coeffs = append(m.coeffs, coeffs)
+		m.rhs = append(m.rhs, aug[k][ncol])
+	}
+	return nil
+}
+
+// Reduce returns the free variable coordinates of x.
+func (m *EqMesh) Reduce(x []float64) []float64 {
+	m.check()
+	z := make([]float64, len(m.free))
+	for i, j := range m.free {
+		z[i] = x[j]
+	}
+	return z
+}
+
+// Expand returns the full coordinates of the point in the constraint
+// subspace with free variable coordinates z.
+func (m *EqMesh) Expand(z []float64) []float64 {
+	m.check()
+	x := make([]float64, m.ndim)
+	for i, j := range m.free {
+		x[j] = z[i]
+	}
+	for k, j := range m.pivots {
+		x[j] = m.rhs[k] - dot(m.coeffs[k], z)
+	}
+	return x
+}
+
+func (m *EqMesh) Nearest(p []float64) []float64 {
+	return m.Expand(m.Mesh.Nearest(m.Reduce(p)))
+}
+
+func (m *EqMesh) SetOrigin(origin []float64) { m.Mesh.SetOrigin(m.Reduce(origin)) }
+
+func (m *EqMesh) Origin() []float64 {
+	if o := m.Mesh.Origin(); o != nil {
+		return m.Expand(o)
+	}
+	return nil
+}
+
+func (m *EqMesh) Steps() []float64 {
+	m.check()
+	steps := make([]float64, m.ndim)
+	for i, step := range Steps(m.Mesh, len(m.free)) {
+		steps[m.free[i]] = step
+	}
+	return steps
+}
+
+func (m *EqMesh) SetSteps(steps []float64) { SetSteps(m.Mesh, m.Reduce(steps)) }
+
+// check panics if m wasn't created by NewEqMesh rather than silently leaving
+// points unconstrained.
+func (m *EqMesh) check() {
+	if m.free == nil {
+		panic("optim: EqMesh not created with NewEqMesh")
+	}
+}
+
+func dot(a, b []float64) float64 {
+	tot := 0.0
+	for i := range a {
+		tot += a[i] * b[i]
+	}
+	return tot
+}
@@ -84,3 +84,157 @@ func DiffInUlps(x, y float64) uint64 {
 		return yi - xi
 	}
 }
+
+func TestInfMeshAniso(t *testing.T) {
+	m := &InfMesh{StepSize: 2, Scales: []float64{1, 0.25, 0}, Center: []float64{0, 0, 0}}
+	got := m.Nearest([]float64{2.9, 0.8, 0.123})
+	want := []float64{2, 1, 0.123}
+	for i := range want {
+		if diff := DiffInUlps(got[i], want[i]); diff > 1 {
+			t.Errorf("v[%v]: got %v, expected %v", i, got[i], want[i])
+		}
+	}
+
+	// uniform refinement must keep relative scaling
+	m.SetStep(1)
+	for i, want := range []float64{1, 0.25, 0} {
+		if got := m.Steps()[i]; got != want {
+			t.Errorf("step[%v] after SetStep: got %v, expected %v", i, got, want)
+		}
+	}
+
+	var mesh Mesh = &BoxMesh{Mesh: &IntMesh{m}, Lower: []float64{-5, -5, -5}, Upper: []float64{5, 5, 5}}
+	SetSteps(mesh, []float64{4, 0.5, 2})
+	if got := mesh.Step(); got != 4 {
+		t.Errorf("scalar step: got %v, expected 4", got)
+	}
+	for i, want := range []float64{4, 1, 2} {
+		if got := Steps(mesh, 3)[i]; got != want {
+			t.Errorf("int mesh step[%v]: got %v, expected %v", i, got, want)
+		}
+	}
+
+	maxm := &MaxStepMesh{Mesh: &InfMesh{StepSize: 1, Center: []float64{0, 0}}, MaxStep: 2}
+	SetSteps(maxm, []float64{1.5, 3})
+	if got := maxm.Steps(); got[0] != 1.5 || got[1] != 1 {
+		t.Errorf("max step mesh steps: got %v, expected [1.5 1]", got)
+	}
+
+	// the scalar step is the largest per-dimension step even for scales
+	// that aren't normalized
+	scaled := &InfMesh{StepSize: 1, Scales: []float64{2, 0.5}}
+	if got := scaled.Step(); got != 2 {
+		t.Errorf("scaled step: got %v, expected 2", got)
+	}
+	scaled.SetStep(1)
+	if got := scaled.Steps(); got[0] != 1 || got[1] != 0.25 {
+		t.Errorf("scaled steps after SetStep: got %v, expected [1 0.25]", got)
+	}
+
+	// non-aniso meshes use the scalar step for all dimensions
+	if got := Steps(&InfMesh{StepSize: 3}, 2); got[0] != 3 || got[1] != 3 {
+		t.Errorf("isotropic steps: got %v, expected [3 3]", got)
+	}
+}
+
+func TestPolyMesh(t *testing.T) {
+	// triangle: x + y <= 1, x >= 0, y >= 0
+	m := &PolyMesh{
+		Mesh:  &InfMesh{},
+		A:     mat64.NewDense(1, 2, []float64{1, 1}),
+		Low:   mat64.NewDense(1, 1, []float64{math.Inf(-1)}),
+		Up:    mat64.NewDense(1, 1, []float64{1}),
+		Lower: []float64{0, 0},
+		Upper: []float64{math.Inf(1), math.Inf(1)},
+	}
+
+	got := m.Nearest([]float64{2, 2})
+	want := []float64{0.5, 0.5}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-8 {
+			t.Errorf("continuous projection: got %v, expected %v", got, want)
+			break
+		}
+	}
+
+	got = m.Nearest([]float64{-1, 3})
+	want = []float64{0, 1}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-8 {
+			t.Errorf("continuous projection: got %v, expected %v", got, want)
+			break
+		}
+	}
+
+	for _, step := range []float64{0.3, 0.07} {
+		m.SetStep(step)
+		m.SetOrigin([]float64{0.01, 0.02})
+		for _, p := range RandPop(200, []float64{-3, -3}, []float64{3, 3}) {
+			if got := m.Nearest(p.Pos); !m.Feasible(got) {
+				t.Errorf("step %v: point %v projected to infeasible %v", step, p.Pos, got)
+			}
+		}
+	}
+}
+
+func TestEqMesh(t *testing.T) {
+	// x + y + z = 3, x - y = 1 (with a redundant row)
+	A := mat64.NewDense(3, 3, []float64{
+		1, 1, 1,
+		1, -1, 0,
+		2, 0, 1,
+	})
+	b := mat64.NewDense(3, 1, []float64{3, 1, 4})
+	m, err := NewEqMesh(&InfMesh{StepSize: 0.25}, A, b)
+	if err != nil {
+		t.Fatalf("consistent constraints: unexpected error %v", err)
+	}
+	m.SetOrigin([]float64{1, 0, 2})
+
+	feasible := func(x []float64) bool {
+		return math.Abs(x[0]+x[1]+x[2]-3) < 1e-9 && math.Abs(x[0]-x[1]-1) < 1e-9
+	}
+
+	if got := m.Origin(); !feasible(got) {
+		t.Errorf("origin %v is infeasible", got)
+	}
+	if steps := m.Steps(); len(steps) != 3 {
+		t.Errorf("want 3 steps, got %v", steps)
+	}
+
+	for _, p := range RandPop(100, []float64{-5, -5, -5}, []float64{5, 5, 5}) {
+		got := m.Nearest(p.Pos)
+		if !feasible(got) {
+			t.Errorf("point %v mapped to infeasible %v", p.Pos, got)
+		}
+		if again := m.Nearest(got); L2Dist(&Point{Pos: got}, &Point{Pos: again}) > 1e-9 {
+			t.Errorf("Nearest is not idempotent: %v != %v", got, again)
+		}
+	}
+
+	b.Set(2, 0, 5)
+	if _, err := NewEqMesh(&InfMesh{}, A, b); err != ErrInfeasible {
+		t.Errorf("inconsistent constraints: want ErrInfeasible, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("want panic for EqMesh not created with NewEqMesh")
+		}
+	}()
+	(&EqMesh{Mesh: &InfMesh{}}).Nearest([]float64{1, 2, 3})
+}
+
+func TestSplitEqConstr(t *testing.T) {
+	A := mat64.NewDense(3, 2, []float64{1, 1, 1, -1, 0, 1})
+	low := mat64.NewDense(3, 1, []float64{2, -1, 0})
+	up := mat64.NewDense(3, 1, []float64{2, 1, 0})
+
+	Aeq, beq, ineqlow, ineqA, inequp := SplitEqConstr(low, A, up)
+	if r, _ := Aeq.Dims(); r != 2 || beq.At(0, 0) != 2 || beq.At(1, 0) != 0 {
+		t.Errorf("bad equality constraints: %v rows, beq=%v", r, beq)
+	}
+	if r, _ := ineqA.Dims(); r != 1 || ineqA.At(0, 1) != -1 || ineqlow.At(0, 0) != -1 || inequp.At(0, 0) != 1 {
+		t.Errorf("bad inequality constraints")
+	}
+}
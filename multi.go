@@ -0,0 +1,351 @@
+package optim
+
+import (
+	"crypto/sha1"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MultiObjectiver is implemented by problems with several objectives to be
+// minimized simultaneously.  Its Objective method should return a scalar
+// summary of the objectives (e.g. their sum) so that it can be used with
+// single-objective methods and solver best tracking.
+type MultiObjectiver interface {
+	Objectiver
+	// Objectives evaluates the variables in v and returns the value of
+	// each objective.  If the evaluation fails, an error should be returned.
+	Objectives(v []float64) ([]float64, error)
+}
+
+// MultiFunc is a MultiObjectiver whose scalar objective is the sum of its
+// objectives.
+type MultiFunc func([]float64) []float64
+
+func (fn MultiFunc) Objectives(v []float64) ([]float64, error) { return fn(v), nil }
+
+func (fn MultiFunc) Objective(v []float64) (float64, error) {
+	tot := 0.0
+	for _, f := range fn(v) {
+		tot += f
+	}
+	return tot, nil
+}
+
+// ValsRecorder is an Objectiver that records the objective vector of every
+// position it evaluates.  Evalers that keep evaluated points (e.g.
+// CacheEvaler) use it to keep the vectors along with the scalar values.
+type ValsRecorder interface {
+	Objectiver
+	// Vals returns the objective vector recorded for pos or nil if pos
+	// wasn't evaluated successfully.
+	Vals(pos []float64) []float64
+}
+
+// EvalMulti evaluates points with ev using obj, setting both each point's
+// objective vector Vals and its scalar Val.  Points that fail to evaluate
+// have nil Vals.
+func EvalMulti(ev Evaler, obj MultiObjectiver, points ...*Point) (results []*Point, n int, err error) {
+	rec := &multiRecorder{obj: obj, vals: map[[sha1.Size]byte][]float64{}}
+	results, n, err = ev.Eval(rec, points...)
+
+	// evalers return one point per position and caches don't call obj at
+	// all, so copy values from the results to every point at each position
+	byhash := map[[sha1.Size]byte]*Point{}
+	for _, p := range results {
+		if vals := rec.Vals(p.Pos); vals != nil {
+			p.Vals = vals
+		}
+		byhash[p.Hash()] = p
+	}
+	for _, p := range points {
+		r, ok := byhash[p.Hash()]
+		if !ok {
+			p.Vals = nil
+		} else if r != p {
+			p.Val, p.Vals = r.Val, nil
+			if r.Vals != nil {
+				p.Vals = append([]float64{}, r.Vals...)
+			}
+		}
+	}
+	return results, n, err
+}
+
+// multiRecorder is a ValsRecorder for a MultiObjectiver.
+type multiRecorder struct {
+	obj  MultiObjectiver
+	vals map[[sha1.Size]byte][]float64
+	mu   sync.Mutex
+}
+
+func (r *multiRecorder) Objective(v []float64) (float64, error) {
+	vals, err := r.obj.Objectives(v)
+	if err != nil {
+		return math.Inf(1), err
+	}
+	val, err := r.obj.Objective(v)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.vals[(&Point{Pos: v}).Hash()] = vals
+	return val, err
+}
+
+func (r *multiRecorder) Vals(pos []float64) []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.vals[(&Point{Pos: pos}).Hash()]
+}
+
+// Dominates returns true if a is at least as good as b in every objective
+// and strictly better in at least one.  Points without objective vectors are
+// dominated by every point with one.
+func Dominates(a, b *Point) bool {
+	if a.Vals == nil {
+		return false
+	} else if b.Vals == nil {
+		return true
+	}
+
+	better := false
+	for i := range a.Vals {
+		if a.Vals[i] > b.Vals[i] {
+			return false
+		} else if a.Vals[i] < b.Vals[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// NondominatedSort partitions points into successive Pareto fronts using the
+// fast non-dominated sort from NSGA-II.  The first front contains the points
+// not dominated by any other, the second those dominated only by points in
+// the first, and so on.
+func NondominatedSort(points []*Point) [][]*Point {
+	ndominating := make([]int, len(points))
+	dominated := make([][]int, len(points))
+	front := []int{}
+	for i, p := range points {
+		for j, q := range points {
+			if Dominates(p, q) {
+				dominated[i] = append(dominated[i], j)
+			} else if Dominates(q, p) {
+				ndominating[i]++
+			}
+		}
+		if ndominating[i] == 0 {
+			front = append(front, i)
+		}
+	}
+
+	fronts := [][]*Point{}
+	for len(front) > 0 {
+		pts := make([]*Point, len(front))
+		next := []int{}
+		for k, i := range front {
+			pts[k] = points[i]
+			for _, j := range dominated[i] {
+				ndominating[j]--
+				if ndominating[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		fronts = append(fronts, pts)
+		front = next
+	}
+	return fronts
+}
+
+// Crowding returns the crowding distance of each point in front - the sum
+// over the objectives of the normalized distance between each point's
+// neighbors.  Boundary points have infinite crowding distance.
+func Crowding(front []*Point) []float64 {
+	dist := make([]float64, len(front))
+	if len(front) == 0 || front[0].Vals == nil {
+		return dist
+	}
+
+	order := make([]int, len(front))
+	for obj := range front[0].Vals {
+		for i := range order {
+			order[i] = i
+		}
+		sort.Sort(byobj{order, front, obj})
+
+		lo, hi := front[order[0]].Vals[obj], front[order[len(order)-1]].Vals[obj]
+		dist[order[0]], dist[order[len(order)-1]] = math.Inf(1), math.Inf(1)
+		if hi-lo == 0 || math.IsInf(hi-lo, 0) || math.IsNaN(hi-lo) {
+			continue
+		}
+		for k := 1; k < len(order)-1; k++ {
+			dist[order[k]] += (front[order[k+1]].Vals[obj] - front[order[k-1]].Vals[obj]) / (hi - lo)
+		}
+	}
+	return dist
+}
+
+type byobj struct {
+	order []int
+	pts   []*Point
+	obj   int
+}
+
+func (b byobj) Len() int { return len(b.order) }
+func (b byobj) Less(i, j int) bool {
+	return b.pts[b.order[i]].Vals[b.obj] < b.pts[b.order[j]].Vals[b.obj]
+}
+func (b byobj) Swap(i, j int) { b.order[i], b.order[j] = b.order[j], b.order[i] }
+
+// Archive maintains a set of mutually non-dominated points.
+type Archive struct {
+	// Max is the maximum number of points kept.  When exceeded, the most
+	// crowded points are removed one at a time.  Zero means no limit.
+	Max    int
+	points []*Point
+}
+
+// Add adds each point not dominated by (or equal to) an archived point and
+// removes the archived points it dominates.  It returns the number of points
+// added.
+func (a *Archive) Add(points ...*Point) (nadded int) {
+	for _, p := range points {
+		if p.Vals == nil || a.covered(p) {
+			continue
+		}
+		keep := a.points[:0]
+		for _, q := range a.points {
+			if !Dominates(p, q) {
+				keep = append(keep, q)
+			}
+		}
+		a.points = append(keep, p.Clone())
+		nadded++
+	}
+
+	for a.Max > 0 && len(a.points) > a.Max {
+		dist := Crowding(a.points)
+		worst := 0
+		for i, d := range dist {
+			if d < dist[worst] {
+				worst = i
+			}
+		}
+		a.points = append(a.points[:worst], a.points[worst+1:]...)
+	}
+	return nadded
+}
+
+// covered returns true if an archived point dominates p or has the same
+// objective values.
+func (a *Archive) covered(p *Point) bool {
+	for _, q := range a.points {
+		if Dominates(q, p) || samevals(q.Vals, p.Vals) {
+			return true
+		}
+	}
+	return false
+}
+
+func samevals(a, b []float64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Points returns the archived points.
+func (a *Archive) Points() []*Point { return a.points }
+
+// Hypervolume returns the volume of objective space dominated by front and
+// bounded by the reference point ref.  Points that don't dominate ref
+// contribute nothing.  The volume is computed exactly by slicing along one
+// objective at a time which is fast for two or three objectives but grows
+// exponentially with more.
+func Hypervolume(front []*Point, ref []float64) float64 {
+	vals := [][]float64{}
+	for _, p := range front {
+		if p.Vals == nil {
+			continue
+		}
+		inside := true
+		for i, v := range p.Vals {
+			if v >= ref[i] {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			vals = append(vals, p.Vals)
+		}
+	}
+	return hv(vals, ref)
+}
+
+// hv slices the region dominated by vals along the last objective - each
+// slab's volume is its thickness times the hypervolume of the points below
+// it in the remaining objectives.
+func hv(vals [][]float64, ref []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	d := len(ref) - 1
+	if d == 0 {
+		min := ref[0]
+		for _, v := range vals {
+			min = math.Min(min, v[0])
+		}
+		return ref[0] - min
+	}
+
+	sorted := append([][]float64{}, vals...)
+	sort.Sort(bydim{sorted, d})
+
+	vol := 0.0
+	for i := range sorted {
+		top := ref[d]
+		if i+1 < len(sorted) {
+			top = sorted[i+1][d]
+		}
+		if h := top - sorted[i][d]; h > 0 {
+			vol += h * hv(sorted[:i+1], ref[:d])
+		}
+	}
+	return vol
+}
+
+type bydim struct {
+	vals [][]float64
+	dim  int
+}
+
+func (b bydim) Len() int           { return len(b.vals) }
+func (b bydim) Less(i, j int) bool { return b.vals[i][b.dim] < b.vals[j][b.dim] }
+func (b bydim) Swap(i, j int)      { b.vals[i], b.vals[j] = b.vals[j], b.vals[i] }
+
+// IGD returns the inverted generational distance of front from the
+// reference front ref (e.g. samples of the true Pareto front) - the mean
+// Euclidean distance from each reference point to its nearest point in
+// front.  Lower is better and zero means front covers ref.
+func IGD(front []*Point, ref [][]float64) float64 {
+	tot := 0.0
+	for _, r := range ref {
+		min := math.Inf(1)
+		for _, p := range front {
+			if p.Vals == nil {
+				continue
+			}
+			d := 0.0
+			for i := range r {
+				d += (p.Vals[i] - r[i]) * (p.Vals[i] - r[i])
+			}
+			min = math.Min(min, math.Sqrt(d))
+		}
+		tot += min
+	}
+	return tot / float64(len(ref))
+}
@@ -0,0 +1,183 @@
+package optim
+
+import (
+	"math"
+	"testing"
+)
+
+func vpoints(vals ...[]float64) []*Point {
+	pts := make([]*Point, len(vals))
+	for i, v := range vals {
+		pts[i] = &Point{Pos: []float64{float64(i)}, Vals: v}
+	}
+	return pts
+}
+
+func TestDominates(t *testing.T) {
+	tests := []struct {
+		A, B []float64
+		Want bool
+	}{
+		{[]float64{1, 1}, []float64{2, 2}, true},
+		{[]float64{1, 2}, []float64{2, 2}, true},
+		{[]float64{2, 2}, []float64{2, 2}, false},
+		{[]float64{1, 3}, []float64{2, 2}, false},
+		{[]float64{1, 3}, nil, true},
+		{nil, []float64{1, 3}, false},
+	}
+	for _, test := range tests {
+		a, b := &Point{Vals: test.A}, &Point{Vals: test.B}
+		if got := Dominates(a, b); got != test.Want {
+			t.Errorf("Dominates(%v, %v): want %v, got %v", test.A, test.B, test.Want, got)
+		}
+	}
+}
+
+func TestNondominatedSort(t *testing.T) {
+	pts := vpoints(
+		[]float64{3, 3}, // front 2
+		[]float64{1, 4}, // front 0
+		[]float64{2, 2}, // front 0
+		[]float64{4, 1}, // front 0
+		[]float64{3, 2}, // front 1
+		[]float64{4, 4}, // front 3
+	)
+	want := [][]float64{{1, 2, 3}, {4}, {0}, {5}}
+
+	fronts := NondominatedSort(pts)
+	if len(fronts) != len(want) {
+		t.Fatalf("want %v fronts, got %v", len(want), len(fronts))
+	}
+	for i, front := range fronts {
+		got := []float64{}
+		for _, p := range front {
+			got = append(got, p.Pos[0])
+		}
+		if !samevals(got, want[i]) || len(got) != len(want[i]) {
+			t.Errorf("front %v: want points %v, got %v", i, want[i], got)
+		}
+	}
+}
+
+func TestCrowding(t *testing.T) {
+	front := vpoints([]float64{0, 4}, []float64{1, 3}, []float64{3, 1}, []float64{4, 0})
+	want := []float64{math.Inf(1), 1.5, 1.5, math.Inf(1)}
+	got := Crowding(front)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("want %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	a := &Archive{}
+	if n := a.Add(vpoints([]float64{2, 2}, []float64{3, 3}, []float64{2, 2})...); n != 1 {
+		t.Errorf("want 1 point added, got %v", n)
+	}
+	if n := a.Add(vpoints([]float64{1, 3}, []float64{1, 1})...); n != 2 {
+		t.Errorf("want 2 points added, got %v", n)
+	} else if len(a.Points()) != 1 || !samevals(a.Points()[0].Vals, []float64{1, 1}) {
+		t.Errorf("want only [1 1] archived, got %v points", len(a.Points()))
+	}
+
+	a = &Archive{Max: 3}
+	a.Add(vpoints([]float64{0, 4}, []float64{1, 3}, []float64{1.2, 2.8}, []float64{3, 1}, []float64{4, 0})...)
+	if len(a.Points()) != 3 {
+		t.Fatalf("want 3 points, got %v", len(a.Points()))
+	}
+	for _, p := range a.Points() {
+		if p.Vals[0] == 1 || p.Vals[0] == 1.2 {
+			// one of the crowded pair is kept
+			return
+		}
+	}
+	t.Errorf("pruning removed both crowded points")
+}
+
+func TestHypervolume(t *testing.T) {
+	tests := []struct {
+		Front [][]float64
+		Ref   []float64
+		Want  float64
+	}{
+		{[][]float64{{1, 3}, {2, 2}, {3, 1}}, []float64{4, 4}, 6},
+		{[][]float64{{1, 3}, {2, 2}, {3, 1}, {3, 3}, {5, 0}}, []float64{4, 4}, 6},
+		{[][]float64{{0, 0, 0}}, []float64{1, 1, 1}, 1},
+		{[][]float64{{0, 0, 0.5}, {0.5, 0.5, 0}}, []float64{1, 1, 1}, 0.625},
+	}
+	for _, test := range tests {
+		if got := Hypervolume(vpoints(test.Front...), test.Ref); math.Abs(got-test.Want) > 1e-12 {
+			t.Errorf("front %v: want %v, got %v", test.Front, test.Want, got)
+		}
+	}
+}
+
+func TestIGD(t *testing.T) {
+	ref := [][]float64{{0, 1}, {1, 0}}
+	if got := IGD(vpoints(ref...), ref); got != 0 {
+		t.Errorf("want 0 for the reference front itself, got %v", got)
+	}
+	if got := IGD(vpoints([]float64{0, 1}), ref); math.Abs(got-math.Sqrt2/2) > 1e-12 {
+		t.Errorf("want %v, got %v", math.Sqrt2/2, got)
+	}
+}
+
+func TestEvalMulti(t *testing.T) {
+	obj := MultiFunc(func(x []float64) []float64 { return []float64{x[0], 1 - x[0]*x[0]} })
+	pts := []*Point{{Pos: []float64{0.5}}, {Pos: []float64{2}}, {Pos: []float64{0.5}}}
+	_, n, err := EvalMulti(ParallelEvaler{}, obj, pts...)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Errorf("want 2 evals, got %v", n)
+	}
+
+	for _, p := range pts {
+		want := []float64{p.Pos[0], 1 - p.Pos[0]*p.Pos[0]}
+		if p.Vals == nil || !samevals(p.Vals, want) {
+			t.Errorf("%v: want objectives %v, got %v", p.Pos, want, p.Vals)
+		}
+	}
+	if pts[1].Val != -1 {
+		t.Errorf("want scalar objective -1, got %v", pts[1].Val)
+	}
+	if pts[2].Val != pts[0].Val {
+		t.Errorf("duplicate point: want scalar objective %v, got %v", pts[0].Val, pts[2].Val)
+	}
+}
+
+func TestEvalMultiCache(t *testing.T) {
+	obj := MultiFunc(func(x []float64) []float64 { return []float64{x[0], 1 - x[0]*x[0]} })
+	ev := NewCacheEvaler(SerialEvaler{})
+	if _, _, err := EvalMulti(ev, obj, &Point{Pos: []float64{2}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Point{Pos: []float64{2}}
+	_, n, err := EvalMulti(ev, obj, p)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Errorf("want cache hit, got %v evals", n)
+	}
+	if want := []float64{2, -3}; p.Vals == nil || !samevals(p.Vals, want) {
+		t.Errorf("cache hit: want objectives %v, got %v", want, p.Vals)
+	}
+	if p.Val != -1 {
+		t.Errorf("cache hit: want scalar objective -1, got %v", p.Val)
+	}
+
+	// positions cached by scalar evaluations have no objective vector
+	ev.Eval(obj, &Point{Pos: []float64{3}})
+	p = &Point{Pos: []float64{3}}
+	if _, n, err = EvalMulti(ev, obj, p); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("want scalar-only cache entry evaluated again, got %v evals", n)
+	}
+	if want := []float64{3, -8}; p.Vals == nil || !samevals(p.Vals, want) {
+		t.Errorf("scalar-only cache entry: want objectives %v, got %v", want, p.Vals)
+	}
+}
@@ -0,0 +1,539 @@
+// Package neldermead provides a Nelder-Mead downhill simplex method.  The
+// simplex is updated using reflection, expansion, contraction and shrink
+// operations as described in:
+//
+//     Nelder, John A., and Roger Mead. "A simplex method for function
+//     minimization." The Computer Journal 7.4 (1965): 308-313.
+//
+// Optionally, the dimension dependent parameters from the following can be
+// used to improve performance for high dimensional problems:
+//
+//     Gao, Fuchang, and Lixing Han. "Implementing the Nelder-Mead simplex
+//     algorithm with adaptive parameters." Computational Optimization and
+//     Applications 51.1 (2012): 259-277.
+//
+// All trial points are projected onto the mesh passed to Iterate, so the
+// method can be used in combination with e.g. optim.BoxMesh to respect bound
+// constraints.  Because every iteration is a complete simplex update, the
+// method can also be used as a search step for pattern search via
+// pattern.SearchMethod.
+package neldermead
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblSimplex is the name of the sql database table that contains the
+	// simplex vertices at each iteration.
+	TblSimplex = "nmsimplex"
+	// TblInfo is the name of the sql database table that contains the
+	// operation performed, the simplex size, and the best point for each
+	// iteration.
+	TblInfo = "nminfo"
+)
+
+// Standard Nelder-Mead parameters.
+const (
+	DefaultReflect  = 1.0
+	DefaultExpand   = 2.0
+	DefaultContract = 0.5
+	DefaultShrink   = 0.5
+)
+
+// DefaultDegenTol is the default threshold for the normalized simplex volume
+// (see Method.DegenTol) below which the simplex is restarted.
+const DefaultDegenTol = 1e-10
+
+// Operation names recorded in the info database table.
+const (
+	OpInit     = "init"
+	OpReflect  = "reflect"
+	OpExpand   = "expand"
+	OpContract = "contract"
+	OpShrink   = "shrink"
+	OpRestart  = "restart"
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Coeffs sets the reflection, expansion, contraction and shrink coefficients.
+func Coeffs(reflect, expand, contract, shrink float64) Option {
+	return func(m *Method) {
+		m.Reflect, m.Expand, m.Contract, m.Shrink = reflect, expand, contract, shrink
+	}
+}
+
+// Adaptive sets the method to use the dimension dependent coefficients of
+// Gao and Han.  These behave identically to the standard coefficients for two
+// dimensions and significantly improve convergence for higher dimensions.
+func Adaptive(m *Method) { m.adaptive = true }
+
+// InitStep sets the edge length of the initial simplex.
+func InitStep(step float64) Option { return func(m *Method) { m.InitStep = step } }
+
+// DegenTol sets the normalized simplex volume below which the simplex is
+// considered degenerate and restarted.  A negative value disables restarts.
+func DegenTol(tol float64) Option { return func(m *Method) { m.DegenTol = tol } }
+
+// Simplex sets the initial simplex vertices.  There must be one more vertex
+// than the number of dimensions.  Vertices with infinite values are evaluated
+// on the first iteration.
+func Simplex(vertices []*optim.Point) Option {
+	return func(m *Method) { m.Simplex = vertices }
+}
+
+type Method struct {
+	// Simplex holds the n+1 simplex vertices sorted from best to worst
+	// after each iteration.  It is generated around the start point on the
+	// first iteration if nil.
+	Simplex  []*optim.Point
+	Reflect  float64
+	Expand   float64
+	Contract float64
+	Shrink   float64
+	// InitStep is the edge length of the initial and restarted simplexes.
+	// If zero, the mesh step size is used.  If the mesh step is also zero,
+	// each edge is 5% of the corresponding start point coordinate (or
+	// .00025 for zero coordinates).
+	InitStep float64
+	// DegenTol is the threshold for the simplex volume normalized by the
+	// product of its edge lengths (a value in [0,1]) below which the simplex
+	// is considered degenerate.  Degenerate simplexes - including ones that
+	// collapsed to a single point due to mesh projection - are restarted as
+	// an axis-aligned simplex around the best vertex with edge length equal
+	// to the shortest nonzero edge from the best vertex (or the mesh step if
+	// larger).  If zero, DefaultDegenTol is used.  Negative values disable
+	// restarts.
+	DegenTol float64
+	// Nrestart is the number of restarts performed so far.
+	Nrestart int
+	Db       *sql.DB
+	adaptive bool
+	start    *optim.Point
+	ev       optim.Evaler
+	iter     int
+	lastop   string
+	// pending holds the simplex vertices that haven't been evaluated yet.
+	pending []*optim.Point
+}
+
+// New creates a Nelder-Mead method with the initial simplex built around
+// start.
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		start:    start,
+		ev:       optim.SerialEvaler{},
+		Reflect:  DefaultReflect,
+		Expand:   DefaultExpand,
+		Contract: DefaultContract,
+		Shrink:   DefaultShrink,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.adaptive {
+		n := float64(start.Len())
+		m.Reflect = 1
+		m.Expand = 1 + 2/n
+		m.Contract = 0.75 - 1/(2*n)
+		m.Shrink = 1 - 1/n
+	}
+
+	m.initdb()
+	return m
+}
+
+// Best returns the best vertex of the simplex.
+func (m *Method) Best() *optim.Point {
+	if len(m.Simplex) == 0 {
+		return m.start
+	}
+	return m.Simplex[0]
+}
+
+// AddPoint replaces the worst simplex vertex with p if p is better and not
+// already a vertex.
+func (m *Method) AddPoint(p *optim.Point) {
+	if len(m.Simplex) == 0 {
+		if p.Val < m.start.Val {
+			m.start = p
+		}
+		return
+	}
+
+	h := p.Hash()
+	for _, v := range m.Simplex {
+		if v.Hash() == h {
+			return
+		}
+	}
+
+	worst := len(m.Simplex) - 1
+	if p.Val < m.Simplex[worst].Val {
+		m.Simplex[worst] = p
+		sort.Sort(byval(m.Simplex))
+	}
+}
+
+// Diameter returns the largest distance between any two simplex vertices.
+func (m *Method) Diameter() float64 {
+	diam := 0.0
+	for i, p := range m.Simplex {
+		for _, q := range m.Simplex[i+1:] {
+			diam = math.Max(diam, optim.L2Dist(p, q))
+		}
+	}
+	return diam
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer func() { m.updateDb() }()
+
+	if len(m.Simplex) == 0 {
+		m.lastop = OpInit
+		m.Simplex = m.initSimplex(m.start, mesh, m.initSteps(m.start, mesh))
+	}
+	if m.iter == 1 {
+		// vertices of the initial simplex with infinite values are
+		// unevaluated - later vertices are marked explicitly since
+		// infeasible points may legitimately have infinite values
+		for _, p := range m.Simplex {
+			if math.IsInf(p.Val, 1) {
+				m.pending = append(m.pending, p)
+			}
+		}
+	}
+
+	if unknown := m.stillPending(); len(unknown) > 0 {
+		n, err = m.evalVertices(obj, unknown...)
+		if m.lastop == OpInit {
+			return m.Simplex[0], n, err
+		}
+	}
+
+	if m.degenerate() {
+		m.restart(mesh)
+		m.lastop = OpRestart
+		var nrestart int
+		var err2 error
+		nrestart, err2 = m.evalVertices(obj, m.Simplex[1:]...)
+		return m.Simplex[0], n + nrestart, collect(err, err2)
+	}
+
+	nv := len(m.Simplex)
+	bestv, worst, second := m.Simplex[0], m.Simplex[nv-1], m.Simplex[nv-2]
+	centroid := m.centroid()
+
+	eval := func(coeff float64) (*optim.Point, error) {
+		pos := make([]float64, len(centroid))
+		for i := range pos {
+			pos[i] = centroid[i] + coeff*(centroid[i]-worst.Pos[i])
+		}
+		p := &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+		_, neval, err := m.ev.Eval(obj, p)
+		n += neval
+		return p, err
+	}
+
+	refl, err2 := eval(m.Reflect)
+	err = collect(err, err2)
+	switch {
+	case refl.Val < bestv.Val:
+		exp, err2 := eval(m.Reflect * m.Expand)
+		err = collect(err, err2)
+		if exp.Val < refl.Val {
+			m.replaceWorst(exp, OpExpand)
+		} else {
+			m.replaceWorst(refl, OpReflect)
+		}
+	case refl.Val < second.Val:
+		m.replaceWorst(refl, OpReflect)
+	default:
+		// outside contraction if the reflection is better than the worst
+		// vertex, otherwise inside contraction.
+		coeff, target := m.Reflect*m.Contract, refl
+		if refl.Val >= worst.Val {
+			coeff, target = -m.Contract, worst
+		}
+		con, err2 := eval(coeff)
+		err = collect(err, err2)
+		if con.Val < target.Val {
+			m.replaceWorst(con, OpContract)
+		} else {
+			var nshrink int
+			nshrink, err2 = m.shrink(obj, mesh)
+			n += nshrink
+			err = collect(err, err2)
+		}
+	}
+
+	return m.Simplex[0], n, err
+}
+
+// initSteps returns the initial simplex edge length for each dimension.
+func (m *Method) initSteps(start *optim.Point, mesh optim.Mesh) []float64 {
+	steps := make([]float64, start.Len())
+	for i, x := range start.Pos {
+		switch {
+		case m.InitStep != 0:
+			steps[i] = m.InitStep
+		case mesh.Step() != 0:
+			steps[i] = mesh.Step()
+		case x != 0:
+			steps[i] = 0.05 * x
+		default:
+			steps[i] = 0.00025
+		}
+	}
+	return steps
+}
+
+// initSimplex creates an axis-aligned simplex with center as the first
+// vertex.  Only center keeps its value - the other vertices are unevaluated.
+func (m *Method) initSimplex(center *optim.Point, mesh optim.Mesh, steps []float64) []*optim.Point {
+	first := center.Clone()
+	if math.IsInf(first.Val, 1) {
+		first.Pos = mesh.Nearest(first.Pos)
+	}
+	simplex := []*optim.Point{first}
+	for i := range first.Pos {
+		pos := append([]float64{}, first.Pos...)
+		pos[i] += steps[i]
+		p := &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+		if optim.L2Dist(p, first) == 0 {
+			// projection moved the vertex back - try the other direction
+			pos[i] = first.Pos[i] - steps[i]
+			p.Pos = mesh.Nearest(pos)
+		}
+		simplex = append(simplex, p)
+	}
+	return simplex
+}
+
+func (m *Method) restart(mesh optim.Mesh) {
+	// use the shortest nonzero edge from the best vertex as the new size -
+	// degenerate simplexes are often very elongated.
+	size := math.Inf(1)
+	for _, p := range m.Simplex[1:] {
+		if d := optim.L2Dist(m.Simplex[0], p); d > 0 {
+			size = math.Min(size, d)
+		}
+	}
+	size = math.Max(size, mesh.Step())
+
+	steps := m.initSteps(m.Simplex[0], mesh)
+	if !math.IsInf(size, 1) {
+		for i := range steps {
+			steps[i] = size
+		}
+	}
+	m.Simplex = m.initSimplex(m.Simplex[0], mesh, steps)
+	m.Nrestart++
+}
+
+// degenerate returns true if the simplex volume normalized by the product of
+// its edge lengths from the best vertex is below DegenTol.
+func (m *Method) degenerate() bool {
+	tol := m.DegenTol
+	if tol < 0 {
+		return false
+	} else if tol == 0 {
+		tol = DefaultDegenTol
+	}
+
+	ndim := m.Simplex[0].Len()
+	edges := make([][]float64, ndim)
+	ratio := 1.0
+	for i := range edges {
+		edges[i] = make([]float64, ndim)
+		l := 0.0
+		for j := range edges[i] {
+			edges[i][j] = m.Simplex[i+1].Pos[j] - m.Simplex[0].Pos[j]
+			l += edges[i][j] * edges[i][j]
+		}
+		if l == 0 {
+			return true
+		}
+		ratio /= math.Sqrt(l)
+	}
+	return math.Abs(det(edges))*ratio < tol
+}
+
+func (m *Method) centroid() []float64 {
+	nv := len(m.Simplex)
+	c := make([]float64, m.Simplex[0].Len())
+	for _, p := range m.Simplex[:nv-1] {
+		for i, x := range p.Pos {
+			c[i] += x / float64(nv-1)
+		}
+	}
+	return c
+}
+
+func (m *Method) replaceWorst(p *optim.Point, op string) {
+	m.Simplex[len(m.Simplex)-1] = p
+	sort.Sort(byval(m.Simplex))
+	m.lastop = op
+}
+
+// shrink moves all vertices toward the best vertex.
+func (m *Method) shrink(obj optim.Objectiver, mesh optim.Mesh) (n int, err error) {
+	best := m.Simplex[0]
+	for i, p := range m.Simplex[1:] {
+		pos := make([]float64, len(p.Pos))
+		for j := range pos {
+			pos[j] = best.Pos[j] + m.Shrink*(p.Pos[j]-best.Pos[j])
+		}
+		m.Simplex[i+1] = &optim.Point{Pos: mesh.Nearest(pos), Val: math.Inf(1)}
+	}
+	n, err = m.evalVertices(obj, m.Simplex[1:]...)
+	m.lastop = OpShrink
+	return n, err
+}
+
+// evalVertices evaluates the simplex vertices vs and re-sorts the simplex.
+// Vertices the evaler skipped (e.g. after an error) remain pending.
+func (m *Method) evalVertices(obj optim.Objectiver, vs ...*optim.Point) (n int, err error) {
+	results, n, err := m.ev.Eval(obj, vs...)
+	done := map[*optim.Point]bool{}
+	for _, p := range results {
+		done[p] = true
+	}
+	m.pending = nil
+	for _, p := range vs {
+		if !done[p] {
+			m.pending = append(m.pending, p)
+		}
+	}
+	sort.Sort(byval(m.Simplex))
+	return n, err
+}
+
+// stillPending returns the pending vertices that are still in the simplex.
+func (m *Method) stillPending() []*optim.Point {
+	pending := []*optim.Point{}
+	for _, p := range m.pending {
+		for _, v := range m.Simplex {
+			if v == p {
+				pending = append(pending, p)
+				break
+			}
+		}
+	}
+	return pending
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblSimplex + " (iter INTEGER,vertex INTEGER,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblInfo + " (iter INTEGER,op TEXT,diameter REAL,val REAL,posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s1 := "INSERT INTO " + TblSimplex + " (iter,vertex,val,posid) VALUES (?,?,?,?);"
+	for i, p := range m.Simplex {
+		_, err := tx.Exec(s1, m.iter, i, p.Val, p.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+	}
+
+	glob := m.Best()
+	s2 := "INSERT INTO " + TblInfo + " (iter,op,diameter,val,posid) VALUES (?,?,?,?,?);"
+	_, err = tx.Exec(s2, m.iter, m.lastop, m.Diameter(), glob.Val, glob.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.Simplex...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+// det computes the determinant of the square matrix a (which is modified)
+// using Gaussian elimination with partial pivoting.
+func det(a [][]float64) float64 {
+	d := 1.0
+	n := len(a)
+	for j := 0; j < n; j++ {
+		piv := j
+		for i := j + 1; i < n; i++ {
+			if math.Abs(a[i][j]) > math.Abs(a[piv][j]) {
+				piv = i
+			}
+		}
+		if a[piv][j] == 0 {
+			return 0
+		} else if piv != j {
+			a[piv], a[j] = a[j], a[piv]
+			d = -d
+		}
+		d *= a[j][j]
+		for i := j + 1; i < n; i++ {
+			f := a[i][j] / a[j][j]
+			for k := j; k < n; k++ {
+				a[i][k] -= f * a[j][k]
+			}
+		}
+	}
+	return d
+}
+
+type byval []*optim.Point
+
+func (b byval) Len() int           { return len(b) }
+func (b byval) Less(i, j int) bool { return b[i].Val < b[j].Val }
+func (b byval) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func collect(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("neldermead: db write failed -", err)
+		return true
+	}
+	return false
+}
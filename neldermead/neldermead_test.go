@@ -0,0 +1,195 @@
+package neldermead
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+	"github.com/baaaaam/optim/pattern"
+)
+
+func TestRosenbrock(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, InitStep(0.5)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 2000,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if solv.Best().Val > 1e-8 {
+		t.Errorf("want ~0, got %v", solv.Best().Val)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	ndim := 12
+	sphere := func(x []float64) float64 {
+		tot := 0.0
+		for i, v := range x {
+			tot += float64(i+1) * (v - 1) * (v - 1)
+		}
+		return tot
+	}
+
+	run := func(opts ...Option) *optim.Solver {
+		start := &optim.Point{Pos: make([]float64, ndim), Val: math.Inf(1)}
+		opts = append(opts, InitStep(1))
+		solv := &optim.Solver{
+			Method:  New(start, opts...),
+			Obj:     optim.Func(sphere),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 20000,
+		}
+		solv.Run()
+		return solv
+	}
+
+	std := run()
+	adapt := run(Adaptive)
+	t.Logf("[INFO] standard: %v evals, got %v", std.Neval(), std.Best().Val)
+	t.Logf("[INFO] adaptive: %v evals, got %v", adapt.Neval(), adapt.Best().Val)
+	if adapt.Best().Val > 1e-6 {
+		t.Errorf("adaptive: want ~0, got %v", adapt.Best().Val)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	// start with a flat simplex that can't reach the optimum at (1, 1)
+	fn := func(x []float64) float64 {
+		a, b := x[0]-1, x[1]-1
+		return a*a + b*b
+	}
+	simplex := []*optim.Point{
+		{Pos: []float64{0, 0}, Val: math.Inf(1)},
+		{Pos: []float64{1, 0}, Val: math.Inf(1)},
+		{Pos: []float64{2, 0}, Val: math.Inf(1)},
+	}
+	m := New(simplex[0], Simplex(simplex))
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn),
+		Mesh:    &optim.InfMesh{},
+		MaxEval: 500,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals, %v restarts: got %v", solv.Neval(), m.Nrestart, solv.Best())
+	if m.Nrestart == 0 {
+		t.Errorf("degenerate simplex was not restarted")
+	}
+	if solv.Best().Val > 1e-8 {
+		t.Errorf("want ~0, got %v", solv.Best().Val)
+	}
+}
+
+func TestInfeasibleVertices(t *testing.T) {
+	// every point is infeasible - vertices must not be re-evaluated just
+	// because their values are infinite
+	seen := map[[2]float64]int{}
+	obj := optim.Func(func(x []float64) float64 {
+		seen[[2]float64{x[0], x[1]}]++
+		return math.Inf(1)
+	})
+	start := &optim.Point{Pos: []float64{1, 1}, Val: math.Inf(1)}
+	m := New(start, InitStep(0.5))
+	for i := 0; i < 20; i++ {
+		m.Iterate(obj, &optim.InfMesh{})
+	}
+
+	for pos, count := range seen {
+		if count > 1 {
+			t.Errorf("vertex %v evaluated %v times", pos, count)
+		}
+	}
+}
+
+func TestBoxMesh(t *testing.T) {
+	// unconstrained optimum at (-3, -3) lies outside the bounds
+	fn := func(x []float64) float64 {
+		a, b := x[0]+3, x[1]+3
+		return a*a + b*b
+	}
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		return fn(x)
+	})
+
+	start := &optim.Point{Pos: []float64{0.5, 0.5}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, InitStep(0.3)),
+		Obj:     obj,
+		Mesh:    &optim.BoxMesh{Mesh: &optim.InfMesh{}, Lower: low, Upper: up},
+		MaxEval: 500,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if outside > 0 {
+		t.Errorf("%v points evaluated outside bounds", outside)
+	}
+	if math.Abs(solv.Best().Pos[0]+1) > 1e-6 || math.Abs(solv.Best().Pos[1]+1) > 1e-6 {
+		t.Errorf("want [-1 -1], got %v", solv.Best().Pos)
+	}
+}
+
+func TestSearcher(t *testing.T) {
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	mesh := &optim.InfMesh{StepSize: 1}
+	mesh.SetOrigin(start.Pos)
+
+	solv := &optim.Solver{
+		Method:  pattern.New(start, pattern.SearchMethod(New(start.Clone()), pattern.Share)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    mesh,
+		MaxEval: 20000,
+		MinStep: 1e-10,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if solv.Best().Val > 1e-6 {
+		t.Errorf("want ~0, got %v", solv.Best().Val)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 50,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblSimplex, TblInfo} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
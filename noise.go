@@ -0,0 +1,232 @@
+package optim
+
+import (
+	"crypto/sha1"
+	"math"
+)
+
+const (
+	// DefaultNinit is the default number of samples a ResampleEvaler takes
+	// of each new point - two is the minimum for a variance estimate.
+	DefaultNinit = 2
+	// DefaultNmax is the default maximum number of samples a
+	// ResampleEvaler takes of any point.
+	DefaultNmax = 32
+	// DefaultZ is the default number of standard errors by which two means
+	// must differ for a ResampleEvaler to consider their comparison settled
+	// (about 95% confidence).
+	DefaultZ = 1.96
+)
+
+// AddSample adds an objective sample v to p updating its mean Val, Nsample
+// and M2 using Welford's algorithm.  If p has not been sampled before (i.e.
+// Nsample is zero), any existing Val is discarded.
+func (p *Point) AddSample(v float64) {
+	if p.Nsample == 0 {
+		p.Val, p.M2 = 0, 0
+	}
+	p.Nsample++
+	delta := v - p.Val
+	p.Val += delta / float64(p.Nsample)
+	p.M2 += delta * (v - p.Val)
+}
+
+// Variance returns the sample variance of p's objective samples - zero if p
+// has fewer than two samples.
+func (p *Point) Variance() float64 {
+	if p.Nsample < 2 {
+		return 0
+	}
+	return p.M2 / float64(p.Nsample-1)
+}
+
+// StdErr returns the standard error of p's mean objective value - zero if p
+// has fewer than two samples.
+func (p *Point) StdErr() float64 { return stderr(p, 0) }
+
+// stderr returns the standard error of p's mean objective value using noise
+// as a prior estimate of the noise standard deviation: it is used instead of
+// the sample standard deviation for points with fewer than two samples and
+// as a lower bound otherwise.  Points without samples have no standard
+// error.
+func stderr(p *Point, noise float64) float64 {
+	if p.Nsample == 0 {
+		return 0
+	}
+	sd := noise
+	if p.Nsample >= 2 {
+		sd = math.Max(sd, math.Sqrt(p.Variance()))
+	}
+	return sd / math.Sqrt(float64(p.Nsample))
+}
+
+// Better returns true if a's value is lower than b's by more than z
+// combined standard errors.  noise is a prior estimate of the noise standard
+// deviation as for ResampleEvaler.Noise - zero for none.  With z equal to
+// zero (or for points without sample statistics) this is simply
+// a.Val < b.Val.
+func Better(a, b *Point, z, noise float64) bool {
+	sea, seb := stderr(a, noise), stderr(b, noise)
+	return a.Val < b.Val-z*math.Sqrt(sea*sea+seb*seb)
+}
+
+// IncumbentSetter is implemented by Evalers that compare the points they
+// evaluate against an incumbent (e.g. ResampleEvaler).  Methods set their
+// current best point as the incumbent before evaluating candidates.  Evalers
+// wrapping such an Evaler should forward the incumbent to it.
+type IncumbentSetter interface {
+	SetIncumbent(p *Point)
+}
+
+// ResampleEvaler is an Evaler for noisy (stochastic) objectives.  Each point
+// is evaluated Ninit times and its mean and variance are tracked on the
+// point (see Point.AddSample).  Points whose comparison with Incumbent is
+// statistically ambiguous - i.e. whose means differ by less than Z combined
+// standard errors - are then resampled together with the incumbent, doubling
+// their number of samples each round, until the comparison is settled or
+// Nmax samples have been taken.  This concentrates evaluations where they
+// affect which point a method accepts.  If Incumbent is nil, the best point
+// of each batch is used.
+//
+// Each round of samples is evaluated as a single batch through the wrapped
+// Evaler.  The wrapped Evaler must not cache values (e.g. CacheEvaler) - it
+// should call the objective for every point.
+type ResampleEvaler struct {
+	// Incumbent is the point evaluated points are compared against.  It
+	// is resampled in place.
+	Incumbent *Point
+	Ninit     int
+	Nmax      int
+	Z         float64
+	// Noise is a prior estimate of the objective's noise standard deviation
+	// used instead of the sample standard deviation for points with fewer
+	// samples than needed for a variance estimate and as a lower bound
+	// otherwise.
+	Noise float64
+	ev    Evaler
+}
+
+func NewResampleEvaler(ev Evaler) *ResampleEvaler {
+	return &ResampleEvaler{Ninit: DefaultNinit, Nmax: DefaultNmax, Z: DefaultZ, ev: ev}
+}
+
+func (ev *ResampleEvaler) SetIncumbent(p *Point) { ev.Incumbent = p }
+
+func (ev *ResampleEvaler) Eval(obj Objectiver, points ...*Point) (results []*Point, n int, err error) {
+	uniq := uniqof(points)
+	if len(uniq) == 0 {
+		return uniq, 0, nil
+	}
+	for _, p := range uniq {
+		p.Nsample, p.M2 = 0, 0
+	}
+
+	inc := ev.Incumbent
+	if inc != nil {
+		if inc.Nsample == 0 && !math.IsInf(inc.Val, 1) {
+			// count an existing single evaluation as a sample
+			inc.AddSample(inc.Val)
+		}
+		for _, p := range uniq {
+			if p.Hash() == inc.Hash() {
+				// don't sample the incumbent twice per round
+				inc = nil
+				break
+			}
+		}
+	}
+
+	for i := 0; i < ev.Ninit; i++ {
+		ni, err := ev.sample(obj, uniq)
+		n += ni
+		if err != nil {
+			return uniq, n, err
+		}
+	}
+
+	for {
+		ref := inc
+		if ref == nil {
+			ref = best(uniq)
+		}
+
+		// double the samples of ambiguous points each round - checking for
+		// significance after every single sample would settle many
+		// comparisons by chance
+		resample, nmore := []*Point{}, []int{}
+		for _, p := range uniq {
+			if p != ref && p.Nsample < ev.Nmax && ev.ambiguous(p, ref) {
+				resample, nmore = append(resample, p), append(nmore, ev.more(p))
+			}
+		}
+		if len(resample) == 0 {
+			break
+		}
+		resample, nmore = append(resample, ref), append(nmore, ev.more(ref))
+
+		for r := 0; ; r++ {
+			round := []*Point{}
+			for i, p := range resample {
+				if r < nmore[i] {
+					round = append(round, p)
+				}
+			}
+			if len(round) == 0 {
+				break
+			}
+			ni, err := ev.sample(obj, round)
+			n += ni
+			if err != nil {
+				return uniq, n, err
+			}
+		}
+	}
+	return uniq, n, nil
+}
+
+// more returns the number of additional samples that double p's samples
+// without exceeding Nmax.
+func (ev *ResampleEvaler) more(p *Point) int {
+	if p.Nsample+p.Nsample > ev.Nmax {
+		return ev.Nmax - p.Nsample
+	}
+	return p.Nsample
+}
+
+// sample evaluates each point once more and adds the result to its samples.
+// Points that have failed before are skipped.
+func (ev *ResampleEvaler) sample(obj Objectiver, points []*Point) (n int, err error) {
+	clones := make([]*Point, 0, len(points))
+	orig := map[[sha1.Size]byte]*Point{}
+	for _, p := range points {
+		if p.Nsample > 0 && math.IsInf(p.Val, 1) {
+			continue
+		}
+		clones = append(clones, &Point{Pos: p.Pos, Val: math.Inf(1)})
+		orig[p.Hash()] = p
+	}
+
+	results, n, err := ev.ev.Eval(obj, clones...)
+	for _, c := range results {
+		orig[c.Hash()].AddSample(c.Val)
+	}
+	return n, err
+}
+
+func (ev *ResampleEvaler) ambiguous(p, ref *Point) bool {
+	if math.IsInf(p.Val, 1) || math.IsInf(ref.Val, 1) {
+		return false
+	}
+	sep, seref := stderr(p, ev.Noise), stderr(ref, ev.Noise)
+	return math.Abs(p.Val-ref.Val) < ev.Z*math.Sqrt(sep*sep+seref*seref)
+}
+
+func best(points []*Point) *Point {
+	b := points[0]
+	for _, p := range points[1:] {
+		if p.Val < b.Val {
+			b = p
+		}
+	}
+	return b
+}
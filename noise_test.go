@@ -0,0 +1,111 @@
+package optim
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAddSample(t *testing.T) {
+	p := &Point{Pos: []float64{1}, Val: 42}
+	samples := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+	for _, v := range samples {
+		p.AddSample(v)
+	}
+
+	if p.Nsample != len(samples) {
+		t.Errorf("want %v samples, got %v", len(samples), p.Nsample)
+	}
+	if p.Val != 5 {
+		t.Errorf("mean: want 5, got %v", p.Val)
+	}
+	if want := 32.0 / 7; math.Abs(p.Variance()-want) > 1e-12 {
+		t.Errorf("variance: want %v, got %v", want, p.Variance())
+	}
+	if want := math.Sqrt(32.0 / 7 / 8); math.Abs(p.StdErr()-want) > 1e-12 {
+		t.Errorf("stderr: want %v, got %v", want, p.StdErr())
+	}
+	if c := p.Clone(); c.Nsample != p.Nsample || c.M2 != p.M2 {
+		t.Errorf("clone lost sample statistics: %+v", c)
+	}
+}
+
+func TestBetter(t *testing.T) {
+	a, b := &Point{Val: 1}, &Point{Val: 1.5}
+	if !Better(a, b, 3, 0) {
+		t.Errorf("points without samples should compare by value")
+	}
+
+	a, b = &Point{}, &Point{}
+	for _, v := range []float64{0, 2, 0, 2} {
+		a.AddSample(v)
+		b.AddSample(v + 0.5)
+	}
+	if !Better(a, b, 0, 0) {
+		t.Errorf("z=0: %v should be better than %v", a.Val, b.Val)
+	} else if Better(a, b, 1, 0) {
+		t.Errorf("z=1: %v+/-%v should not be significantly better than %v+/-%v", a.Val, a.StdErr(), b.Val, b.StdErr())
+	}
+
+	// single samples have no sample variance so only the noise prior
+	// makes them comparable
+	a, b = &Point{}, &Point{}
+	a.AddSample(0)
+	b.AddSample(1)
+	if !Better(a, b, 2, 0) {
+		t.Errorf("no prior: %v should be better than %v", a.Val, b.Val)
+	} else if Better(a, b, 2, 1) {
+		t.Errorf("noise prior 1: %v should not be significantly better than %v", a.Val, b.Val)
+	}
+}
+
+// noisyObj is a sphere with normally distributed noise of standard deviation
+// Noise that counts its evaluations per position.
+type noisyObj struct {
+	Noise  float64
+	counts map[float64]int
+}
+
+func (o *noisyObj) Objective(x []float64) (float64, error) {
+	o.counts[x[0]]++
+	return x[0]*x[0] + o.Noise*RandNorm(), nil
+}
+
+func TestResampleEvaler(t *testing.T) {
+	// the number of resamples depends on the noise drawn so fix the stream
+	// regardless of which tests ran before
+	defer func(r Rng) { Rand = r }(Rand)
+	Rand = rand.New(rand.NewSource(1))
+
+	obj := &noisyObj{Noise: 1, counts: map[float64]int{}}
+	ev := NewResampleEvaler(SerialEvaler{})
+	ev.Incumbent = &Point{Pos: []float64{0}, Val: obj.Noise * RandNorm()}
+
+	// 0.1 is hard to distinguish from the incumbent at 0 - 10 is not
+	near := &Point{Pos: []float64{0.1}, Val: math.Inf(1)}
+	far := &Point{Pos: []float64{10}, Val: math.Inf(1)}
+	results, n, err := ev.Eval(obj, near, far, far)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Logf("[INFO] %v evals: near %v, far %v, incumbent %v", n, obj.counts[0.1], obj.counts[10], obj.counts[0])
+	if len(results) != 2 {
+		t.Errorf("want 2 unique results, got %v", len(results))
+	}
+	if far.Nsample != DefaultNinit || obj.counts[10] != DefaultNinit {
+		t.Errorf("far point: want %v samples, got %v", DefaultNinit, far.Nsample)
+	}
+	if near.Nsample <= far.Nsample {
+		t.Errorf("ambiguous near point: want more than %v samples, got %v", far.Nsample, near.Nsample)
+	}
+	if ev.Incumbent.Nsample != obj.counts[0]+1 {
+		t.Errorf("incumbent: want %v samples, got %v", obj.counts[0]+1, ev.Incumbent.Nsample)
+	}
+	if tot := obj.counts[0] + obj.counts[0.1] + obj.counts[10]; n != tot {
+		t.Errorf("want %v evals, got %v", tot, n)
+	}
+	if math.Abs(far.Val-100) > 3 {
+		t.Errorf("far point: want mean near 100, got %v", far.Val)
+	}
+}
@@ -0,0 +1,355 @@
+// Package nsga2 provides the elitist non-dominated sorting genetic algorithm
+// for multi-objective optimization described in:
+//
+//     Deb, Kalyanmoy, et al. "A fast and elitist multiobjective genetic
+//     algorithm: NSGA-II." IEEE Transactions on Evolutionary Computation 6.2
+//     (2002): 182-197.
+//
+// Each generation creates offspring by binary tournament selection,
+// simulated binary crossover (SBX) and polynomial mutation.  Parents and
+// offspring are then ranked together into Pareto fronts and the next
+// population is filled front by front, breaking ties within the last front
+// that fits by preferring less crowded points.  The objective passed to
+// Iterate must be an optim.MultiObjectiver.  Each generation is evaluated as
+// a single batch through the method's optim.Evaler and all non-dominated
+// points found are kept in an optim.Archive (see Method.Front).
+package nsga2
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+)
+
+// TblFront is the name of the sql database table that contains the
+// objective values of the archived Pareto front after each iteration.
+const TblFront = "nsga2front"
+
+const (
+	DefaultPopSize   = 100
+	DefaultCrossProb = 0.9
+	// DefaultEta is the default distribution index of both crossover and
+	// mutation - larger values create offspring closer to their parents.
+	DefaultEta = 20
+)
+
+// ErrNotMulti is returned by Iterate if the objective is not an
+// optim.MultiObjectiver.
+var ErrNotMulti = errors.New("nsga2: objective does not implement optim.MultiObjectiver")
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// PopSize sets the number of points in the population - it is rounded up to
+// an even number.
+func PopSize(n int) Option { return func(m *Method) { m.PopSize = n + n%2 } }
+
+// Crossover sets the probability that a pair of parents is recombined and
+// the SBX distribution index.
+func Crossover(prob, eta float64) Option {
+	return func(m *Method) { m.CrossProb, m.EtaC = prob, eta }
+}
+
+// Mutation sets the probability that each variable is mutated and the
+// polynomial mutation distribution index.  The default probability is
+// 1/ndim.
+func Mutation(prob, eta float64) Option {
+	return func(m *Method) { m.MutProb, m.EtaM = prob, eta }
+}
+
+// ArchiveSize limits the number of points kept in the Pareto front archive.
+// Zero means no limit.
+func ArchiveSize(n int) Option { return func(m *Method) { m.Archive.Max = n } }
+
+type Method struct {
+	Pop       []*optim.Point
+	Low, Up   []float64
+	PopSize   int
+	CrossProb float64
+	EtaC      float64
+	MutProb   float64
+	EtaM      float64
+	// Archive holds every non-dominated point found so far.
+	Archive *optim.Archive
+	Db      *sql.DB
+
+	rank  []int
+	crowd []float64
+	ev    optim.Evaler
+	iter  int
+}
+
+// New creates an NSGA-II method searching within the bounds low and up.  The
+// initial population is sampled uniformly within the bounds.
+func New(low, up []float64, opts ...Option) *Method {
+	m := &Method{
+		Low:       append([]float64{}, low...),
+		Up:        append([]float64{}, up...),
+		PopSize:   DefaultPopSize,
+		CrossProb: DefaultCrossProb,
+		EtaC:      DefaultEta,
+		MutProb:   1 / float64(len(low)),
+		EtaM:      DefaultEta,
+		Archive:   &optim.Archive{},
+		ev:        optim.SerialEvaler{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.initdb()
+	return m
+}
+
+// Front returns the non-dominated points found so far.
+func (m *Method) Front() []*optim.Point { return m.Archive.Points() }
+
+// AddPoint adds p to the population if it has objective values, replacing the
+// point with the worst rank.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Vals == nil || len(m.Pop) == 0 {
+		return
+	}
+	worst := 0
+	for i := range m.Pop {
+		if m.rank[i] > m.rank[worst] || (m.rank[i] == m.rank[worst] && m.crowd[i] < m.crowd[worst]) {
+			worst = i
+		}
+	}
+	m.Pop[worst] = p.Clone()
+	m.Archive.Add(p)
+	m.rankpop(m.Pop)
+}
+
+// Iterate runs one generation and returns the population point with the
+// lowest scalar objective value.
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	mobj, ok := obj.(optim.MultiObjectiver)
+	if !ok {
+		return &optim.Point{Val: math.Inf(1)}, 0, ErrNotMulti
+	}
+
+	m.iter++
+	defer m.updateDb()
+
+	if m.Pop == nil {
+		m.Pop = make([]*optim.Point, m.PopSize)
+		for i := range m.Pop {
+			pos := make([]float64, len(m.Low))
+			for j := range pos {
+				pos[j] = m.Low[j] + (m.Up[j]-m.Low[j])*optim.RandFloat()
+			}
+			m.Pop[i] = &optim.Point{Pos: m.project(pos, mesh), Val: math.Inf(1)}
+		}
+		_, n, err = optim.EvalMulti(m.ev, mobj, m.Pop...)
+		m.Archive.Add(m.Pop...)
+		m.rankpop(m.Pop)
+		return m.best(), n, err
+	}
+
+	children := make([]*optim.Point, 0, m.PopSize)
+	for len(children) < m.PopSize {
+		p1, p2 := m.tournament(), m.tournament()
+		c1, c2 := append([]float64{}, p1.Pos...), append([]float64{}, p2.Pos...)
+		if optim.RandFloat() < m.CrossProb {
+			m.sbx(c1, c2)
+		}
+		for _, c := range [][]float64{c1, c2} {
+			m.mutate(c)
+			children = append(children, &optim.Point{Pos: m.project(c, mesh), Val: math.Inf(1)})
+		}
+	}
+
+	_, n, err = optim.EvalMulti(m.ev, mobj, children...)
+	if err != nil {
+		return m.best(), n, err
+	}
+	m.Archive.Add(children...)
+
+	// fill the next population front by front - the crowding distances of
+	// the last front that fits decide which of its points make it
+	union := make([]*optim.Point, 0, len(m.Pop)+len(children))
+	union = append(append(union, m.Pop...), children...)
+	next := make([]*optim.Point, 0, m.PopSize)
+	for _, front := range optim.NondominatedSort(union) {
+		if len(next)+len(front) <= m.PopSize {
+			next = append(next, front...)
+			continue
+		}
+		dist := optim.Crowding(front)
+		order := make([]int, len(front))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Sort(bycrowd{order, dist})
+		for _, i := range order[:m.PopSize-len(next)] {
+			next = append(next, front[i])
+		}
+		break
+	}
+	m.rankpop(next)
+	return m.best(), n, nil
+}
+
+// rankpop makes pop the population and computes each point's front rank and
+// crowding distance within its front.
+func (m *Method) rankpop(pop []*optim.Point) {
+	m.Pop = make([]*optim.Point, 0, len(pop))
+	m.rank = make([]int, 0, len(pop))
+	m.crowd = make([]float64, 0, len(pop))
+	for r, front := range optim.NondominatedSort(pop) {
+		m.Pop = append(m.Pop, front...)
+		m.crowd = append(m.crowd, optim.Crowding(front)...)
+		for range front {
+			m.rank = append(m.rank, r)
+		}
+	}
+}
+
+// tournament returns the better of two random population members by rank
+// and then crowding distance.
+func (m *Method) tournament() *optim.Point {
+	i, j := optim.Rand.Intn(len(m.Pop)), optim.Rand.Intn(len(m.Pop))
+	if m.rank[j] < m.rank[i] || (m.rank[j] == m.rank[i] && m.crowd[j] > m.crowd[i]) {
+		i = j
+	}
+	return m.Pop[i]
+}
+
+// sbx recombines x1 and x2 in place using bounded simulated binary
+// crossover.  Each variable is recombined with probability 1/2.
+func (m *Method) sbx(x1, x2 []float64) {
+	for i := range x1 {
+		if optim.RandFloat() > 0.5 || math.Abs(x1[i]-x2[i]) < 1e-14 {
+			continue
+		}
+		y1, y2 := math.Min(x1[i], x2[i]), math.Max(x1[i], x2[i])
+		lo, up := m.Low[i], m.Up[i]
+		u := optim.RandFloat()
+
+		betaq := func(beta float64) float64 {
+			alpha := 2 - math.Pow(beta, -(m.EtaC+1))
+			if u <= 1/alpha {
+				return math.Pow(u*alpha, 1/(m.EtaC+1))
+			}
+			return math.Pow(1/(2-u*alpha), 1/(m.EtaC+1))
+		}
+		c1 := 0.5 * (y1 + y2 - betaq(1+2*(y1-lo)/(y2-y1))*(y2-y1))
+		c2 := 0.5 * (y1 + y2 + betaq(1+2*(up-y2)/(y2-y1))*(y2-y1))
+		c1, c2 = math.Min(up, math.Max(lo, c1)), math.Min(up, math.Max(lo, c2))
+
+		if optim.RandFloat() < 0.5 {
+			c1, c2 = c2, c1
+		}
+		x1[i], x2[i] = c1, c2
+	}
+}
+
+// mutate applies polynomial mutation to x in place.
+func (m *Method) mutate(x []float64) {
+	for i := range x {
+		if optim.RandFloat() >= m.MutProb {
+			continue
+		}
+		lo, up := m.Low[i], m.Up[i]
+		if up <= lo {
+			continue
+		}
+		d1, d2 := (x[i]-lo)/(up-lo), (up-x[i])/(up-lo)
+		u := optim.RandFloat()
+		pow := 1 / (m.EtaM + 1)
+
+		var deltaq float64
+		if u < 0.5 {
+			val := 2*u + (1-2*u)*math.Pow(1-d1, m.EtaM+1)
+			deltaq = math.Pow(val, pow) - 1
+		} else {
+			val := 2*(1-u) + 2*(u-0.5)*math.Pow(1-d2, m.EtaM+1)
+			deltaq = 1 - math.Pow(val, pow)
+		}
+		x[i] = math.Min(up, math.Max(lo, x[i]+deltaq*(up-lo)))
+	}
+}
+
+func (m *Method) project(pos []float64, mesh optim.Mesh) []float64 {
+	if mesh == nil {
+		return pos
+	}
+	return mesh.Nearest(pos)
+}
+
+func (m *Method) best() *optim.Point {
+	best := &optim.Point{Val: math.Inf(1)}
+	for _, p := range m.Pop {
+		if p.Val < best.Val {
+			best = p
+		}
+	}
+	return best
+}
+
+// bycrowd orders indexes into a front by decreasing crowding distance.
+type bycrowd struct {
+	order []int
+	dist  []float64
+}
+
+func (b bycrowd) Len() int           { return len(b.order) }
+func (b bycrowd) Less(i, j int) bool { return b.dist[b.order[i]] > b.dist[b.order[j]] }
+func (b bycrowd) Swap(i, j int)      { b.order[i], b.order[j] = b.order[j], b.order[i] }
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblFront + " (iter INTEGER,obj INTEGER,val REAL,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblFront + " (iter,obj,val,posid) VALUES (?,?,?,?);"
+	front := m.Front()
+	for _, p := range front {
+		for k, v := range p.Vals {
+			_, err := tx.Exec(s, m.iter, k, v, p.HashSlice())
+			if checkdberr(err) {
+				return
+			}
+		}
+	}
+
+	err = optim.RecordPointPos(tx, front...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("nsga2: db write failed -", err)
+		return true
+	}
+	return false
+}
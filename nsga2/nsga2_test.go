@@ -0,0 +1,107 @@
+package nsga2
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func run(fn bench.MultiFunc, ngen int, opts ...Option) (*Method, *optim.Solver) {
+	low, up := fn.Bounds()
+	m := New(low, up, opts...)
+	solv := &optim.Solver{Method: m, Obj: bench.MultiObjective(fn), MaxIter: ngen}
+	solv.Run()
+	return m, solv
+}
+
+func TestBench(t *testing.T) {
+	tests := []struct {
+		Fn      bench.MultiFunc
+		Ngen    int
+		MaxIGD  float64
+		MinFrac float64 // of the reference front's hypervolume
+	}{
+		{bench.ZDT1{NDim: 30}, 250, 0.01, 0.97},
+		{bench.ZDT2{NDim: 30}, 250, 0.01, 0.97},
+		{bench.ZDT3{NDim: 30}, 250, 0.02, 0.97},
+		{bench.DTLZ2{NDim: 12, M: 3}, 250, 0.1, 0.9},
+	}
+
+	for _, test := range tests {
+		m, solv := run(test.Fn, test.Ngen, ArchiveSize(100))
+		if err := solv.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		ref := test.Fn.Front(200)
+		refpts := make([]*optim.Point, len(ref))
+		for i, v := range ref {
+			refpts[i] = &optim.Point{Vals: v}
+		}
+		igd := optim.IGD(m.Front(), ref)
+		frac := optim.Hypervolume(m.Front(), test.Fn.Ref()) / optim.Hypervolume(refpts, test.Fn.Ref())
+
+		t.Logf("[INFO] %v: %v evals, %v front points, IGD %.4f, hypervolume %.3f of reference", test.Fn.Name(), solv.Neval(), len(m.Front()), igd, frac)
+		if igd > test.MaxIGD {
+			t.Errorf("%v: want IGD < %v, got %v", test.Fn.Name(), test.MaxIGD, igd)
+		}
+		if frac < test.MinFrac {
+			t.Errorf("%v: want hypervolume > %v of reference, got %v", test.Fn.Name(), test.MinFrac, frac)
+		}
+	}
+}
+
+func TestFront(t *testing.T) {
+	m, _ := run(bench.ZDT1{NDim: 5}, 20, PopSize(21))
+	if len(m.Pop) != 22 {
+		t.Errorf("want population rounded up to 22, got %v", len(m.Pop))
+	}
+
+	front := m.Front()
+	if len(front) == 0 {
+		t.Fatal("empty front")
+	}
+	for _, p := range front {
+		for _, q := range front {
+			if optim.Dominates(p, q) {
+				t.Fatalf("front point %v dominates front point %v", p.Vals, q.Vals)
+			}
+		}
+		for _, q := range m.Pop {
+			if optim.Dominates(q, p) {
+				t.Fatalf("population point %v dominates front point %v", q.Vals, p.Vals)
+			}
+		}
+	}
+}
+
+func TestNotMulti(t *testing.T) {
+	m := New([]float64{0}, []float64{1})
+	_, _, err := m.Iterate(optim.Func(func(x []float64) float64 { return x[0] }), &optim.InfMesh{})
+	if err != ErrNotMulti {
+		t.Errorf("want ErrNotMulti, got %v", err)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, solv := run(bench.ZDT1{NDim: 3}, 5, DB(db), PopSize(10))
+
+	var count, niter int
+	err = db.QueryRow("SELECT COUNT(*),COUNT(DISTINCT iter) FROM "+TblFront).Scan(&count, &niter)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblFront, err)
+	} else if niter != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v iterations, want %v", TblFront, niter, solv.Niter())
+	} else if count < 2*len(m.Front()) {
+		t.Errorf("[ERROR] %v table has %v rows, want at least %v", TblFront, count, 2*len(m.Front()))
+	}
+}
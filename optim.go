@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"database/sql"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -15,6 +16,11 @@ import (
 
 var Rand Rng = rand.New(rand.NewSource(1))
 
+// ErrConverged is returned by Method.Iterate when the method can make no
+// further progress (e.g. its convergence criteria are met or there are no
+// points left to evaluate).  Solver stops without reporting an error.
+var ErrConverged = errors.New("optim: converged")
+
 type Rng interface {
 	Float64() float64
 	Intn(n int) int
@@ -23,9 +29,22 @@ type Rng interface {
 
 func RandFloat() float64 { return Rand.Float64() }
 
+// RandNorm returns a standard normally distributed random number generated
+// from Rand using the Box-Muller transform.
+func RandNorm() float64 {
+	u1 := RandFloat()
+	for u1 == 0 {
+		u1 = RandFloat()
+	}
+	u2 := RandFloat()
+	return math.Sqrt(-2*math.Log(u1)) * math.Cos(2*math.Pi*u2)
+}
+
 type Solver struct {
-	Method       Method
-	Obj          Objectiver
+	Method Method
+	Obj    Objectiver
+	// StopOnErr stops the solver after any iteration that returns an error.
+	// An iteration returning ErrConverged always stops the solver.
 	StopOnErr    bool
 	Mesh         Mesh
 	MaxIter      int
@@ -71,7 +90,16 @@ func (s *Solver) Next() (more bool) {
 		s.noimprove++
 	}
 
-	if s.err != nil && s.StopOnErr {
+	if a, ok := s.Obj.(Adapter); ok && a.Adapt(best) {
+		if err := s.rescore(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+
+	if s.err == ErrConverged {
+		s.err = nil
+		return false
+	} else if s.err != nil && s.StopOnErr {
 		return false
 	}
 
@@ -82,9 +110,49 @@ func (s *Solver) Next() (more bool) {
 	return more
 }
 
+// rescore re-evaluates the best point using the (adapted) objective and
+// has the method re-score its own points if it implements Rescorer.  The
+// better of the two becomes the new best point.
+func (s *Solver) rescore() (err error) {
+	var rbest *Point
+	if r, ok := s.Method.(Rescorer); ok {
+		var n int
+		rbest, n, err = r.Rescore(s.Obj)
+		s.neval += n
+	}
+
+	if s.best.Pos != nil && (rbest == nil || rbest.Hash() != s.best.Hash()) {
+		best := s.best.Clone()
+		var err2 error
+		best.Val, err2 = s.Obj.Objective(best.Pos)
+		s.neval++
+		if err == nil {
+			err = err2
+		}
+		s.best = best
+	} else if rbest != nil {
+		s.best = &Point{Val: math.Inf(1)}
+	}
+
+	if rbest != nil && rbest.Val < s.best.Val {
+		s.best = rbest.Clone()
+	}
+	return err
+}
+
 type Point struct {
 	Pos []float64
+	// Val is the objective value at Pos.  For points sampled repeatedly
+	// (see AddSample), Val is the mean of the samples.
 	Val float64
+	// Nsample is the number of objective samples averaged into Val - zero
+	// for points that were not sampled with AddSample.
+	Nsample int
+	// M2 is the sum of squared deviations of the samples from their mean.
+	M2 float64
+	// Vals holds the objective values at Pos for multi-objective problems
+	// (see MultiObjectiver) - nil otherwise.
+	Vals []float64
 }
 
 func (p *Point) Len() int             { return len(p.Pos) }
@@ -94,7 +162,11 @@ func (p *Point) String() string       { return fmt.Sprintf("f%v = %v", p.Pos, p.
 func (p *Point) Clone() *Point {
 	pos := make([]float64, len(p.Pos))
 	copy(pos, p.Pos)
-	return &Point{Pos: pos, Val: p.Val}
+	c := &Point{Pos: pos, Val: p.Val, Nsample: p.Nsample, M2: p.M2}
+	if p.Vals != nil {
+		c.Vals = append([]float64{}, p.Vals...)
+	}
+	return c
 }
 
 func (p *Point) Hash() [sha1.Size]byte {
@@ -141,9 +213,36 @@ type Objectiver interface {
 	Objective(v []float64) (float64, error)
 }
 
+// ConstrObjectiver is an Objectiver with general (possibly nonlinear)
+// constraints of the form g(v) <= 0.
+type ConstrObjectiver interface {
+	Objectiver
+	// Constraints evaluates the constraint functions for the variables in v.
+	// v is feasible if every returned value is less than or equal to zero.
+	// If the evaluation fails, an error should be returned.
+	Constraints(v []float64) ([]float64, error)
+}
+
+// Violation returns the aggregate constraint violation
+// h = sum(max(0, g[i])^2) for the constraint values g.  h is zero if and
+// only if all constraints are satisfied.
+func Violation(g []float64) float64 {
+	h := 0.0
+	for _, v := range g {
+		if v > 0 {
+			h += v * v
+		}
+	}
+	return h
+}
+
+// CacheEvaler wraps an Evaler and remembers the value of every successfully
+// evaluated position so that it is never evaluated twice.  For a ValsRecorder
+// objective (e.g. with EvalMulti), the objective vectors are cached too and
+// cached positions without one are evaluated again.
 type CacheEvaler struct {
 	ev    Evaler
-	cache map[[sha1.Size]byte]float64
+	cache map[[sha1.Size]byte]*Point
 	// UseCount reports the number of times a cached objective evaluation was
 	// successfully used to avoid recalculation.
 	UseCount int
@@ -152,18 +251,32 @@ type CacheEvaler struct {
 func NewCacheEvaler(ev Evaler) *CacheEvaler {
 	return &CacheEvaler{
 		ev:    ev,
-		cache: map[[sha1.Size]byte]float64{},
+		cache: map[[sha1.Size]byte]*Point{},
 	}
 }
 
+// Points returns copies of all cached evaluations (e.g. for seeding
+// surrogate models).
+func (ev *CacheEvaler) Points() []*Point {
+	pts := make([]*Point, 0, len(ev.cache))
+	for _, p := range ev.cache {
+		pts = append(pts, p.Clone())
+	}
+	return pts
+}
+
 func (ev *CacheEvaler) Eval(obj Objectiver, points ...*Point) (results []*Point, n int, err error) {
 	results = make([]*Point, 0, len(points))
 	newp := make([]*Point, 0, len(points))
+	vr, recorder := obj.(ValsRecorder)
 	uniq := uniqof(points)
 	for _, p := range uniq {
 		h := p.Hash()
-		if val, ok := ev.cache[h]; ok {
-			p.Val = val
+		if cached, ok := ev.cache[h]; ok && (!recorder || cached.Vals != nil) {
+			p.Val = cached.Val
+			if cached.Vals != nil {
+				p.Vals = append([]float64{}, cached.Vals...)
+			}
 			results = append(results, p)
 			ev.UseCount++
 		} else {
@@ -174,8 +287,11 @@ func (ev *CacheEvaler) Eval(obj Objectiver, points ...*Point) (results []*Point,
 
 	newresults, n, err := ev.ev.Eval(obj, newp...)
 	for _, p := range newresults {
+		if recorder {
+			p.Vals = vr.Vals(p.Pos)
+		}
 		if p.Val != math.Inf(1) {
-			ev.cache[p.Hash()] = p.Val
+			ev.cache[p.Hash()] = p.Clone()
 		}
 	}
 	return append(newresults, results...), n, err
@@ -271,6 +387,17 @@ type Func func([]float64) float64
 
 func (so Func) Objective(v []float64) (float64, error) { return so(v), nil }
 
+// ConstrFunc is a ConstrObjectiver that combines an objective function Obj
+// with a constraint function Constr.
+type ConstrFunc struct {
+	Obj    Func
+	Constr func([]float64) []float64
+}
+
+func (c ConstrFunc) Objective(v []float64) (float64, error) { return c.Obj(v), nil }
+
+func (c ConstrFunc) Constraints(v []float64) ([]float64, error) { return c.Constr(v), nil }
+
 type ObjectiveLogger struct {
 	Obj Objectiver
 	W   io.Writer
@@ -391,6 +518,41 @@ func RecordPointPos(tx *sql.Tx, pts ...*Point) error {
 	return nil
 }
 
+// LoadPoints reads evaluated points from the table tbl of a database
+// populated by a method's DB option.  tbl must have val and posid columns
+// (e.g. swarm.TblParticles or pattern.TblPolls) and positions are looked up
+// in the points table written by RecordPointPos.  Each distinct position is
+// returned once.
+func LoadPoints(db *sql.DB, tbl string) ([]*Point, error) {
+	rows, err := db.Query("SELECT t.posid,t.val,p.dim,p.val FROM (SELECT posid,MIN(val) AS val FROM " + tbl +
+		" GROUP BY posid) AS t JOIN (SELECT DISTINCT posid,dim,val FROM points) AS p ON t.posid=p.posid ORDER BY t.posid,p.dim;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pts := []*Point{}
+	var last []byte
+	for rows.Next() {
+		var id []byte
+		var val, pos float64
+		var dim int
+		if err := rows.Scan(&id, &val, &dim, &pos); err != nil {
+			return nil, err
+		}
+		if string(id) != string(last) {
+			pts = append(pts, &Point{Val: val})
+			last = id
+		}
+		p := pts[len(pts)-1]
+		if dim != len(p.Pos) {
+			return nil, fmt.Errorf("point %x has inconsistent dimensions", id)
+		}
+		p.Pos = append(p.Pos, pos)
+	}
+	return pts, rows.Err()
+}
+
 func StackConstr(low, A, up *mat64.Dense) (stackA, b *mat64.Dense, ranges []float64) {
 	neglow := &mat64.Dense{}
 	neglow.Scale(-1, low)
@@ -420,3 +582,40 @@ func StackConstr(low, A, up *mat64.Dense) (stackA, b *mat64.Dense, ranges []floa
 
 	return stackA, b, ranges
 }
+
+// SplitEqConstr separates the linear constraints low <= Ax <= up into the
+// equality constraints Aeq x = beq (rows with low == up) and the remaining
+// inequality constraints.  Returned matrices are nil if there are no
+// constraints of the corresponding kind.  The equality constraints can be
+// handled exactly with an EqMesh.
+func SplitEqConstr(low, A, up *mat64.Dense) (Aeq, beq, ineqlow, ineqA, inequp *mat64.Dense) {
+	m, n := A.Dims()
+	var eqrows, ineqrows []int
+	for i := 0; i < m; i++ {
+		if low.At(i, 0) == up.At(i, 0) {
+			eqrows = append(eqrows, i)
+		} else {
+			ineqrows = append(ineqrows, i)
+		}
+	}
+
+	rows := func(idx []int, bound *mat64.Dense) (a, b *mat64.Dense) {
+		if len(idx) == 0 {
+			return nil, nil
+		}
+		a = mat64.NewDense(len(idx), n, nil)
+		b = mat64.NewDense(len(idx), 1, nil)
+		for k, i := range idx {
+			for j := 0; j < n; j++ {
+				a.Set(k, j, A.At(i, j))
+			}
+			b.Set(k, 0, bound.At(i, 0))
+		}
+		return a, b
+	}
+
+	Aeq, beq = rows(eqrows, up)
+	ineqA, inequp = rows(ineqrows, up)
+	_, ineqlow = rows(ineqrows, low)
+	return Aeq, beq, ineqlow, ineqA, inequp
+}
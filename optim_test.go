@@ -1,21 +1,24 @@
 package optim
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"math"
 	"sync"
 	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
 )
 
 func testpoints() []*Point {
 	return []*Point{
-		&Point{[]float64{1, 2, 3}, 0},
-		&Point{[]float64{1, 2, 3}, 0}, // duplicate point on purpose
-		&Point{[]float64{1, 2, 4}, 0},
-		&Point{[]float64{1, 2, 5}, 0},
-		&Point{[]float64{1, 2, 6}, 0},
-		&Point{[]float64{1, 2, 7}, 0},
+		&Point{Pos: []float64{1, 2, 3}, Val: 0},
+		&Point{Pos: []float64{1, 2, 3}, Val: 0}, // duplicate point on purpose
+		&Point{Pos: []float64{1, 2, 4}, Val: 0},
+		&Point{Pos: []float64{1, 2, 5}, Val: 0},
+		&Point{Pos: []float64{1, 2, 6}, Val: 0},
+		&Point{Pos: []float64{1, 2, 7}, Val: 0},
 	}
 }
 
@@ -209,3 +212,76 @@ func TestCacheEvaler(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheEvalerPoints(t *testing.T) {
+	obj := &ObjTest{max: 100000}
+	ev := NewCacheEvaler(SerialEvaler{})
+	ev.Eval(obj, testpoints()...)
+
+	pts := ev.Points()
+	if exp := len(testpoints()) - 1; len(pts) != exp {
+		t.Fatalf("expected %v cached points, got %v", exp, len(pts))
+	}
+	for _, p := range pts {
+		if p.Val != p.Pos[0]+p.Pos[1]+p.Pos[2] {
+			t.Errorf("cached point %v has wrong value", p)
+		}
+	}
+
+	// modifying returned points must not corrupt the cache
+	pts[0].Val = -1
+	for _, p := range ev.Points() {
+		if p.Val == -1 {
+			t.Errorf("cache modified through returned points")
+		}
+	}
+}
+
+func TestLoadPoints(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE evals (iter INTEGER, val REAL, posid BLOB);")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpoints := testpoints()
+	for i, p := range tpoints {
+		p.Val = float64(i)
+		if _, err := tx.Exec("INSERT INTO evals VALUES (?,?,?);", i, p.Val, p.HashSlice()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RecordPointPos(tx, tpoints...); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	pts, err := LoadPoints(db, "evals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := len(tpoints) - 1; len(pts) != exp {
+		t.Fatalf("expected %v unique points, got %v", exp, len(pts))
+	}
+	for _, p := range pts {
+		if len(p.Pos) != 3 || p.Pos[0] != 1 || p.Pos[1] != 2 {
+			t.Errorf("bad position %v", p.Pos)
+		}
+		exp := p.Pos[2] - 2
+		if p.Pos[2] == 3 {
+			exp = 0 // the duplicate point keeps its best value
+		}
+		if p.Val != exp {
+			t.Errorf("point %v: expected value %v", p, exp)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package pattern
+
+import (
+	"crypto/sha1"
+	"math"
+	"sync"
+
+	"github.com/baaaaam/optim"
+)
+
+// BarrierMode specifies how a Method handles the constraints of objectives
+// implementing optim.ConstrObjectiver.
+type BarrierMode int
+
+const (
+	// NoBarrier ignores constraints and only uses the objective value.
+	NoBarrier BarrierMode = iota
+	// ExtremeBarrier treats every infeasible point as having an objective
+	// value of +infinity.  The objective is not evaluated for infeasible
+	// points.
+	ExtremeBarrier
+	// ProgressiveBarrier tracks both a feasible incumbent and an infeasible
+	// incumbent and polls around both.  Infeasible points are accepted if
+	// their constraint violation is below a threshold that decreases as the
+	// optimization progresses.  This is the MADS-PB algorithm described in:
+	//
+	//     Audet, Charles, and John E. Dennis Jr. "A progressive barrier for
+	//     derivative-free nonlinear programming." SIAM Journal on
+	//     Optimization 20.1 (2009): 445-472.
+	//
+	// The search step (if any) always uses an extreme barrier.
+	ProgressiveBarrier
+)
+
+// Barrier sets the constraint handling mode for objectives that implement
+// optim.ConstrObjectiver.
+func Barrier(mode BarrierMode) Option { return func(m *Method) { m.Barrier = mode } }
+
+// BarrierHmax sets the initial maximum constraint violation (see
+// optim.Violation) for points to be accepted by the progressive barrier.
+func BarrierHmax(hmax float64) Option { return func(m *Method) { m.Hmax = hmax } }
+
+// barrierObj wraps a constrained objective and records the constraint
+// violation for each evaluated point.  The objective is not evaluated (and
+// +infinity is returned) for points with violation greater than hmax.
+type barrierObj struct {
+	optim.ConstrObjectiver
+	hmax  float64
+	mu    sync.Mutex
+	viols map[[sha1.Size]byte]float64
+}
+
+func newBarrierObj(o optim.ConstrObjectiver, hmax float64) *barrierObj {
+	return &barrierObj{
+		ConstrObjectiver: o,
+		hmax:             hmax,
+		viols:            map[[sha1.Size]byte]float64{},
+	}
+}
+
+func (b *barrierObj) Objective(v []float64) (float64, error) {
+	g, err := b.Constraints(v)
+	h := optim.Violation(g)
+	if err != nil {
+		h = math.Inf(1)
+	}
+
+	b.mu.Lock()
+	b.viols[(&optim.Point{Pos: v}).Hash()] = h
+	b.mu.Unlock()
+
+	if err != nil {
+		return math.Inf(1), err
+	} else if h > b.hmax {
+		return math.Inf(1), nil
+	}
+	return b.ConstrObjectiver.Objective(v)
+}
+
+// Viol returns the recorded constraint violation for p.  Unevaluated points
+// have infinite violation.
+func (b *barrierObj) Viol(p *optim.Point) float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if h, ok := b.viols[p.Hash()]; ok {
+		return h
+	}
+	return math.Inf(1)
+}
+
+// filterpoint is an infeasible point along with its constraint violation.
+type filterpoint struct {
+	*optim.Point
+	H float64
+}
+
+func (fp filterpoint) dominates(other filterpoint) bool {
+	return fp.H <= other.H && fp.Val <= other.Val && (fp.H < other.H || fp.Val < other.Val)
+}
+
+// filter holds the non-dominated infeasible points in (violation, objective)
+// space.
+type filter []filterpoint
+
+func (f filter) add(fp filterpoint) filter {
+	for _, other := range f {
+		if other.dominates(fp) || (other.H == fp.H && other.Val == fp.Val) {
+			return f
+		}
+	}
+	next := filter{}
+	for _, other := range f {
+		if !fp.dominates(other) {
+			next = append(next, other)
+		}
+	}
+	return append(next, fp)
+}
+
+// incumbent returns the point with the lowest objective value among those
+// with violation <= hmax.
+func (f filter) incumbent(hmax float64) *filterpoint {
+	var best *filterpoint
+	for i, fp := range f {
+		if fp.H <= hmax && (best == nil || fp.Val < best.Val) {
+			best = &f[i]
+		}
+	}
+	return best
+}
+
+// iteratePB performs a single progressive barrier iteration.
+func (m *Method) iteratePB(o optim.ConstrObjectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	var nevalsearch, nevalpoll int
+	defer m.updateDb(&nevalsearch, &nevalpoll, mesh.Step())
+	m.count++
+
+	// zero only means no limit before the first iteration - the threshold
+	// may legitimately be tightened to zero later on
+	if m.count == 1 && m.Hmax == 0 {
+		m.Hmax = math.Inf(1)
+	}
+
+	bobj := newBarrierObj(o, m.Hmax)
+
+	// classify the starting point
+	if m.count == 1 {
+		start := m.Curr.Clone()
+		_, nevalpoll, err = m.ev.Eval(bobj, start)
+		n += nevalpoll
+		m.classify(start, bobj.Viol(start))
+		if err != nil {
+			return m.Curr, n, err
+		}
+	}
+
+	prevstep := mesh.Step()
+	if !m.DiscreteSearch {
+		mesh.SetStep(0)
+	}
+	var success bool
+	success, best, nevalsearch, err = m.Searcher.Search(newBarrierObj(o, 0), mesh, m.Curr)
+	mesh.SetStep(prevstep)
+	n += nevalsearch
+	if success {
+		m.Curr = best
+		return best, n, err
+	}
+
+	// poll around both incumbents
+	centers := []*optim.Point{}
+	if !math.IsInf(m.Curr.Val, 1) {
+		centers = append(centers, m.Curr)
+	}
+	var currinf *filterpoint
+	if currinf = m.filter.incumbent(m.Hmax); currinf != nil {
+		centers = append(centers, currinf.Point)
+	}
+	if len(centers) == 0 {
+		centers = append(centers, m.Curr)
+	}
+
+	m.Poller.initSpanner(m.Curr.Len())
+	pollpoints := []*optim.Point{}
+	for _, c := range centers {
+		mesh.SetOrigin(c.Pos)
+		for _, p := range genPollPoints(c, m.Poller.Spanner, mesh) {
+			if optim.L2Dist(c, p) > m.Poller.SkipEps {
+				pollpoints = append(pollpoints, p)
+			}
+		}
+	}
+	m.Poller.points = pollpoints
+
+	results, nevalpoll, err2 := m.ev.Eval(bobj, pollpoints...)
+	n += nevalpoll
+
+	dominating, improving := false, false
+	hinc := math.Inf(1)
+	if currinf != nil {
+		hinc = currinf.H
+	}
+	for _, p := range results {
+		h := bobj.Viol(p)
+		if h == 0 && p.Val < m.Curr.Val {
+			dominating = true
+		} else if h > 0 && h <= m.Hmax && !math.IsInf(p.Val, 1) {
+			fp := filterpoint{p, h}
+			if currinf == nil && math.IsInf(m.Curr.Val, 1) {
+				dominating = true
+			} else if currinf != nil && fp.dominates(*currinf) {
+				dominating = true
+			} else if h < hinc {
+				improving = true
+			}
+		}
+		m.classify(p, h)
+	}
+	m.Poller.Spanner.Update(mesh.Step(), dominating)
+
+	// update the violation threshold and mesh
+	switch {
+	case dominating:
+		m.nsuccess++
+		if m.nsuccess == m.NsuccessGrow {
+			mesh.SetStep(mesh.Step() / m.StepMult)
+			m.nsuccess = 0
+		}
+		if fp := m.filter.incumbent(m.Hmax); fp != nil {
+			m.Hmax = fp.H
+		}
+	case improving:
+		m.nsuccess = 0
+		hmax := 0.0
+		for _, fp := range m.filter {
+			if fp.H < hinc {
+				hmax = math.Max(hmax, fp.H)
+			}
+		}
+		m.Hmax = hmax
+	default:
+		m.nsuccess = 0
+		if nextstep := mesh.Step() * m.StepMult; nextstep > 0 {
+			mesh.SetStep(nextstep)
+		}
+		if currinf != nil {
+			m.Hmax = currinf.H
+		}
+	}
+
+	kept := filter{}
+	for _, fp := range m.filter {
+		if fp.H <= m.Hmax {
+			kept = append(kept, fp)
+		}
+	}
+	m.filter = kept
+
+	if fp := m.filter.incumbent(m.Hmax); fp != nil {
+		m.CurrInf = fp.Point
+	} else {
+		m.CurrInf = nil
+	}
+
+	if !math.IsInf(m.Curr.Val, 1) {
+		mesh.SetOrigin(m.Curr.Pos)
+	} else if m.CurrInf != nil {
+		mesh.SetOrigin(m.CurrInf.Pos)
+	}
+	return m.Curr, n, collect(err, err2)
+}
+
+// classify updates the feasible incumbent or the infeasible filter with p
+// which has constraint violation h.
+func (m *Method) classify(p *optim.Point, h float64) {
+	if math.IsInf(p.Val, 1) || math.IsNaN(p.Val) {
+		return
+	} else if h == 0 {
+		if p.Val < m.Curr.Val {
+			m.Curr = p
+		}
+	} else if h <= m.Hmax {
+		m.filter = m.filter.add(filterpoint{p, h})
+	}
+}
@@ -0,0 +1,204 @@
+package pattern
+
+import (
+	"math"
+	"testing"
+
+	"github.com/baaaaam/optim"
+)
+
+// diskobj is minimize x+y subject to x^2 + y^2 <= 1 and y <= 0.5.
+var diskobj = optim.ConstrFunc{
+	Obj: func(x []float64) float64 { return x[0] + x[1] },
+	Constr: func(x []float64) []float64 {
+		return []float64{x[0]*x[0] + x[1]*x[1] - 1, x[1] - 0.5}
+	},
+}
+
+func barriersolver(start []float64, opts ...Option) *optim.Solver {
+	p := &optim.Point{Pos: start, Val: math.Inf(1)}
+	mesh := &optim.InfMesh{StepSize: 0.5}
+	mesh.SetOrigin(p.Pos)
+	return &optim.Solver{
+		Method:  New(p, opts...),
+		Obj:     diskobj,
+		Mesh:    mesh,
+		MaxEval: 20000,
+		MinStep: 1e-7,
+	}
+}
+
+func TestExtremeBarrier(t *testing.T) {
+	// compass polling may stall on the curved boundary short of the optimum
+	// at -sqrt(2)
+	want := -1.0
+	solv := barriersolver([]float64{0.1, 0.2}, Barrier(ExtremeBarrier))
+	if err := solv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), best)
+
+	if g, _ := diskobj.Constraints(best.Pos); optim.Violation(g) > 0 {
+		t.Errorf("best point %v is infeasible: g = %v", best.Pos, g)
+	}
+	if best.Val > want {
+		t.Errorf("want < %v, got %v", want, best.Val)
+	}
+}
+
+func TestProgressiveBarrier(t *testing.T) {
+	want := -math.Sqrt2
+
+	// extreme barrier can't make progress from an infeasible start
+	eb := barriersolver([]float64{3, 4}, Barrier(ExtremeBarrier))
+	eb.Run()
+	if !math.IsInf(eb.Best().Val, 1) {
+		t.Errorf("extreme barrier from infeasible start: want +Inf, got %v", eb.Best())
+	}
+
+	solv := barriersolver([]float64{3, 4}, Barrier(ProgressiveBarrier))
+	if err := solv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), best)
+
+	if g, _ := diskobj.Constraints(best.Pos); optim.Violation(g) > 0 {
+		t.Errorf("best point %v is infeasible: g = %v", best.Pos, g)
+	}
+	if math.Abs(best.Val-want) > 1e-3 {
+		t.Errorf("want %v, got %v", want, best.Val)
+	}
+}
+
+func TestAugLagrangian(t *testing.T) {
+	lag := &optim.AugLagrangian{Obj: diskobj}
+	solv := barriersolver([]float64{0, 0})
+	solv.Obj = lag
+	if err := solv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v, lambda=%v", solv.Neval(), best.Pos, lag.Lambda)
+
+	x := best.Pos
+	if got, want := diskobj.Obj(x), -math.Sqrt2; math.Abs(got-want) > 1e-3 {
+		t.Errorf("objective: want %v, got %v", want, got)
+	}
+	if g := diskobj.Constr(x); optim.Violation(g) > 1e-6 {
+		t.Errorf("solution %v is infeasible: g = %v", x, g)
+	}
+	if want := 1 / math.Sqrt2; math.Abs(lag.Lambda[0]-want) > 1e-2 {
+		t.Errorf("multiplier: want %v, got %v", want, lag.Lambda[0])
+	}
+	if val, _ := lag.Objective(x); math.Abs(best.Val-val) > 1e-12 {
+		t.Errorf("best value is stale: want %v for the final multipliers, got %v", val, best.Val)
+	}
+}
+
+func TestAdaptivePenalty(t *testing.T) {
+	pen := &optim.AdaptivePenalty{Obj: diskobj, MinWeight: 1e3}
+	solv := barriersolver([]float64{3, 4})
+	solv.Obj = pen
+	if err := solv.Run(); err != nil {
+		t.Fatal(err)
+	}
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v, weight=%v", solv.Neval(), best, pen.Weight)
+
+	// like the extreme barrier, compass polling may stall on the curved
+	// boundary short of the optimum at -sqrt(2)
+	if got, want := diskobj.Obj(best.Pos), -1.0; got > want {
+		t.Errorf("objective: want < %v, got %v", want, got)
+	}
+	if g := diskobj.Constr(best.Pos); optim.Violation(g) > 1e-6 {
+		t.Errorf("solution %v is infeasible: g = %v", best.Pos, g)
+	}
+	if val, _ := pen.Objective(best.Pos); math.Abs(best.Val-val) > 1e-12 {
+		t.Errorf("best value is stale: want %v for the final weight, got %v", val, best.Val)
+	}
+}
+
+func TestHmaxZero(t *testing.T) {
+	solv := barriersolver([]float64{3, 4}, Barrier(ProgressiveBarrier))
+	m := solv.Method.(*Method)
+	if _, _, err := m.Iterate(diskobj, solv.Mesh); err != nil {
+		t.Fatal(err)
+	}
+
+	// a threshold tightened to zero after the first iteration must stick
+	m.Hmax = 0
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Iterate(diskobj, solv.Mesh); err != nil {
+			t.Fatal(err)
+		}
+		if m.Hmax != 0 {
+			t.Fatalf("iter %v: want Hmax to stay 0, got %v", i+2, m.Hmax)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := filter{}
+	f = f.add(filterpoint{&optim.Point{Val: 5}, 1})
+	f = f.add(filterpoint{&optim.Point{Val: 3}, 2})
+	f = f.add(filterpoint{&optim.Point{Val: 6}, 2}) // dominated
+	f = f.add(filterpoint{&optim.Point{Val: 4}, 0.5})
+
+	if len(f) != 2 {
+		t.Errorf("filter should have 2 non-dominated points, got %v", len(f))
+	}
+	if inc := f.incumbent(math.Inf(1)); inc.Val != 3 {
+		t.Errorf("incumbent: want val 3, got %v", inc.Val)
+	}
+	if inc := f.incumbent(1); inc.Val != 4 {
+		t.Errorf("incumbent with hmax=1: want val 4, got %v", inc.Val)
+	}
+}
+
+// shifted adds Shift to diskobj's objective.
+type shifted struct {
+	optim.ConstrFunc
+	Shift float64
+}
+
+func (o *shifted) Objective(v []float64) (float64, error) {
+	val, err := o.ConstrFunc.Objective(v)
+	return val + o.Shift, err
+}
+
+func TestRescore(t *testing.T) {
+	obj := &shifted{ConstrFunc: diskobj}
+	feas, infeas := []float64{0, 0}, []float64{3, 4}
+
+	m := New(&optim.Point{Pos: feas, Val: math.Inf(1)}, Evaler(optim.NewCacheEvaler(optim.SerialEvaler{})))
+	m.ev.Eval(obj, m.Curr)
+	obj.Shift = 1
+	if best, _, err := m.Rescore(obj); err != nil {
+		t.Fatal(err)
+	} else if best.Val != 1 {
+		t.Errorf("cached incumbent: want value 1 for the shifted objective, got %v", best.Val)
+	}
+
+	m = New(&optim.Point{Pos: infeas, Val: 7}, Barrier(ExtremeBarrier))
+	if best, _, _ := m.Rescore(obj); !math.IsInf(best.Val, 1) {
+		t.Errorf("extreme barrier: want +Inf for infeasible incumbent, got %v", best.Val)
+	}
+
+	solv := barriersolver(infeas, Barrier(ProgressiveBarrier))
+	solv.Obj = obj
+	m = solv.Method.(*Method)
+	for i := 0; i < 3; i++ {
+		solv.Next()
+	}
+	if m.CurrInf == nil {
+		t.Fatal("want an infeasible incumbent after polling from an infeasible start")
+	}
+	pos, val := m.CurrInf.Pos, m.CurrInf.Val
+	obj.Shift = 10
+	m.Rescore(obj)
+	if m.CurrInf == nil || m.CurrInf.Val != val+9 || m.CurrInf.Pos[0] != pos[0] || m.CurrInf.Pos[1] != pos[1] {
+		t.Errorf("progressive barrier: want infeasible incumbent %v with value %v, got %v", pos, val+9, m.CurrInf)
+	}
+}
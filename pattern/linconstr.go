@@ -0,0 +1,329 @@
+package pattern
+
+import (
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+	"github.com/gonum/matrix/mat64"
+)
+
+// PointSpanner is a Spanner whose poll directions depend on the poll center
+// and mesh.  If a Poller's Spanner implements PointSpanner, SpanAt is used
+// instead of Span to generate poll directions.
+type PointSpanner interface {
+	Spanner
+	// SpanAt returns a set of polling directions for a poll centered on from
+	// using mesh m.  Direction components may be any integer - a poll point
+	// is placed at from.Pos[i] + dir[i]*step[i] for each dimension i.
+	SpanAt(from *optim.Point, m optim.Mesh) [][]int
+}
+
+// DefaultResolution is the default LinConstr.Resolution.
+const DefaultResolution = 4
+
+// LinConstr is a Spanner that generates poll directions spanning the tangent
+// cone of the linear constraints low <= Ax <= up that are nearly active at
+// the poll center (the epsilon-active set).  This allows polls to move along
+// constraint boundaries instead of stalling against them as compass
+// directions do.  The constraints are specified identically to those of
+// optim.ObjectivePenalty.  The generators are computed as described in:
+//
+//     Lewis, Robert Michael, and Virginia Torczon. "Pattern search methods
+//     for linearly constrained minimization." SIAM Journal on Optimization
+//     10.3 (2000): 917-941.
+//
+// If the epsilon-active constraint normals are linearly dependent
+// (degenerate), constraints are dropped in order of decreasing slack until
+// the remaining normals are independent.  When no constraints are
+// epsilon-active, LinConstr polls in the 2n compass directions.
+type LinConstr struct {
+	A       *mat64.Dense
+	Low, Up *mat64.Dense
+	// Eps is the distance to a constraint boundary within which a constraint
+	// is considered active.  If zero, the largest mesh step is used.
+	Eps float64
+	// Resolution is the largest absolute integer component used when
+	// rounding the (real-valued) generators to integer poll directions.
+	// Larger values approximate the generators more accurately at the cost
+	// of longer poll steps.  If zero, DefaultResolution is used.
+	Resolution int
+	a          *mat64.Dense // stacked version of A
+	b          *mat64.Dense // Low and Up stacked
+}
+
+// PollLinConstr sets the method to poll in directions that span the tangent
+// cone of the nearly-active constraints low <= Ax <= up.
+func PollLinConstr(low, A, up *mat64.Dense) Option {
+	return func(m *Method) {
+		m.Poller.Spanner = &LinConstr{A: A, Low: low, Up: up}
+	}
+}
+
+func (s *LinConstr) init() {
+	if s.a != nil {
+		// already initialized
+		return
+	}
+	s.a, s.b, _ = optim.StackConstr(s.Low, s.A, s.Up)
+}
+
+func (s *LinConstr) Update(step float64, prevsuccess bool) {}
+
+// Span returns compass directions - a poll center is required to compute
+// the tangent cone.
+func (s *LinConstr) Span(ndim int) [][]int { return Compass2N{}.Span(ndim) }
+
+func (s *LinConstr) SpanAt(from *optim.Point, m optim.Mesh) [][]int {
+	s.init()
+	ndim := from.Len()
+
+	// scaling the constraints by the mesh steps makes the generators tangent
+	// after polls are scaled by per-dimension mesh steps.
+	steps := optim.Steps(m, ndim)
+	for i := range steps {
+		if steps[i] == 0 {
+			steps[i] = 1
+		}
+	}
+
+	eps := s.Eps
+	if eps == 0 {
+		for _, step := range steps {
+			eps = math.Max(eps, step)
+		}
+	}
+
+	active := s.activeset(from.Pos, eps)
+	normals := make([][]float64, len(active))
+	for i, row := range active {
+		normals[i] = make([]float64, ndim)
+		for j := range normals[i] {
+			normals[i][j] = s.a.At(row, j) * steps[j]
+		}
+	}
+
+	gens := tangentcone(normals, ndim)
+	if gens == nil {
+		return Compass2N{}.Span(ndim)
+	}
+
+	res := s.Resolution
+	if res == 0 {
+		res = DefaultResolution
+	}
+	dirs := make([][]int, 0, len(gens))
+	for _, g := range gens {
+		if d := intdirec(g, res); d != nil {
+			dirs = append(dirs, d)
+		}
+	}
+	perms := optim.Rand.Perm(len(dirs))
+	shuffled := make([][]int, len(dirs))
+	for i, p := range perms {
+		shuffled[p] = dirs[i]
+	}
+	return shuffled
+}
+
+// activeset returns the indices of stacked constraint rows that are within
+// a distance eps of x sorted by ascending slack.
+func (s *LinConstr) activeset(x []float64, eps float64) []int {
+	m, n := s.a.Dims()
+	active := []int{}
+	slacks := []float64{}
+	for i := 0; i < m; i++ {
+		ax := 0.0
+		nrm := 0.0
+		for j := 0; j < n; j++ {
+			ax += s.a.At(i, j) * x[j]
+			nrm += s.a.At(i, j) * s.a.At(i, j)
+		}
+		if nrm == 0 {
+			continue
+		}
+		slack := (s.b.At(i, 0) - ax) / math.Sqrt(nrm)
+		if slack <= eps {
+			active = append(active, i)
+			slacks = append(slacks, slack)
+		}
+	}
+	sort.Sort(byslack{active, slacks})
+	return active
+}
+
+type byslack struct {
+	rows   []int
+	slacks []float64
+}
+
+func (b byslack) Len() int           { return len(b.rows) }
+func (b byslack) Less(i, j int) bool { return b.slacks[i] < b.slacks[j] }
+func (b byslack) Swap(i, j int) {
+	b.rows[i], b.rows[j] = b.rows[j], b.rows[i]
+	b.slacks[i], b.slacks[j] = b.slacks[j], b.slacks[i]
+}
+
+// tangentcone returns a set of generators for the cone {d : N*d <= 0} where
+// the rows of N are normals.  Pairs of opposing normals (i.e. equality
+// constraints) restrict the cone to their null space.  It returns nil if
+// there are no normals.
+func tangentcone(normals [][]float64, ndim int) [][]float64 {
+	if len(normals) == 0 {
+		return nil
+	}
+
+	// separate equality pairs from inequalities
+	eqs, ineqs := [][]float64{}, [][]float64{}
+	for i, a := range normals {
+		opposed := false
+		for j, b := range normals {
+			if i != j && cosine(a, b) < -1+1e-10 {
+				opposed = true
+				break
+			}
+		}
+		if opposed {
+			eqs = append(eqs, a)
+		} else {
+			ineqs = append(ineqs, a)
+		}
+	}
+
+	// basis for the subspace satisfying the equalities
+	z := nullspace(eqs, ndim)
+	if len(z) == 0 {
+		return nil
+	}
+
+	// project inequality normals into the subspace coordinates and keep a
+	// linearly independent subset (in order of increasing slack).
+	reduced := [][]float64{}
+	for _, a := range ineqs {
+		r := make([]float64, len(z))
+		for k, zk := range z {
+			r[k] = dot(a, zk)
+		}
+		if len(orthobasis(append(reduced, r))) == len(reduced)+1 {
+			reduced = append(reduced, r)
+		}
+	}
+
+	// generators in reduced coordinates: columns of -N^T(N N^T)^-1 plus a
+	// positive spanning set for the null space of N.
+	gens := [][]float64{}
+	if len(reduced) > 0 {
+		nnt := mat64.NewDense(len(reduced), len(reduced), nil)
+		for i := range reduced {
+			for j := range reduced {
+				nnt.Set(i, j, dot(reduced[i], reduced[j]))
+			}
+		}
+		if inv, err := mat64.Inverse(nnt); err == nil {
+			for j := range reduced {
+				g := make([]float64, len(z))
+				for i, wi := range inv.Col(nil, j) {
+					for k := range g {
+						g[k] -= wi * reduced[i][k]
+					}
+				}
+				gens = append(gens, g)
+			}
+		}
+	}
+	for _, v := range nullspace(reduced, len(z)) {
+		neg := make([]float64, len(v))
+		for i := range v {
+			neg[i] = -v[i]
+		}
+		gens = append(gens, v, neg)
+	}
+
+	// map back to full coordinates
+	full := make([][]float64, len(gens))
+	for i, g := range gens {
+		full[i] = make([]float64, ndim)
+		for k, zk := range z {
+			for j := range full[i] {
+				full[i][j] += g[k] * zk[j]
+			}
+		}
+	}
+	return full
+}
+
+// orthobasis returns an orthonormal basis for the span of vecs using
+// modified Gram-Schmidt.  Nearly dependent vectors are discarded.
+func orthobasis(vecs [][]float64) [][]float64 {
+	basis := [][]float64{}
+	for _, v := range vecs {
+		u := append([]float64{}, v...)
+		for _, b := range basis {
+			proj := dot(u, b)
+			for i := range u {
+				u[i] -= proj * b[i]
+			}
+		}
+		if nrm := norm(u); nrm > 1e-10*math.Max(1, norm(v)) {
+			for i := range u {
+				u[i] /= nrm
+			}
+			basis = append(basis, u)
+		}
+	}
+	return basis
+}
+
+// nullspace returns an orthonormal basis for the null space of the matrix
+// with the given rows and ndim columns.  Basis vectors are preferentially
+// aligned with coordinate axes.
+func nullspace(rows [][]float64, ndim int) [][]float64 {
+	rowbasis := orthobasis(rows)
+	vecs := append([][]float64{}, rowbasis...)
+	for i := 0; i < ndim; i++ {
+		e := make([]float64, ndim)
+		e[i] = 1
+		vecs = append(vecs, e)
+	}
+	return orthobasis(vecs)[len(rowbasis):]
+}
+
+// intdirec scales v so its largest absolute component equals res, rounds
+// each component to the nearest integer and divides out their greatest
+// common divisor.  It returns nil for a zero vector.
+func intdirec(v []float64, res int) []int {
+	max := 0.0
+	for _, x := range v {
+		max = math.Max(max, math.Abs(x))
+	}
+	if max == 0 {
+		return nil
+	}
+
+	d := make([]int, len(v))
+	div := 0
+	for i, x := range v {
+		d[i] = int(math.Floor(x/max*float64(res) + 0.5))
+		div = gcd(div, d[i])
+	}
+	if div == 0 {
+		return nil
+	}
+	for i := range d {
+		d[i] /= div
+	}
+	return d
+}
+
+func gcd(a, b int) int {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
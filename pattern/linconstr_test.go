@@ -0,0 +1,114 @@
+package pattern
+
+import (
+	"math"
+	"testing"
+
+	"github.com/baaaaam/optim"
+	"github.com/gonum/matrix/mat64"
+)
+
+func TestTangentCone(t *testing.T) {
+	tests := []struct {
+		Normals [][]float64
+		Ndim    int
+		Ngen    int
+	}{
+		{[][]float64{{1, 1}}, 2, 3},
+		{[][]float64{{1, 2, 0}, {0, -1, 1}}, 3, 4},
+		{[][]float64{{1, 1, 1}, {-1, -1, -1}}, 3, 4},          // equality
+		{[][]float64{{1, 0}, {0, 1}, {1, 1}}, 2, 2},           // degenerate
+		{[][]float64{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}}, 3, 3}, // mixed
+	}
+
+	for i, test := range tests {
+		gens := tangentcone(test.Normals, test.Ndim)
+		if len(gens) != test.Ngen {
+			t.Errorf("test %v: want %v generators, got %v: %v", i, test.Ngen, len(gens), gens)
+		}
+		for _, g := range gens {
+			for _, a := range test.Normals {
+				if v := dot(a, g); v > 1e-10 {
+					t.Errorf("test %v: generator %v leaves cone: normal %v dot = %v", i, g, a, v)
+				}
+			}
+		}
+	}
+}
+
+func TestIntDirec(t *testing.T) {
+	tests := []struct {
+		V   []float64
+		Res int
+		Exp []int
+	}{
+		{[]float64{0.5, -0.25}, 4, []int{2, -1}},
+		{[]float64{0, 3, 0}, 4, []int{0, 1, 0}},
+		{[]float64{1, 0.3}, 4, []int{4, 1}},
+		{[]float64{0, 0}, 4, nil},
+	}
+	for i, test := range tests {
+		got := intdirec(test.V, test.Res)
+		if len(got) != len(test.Exp) {
+			t.Errorf("test %v: want %v, got %v", i, test.Exp, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != test.Exp[j] {
+				t.Errorf("test %v: want %v, got %v", i, test.Exp, got)
+				break
+			}
+		}
+	}
+}
+
+func TestPollLinConstr(t *testing.T) {
+	// minimize distance to (2,2) subject to x+y <= 1 starting on the
+	// constraint boundary where compass polling stalls.
+	A := mat64.NewDense(1, 2, []float64{1, 1})
+	low := mat64.NewDense(1, 1, []float64{-100})
+	up := mat64.NewDense(1, 1, []float64{1})
+	obj := &optim.ObjectivePenalty{
+		Obj: optim.Func(func(x []float64) float64 {
+			return (x[0]-2)*(x[0]-2) + (x[1]-2)*(x[1]-2)
+		}),
+		A:      A,
+		Low:    low,
+		Up:     up,
+		Weight: 1e6,
+	}
+	want := 4.5
+
+	run := func(opts ...Option) *optim.Solver {
+		// evaluate the start so that compass polling stalls there instead
+		// of accepting whichever poll point happens to be evaluated first
+		pos := []float64{1, 0}
+		val, _ := obj.Objective(pos)
+		start := &optim.Point{Pos: pos, Val: val}
+		mesh := &optim.InfMesh{StepSize: 0.5}
+		mesh.SetOrigin(start.Pos)
+		solv := &optim.Solver{
+			Method:  New(start, opts...),
+			Obj:     obj,
+			Mesh:    mesh,
+			MaxEval: 5000,
+			MinStep: 1e-6,
+		}
+		if err := solv.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return solv
+	}
+
+	compass := run(Poll2N)
+	constr := run(PollLinConstr(low, A, up))
+	t.Logf("[INFO] compass: %v evals, got %v", compass.Neval(), compass.Best())
+	t.Logf("[INFO] tangent cone: %v evals, got %v", constr.Neval(), constr.Best())
+
+	if got := constr.Best().Val; math.Abs(got-want) > 1e-4 {
+		t.Errorf("tangent cone polling failed: want %v, got %v", want, got)
+	}
+	if got := compass.Best().Val; got != 5 {
+		t.Errorf("compass polling didn't stall at the start: want 5, got %v", got)
+	}
+}
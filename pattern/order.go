@@ -0,0 +1,308 @@
+package pattern
+
+import (
+	"math"
+	"sort"
+
+	"github.com/baaaaam/optim"
+	"github.com/gonum/matrix/mat64"
+)
+
+// Orderer determines the sequence in which poll points are evaluated.  With
+// opportunistic polling (i.e. evaluating with optim.SerialEvaler), polling
+// stops at the first improvement, so placing likely-successful points first
+// reduces the number of evaluations per successful poll.
+type Orderer interface {
+	// Order reorders polls in-place into the sequence in which they should be
+	// evaluated when polling around from.
+	Order(from *optim.Point, polls []*optim.Point)
+	// Update informs the orderer of the results of the most recent poll
+	// around from.  results contains only the evaluated points.
+	Update(from *optim.Point, results []*optim.Point)
+}
+
+// byscore sorts points by ascending score.
+type byscore struct {
+	pts    []*optim.Point
+	scores []float64
+}
+
+func (b byscore) Len() int           { return len(b.pts) }
+func (b byscore) Less(i, j int) bool { return b.scores[i] < b.scores[j] }
+func (b byscore) Swap(i, j int) {
+	b.pts[i], b.pts[j] = b.pts[j], b.pts[i]
+	b.scores[i], b.scores[j] = b.scores[j], b.scores[i]
+}
+
+// orderby stably sorts polls in ascending order of score(p).  A stable sort
+// is used so that ties retain the spanner's (usually random) ordering.
+func orderby(polls []*optim.Point, score func(p *optim.Point) float64) {
+	scores := make([]float64, len(polls))
+	for i, p := range polls {
+		scores[i] = score(p)
+	}
+	sort.Stable(byscore{polls, scores})
+}
+
+// LastSuccessOrder orders poll points so that those pointing most closely in
+// the direction of the last successful poll step are evaluated first.
+type LastSuccessOrder struct {
+	dir []float64
+}
+
+func (o *LastSuccessOrder) Order(from *optim.Point, polls []*optim.Point) {
+	if o.dir == nil {
+		return
+	}
+	orderby(polls, func(p *optim.Point) float64 {
+		return -cosine(o.dir, diff(p.Pos, from.Pos))
+	})
+}
+
+func (o *LastSuccessOrder) Update(from *optim.Point, results []*optim.Point) {
+	best := from
+	for _, p := range results {
+		if p.Val < best.Val {
+			best = p
+		}
+	}
+	if best != from {
+		o.dir = diff(best.Pos, from.Pos)
+	}
+}
+
+// DefaultNhist is the default number of most recently evaluated points
+// retained by GradientOrder and ModelOrder for building their estimates.
+const DefaultNhist = 50
+
+// history holds the most recently evaluated points (with finite values).
+type history struct {
+	Nhist int
+	pts   []*optim.Point
+}
+
+func (h *history) add(pts ...*optim.Point) {
+	for _, p := range pts {
+		if !math.IsInf(p.Val, 0) && !math.IsNaN(p.Val) {
+			h.pts = append(h.pts, p)
+		}
+	}
+
+	n := h.Nhist
+	if n == 0 {
+		n = DefaultNhist
+	}
+	if len(h.pts) > n {
+		h.pts = append([]*optim.Point{}, h.pts[len(h.pts)-n:]...)
+	}
+}
+
+// nearest returns up to n history points closest to x excluding points
+// identical to x.
+func (h *history) nearest(x []float64, n int) []*optim.Point {
+	pts := make([]*optim.Point, 0, len(h.pts))
+	for _, p := range h.pts {
+		if len(p.Pos) == len(x) && norm(diff(p.Pos, x)) > 0 {
+			pts = append(pts, p)
+		}
+	}
+	orderby(pts, func(p *optim.Point) float64 { return norm(diff(p.Pos, x)) })
+	if len(pts) > n {
+		pts = pts[:n]
+	}
+	return pts
+}
+
+// GradientOrder orders poll points by ascending directional derivative using
+// a simplex gradient estimated from recently evaluated points near the poll
+// center.  The gradient is the least-squares solution of
+//
+//     f(y_i) - f(x) = g . (y_i - x)
+//
+// for the (up to) Nhist nearest points y_i.  No reordering occurs until at
+// least ndim points are available.
+type GradientOrder struct {
+	history
+}
+
+// NewGradientOrder creates a gradient orderer that retains the nhist most
+// recently evaluated points.
+func NewGradientOrder(nhist int) *GradientOrder {
+	return &GradientOrder{history{Nhist: nhist}}
+}
+
+func (o *GradientOrder) Order(from *optim.Point, polls []*optim.Point) {
+	g := o.Gradient(from)
+	if g == nil {
+		return
+	}
+	orderby(polls, func(p *optim.Point) float64 { return dot(g, diff(p.Pos, from.Pos)) })
+}
+
+func (o *GradientOrder) Update(from *optim.Point, results []*optim.Point) {
+	o.add(from)
+	o.add(results...)
+}
+
+// Gradient returns the simplex gradient estimate at from or nil if there are
+// too few points to compute one.
+func (o *GradientOrder) Gradient(from *optim.Point) []float64 {
+	if math.IsInf(from.Val, 0) {
+		return nil
+	}
+	ndim := from.Len()
+	near := o.nearest(from.Pos, 2*ndim)
+	if len(near) < ndim {
+		return nil
+	}
+
+	rows := make([][]float64, len(near))
+	rhs := make([]float64, len(near))
+	for i, p := range near {
+		rows[i] = diff(p.Pos, from.Pos)
+		rhs[i] = p.Val - from.Val
+	}
+	return lstsq(rows, rhs)
+}
+
+// Model is a surrogate of the objective function that can be fit to
+// evaluated points and used to predict objective values at other points.
+type Model interface {
+	// Fit builds the model around center from pts.  It returns false if the
+	// model could not be built (e.g. too few points).
+	Fit(center *optim.Point, pts []*optim.Point) bool
+	Predict(x []float64) float64
+}
+
+// ModelOrder orders poll points by ascending objective value predicted by a
+// local surrogate Model fit to recently evaluated points.  If Model is nil, a
+// QuadModel is used.
+type ModelOrder struct {
+	Model Model
+	history
+}
+
+// NewModelOrder creates a surrogate model orderer that retains the nhist most
+// recently evaluated points.
+func NewModelOrder(m Model, nhist int) *ModelOrder {
+	return &ModelOrder{Model: m, history: history{Nhist: nhist}}
+}
+
+func (o *ModelOrder) Order(from *optim.Point, polls []*optim.Point) {
+	if o.Model == nil {
+		o.Model = &QuadModel{}
+	}
+	if math.IsInf(from.Val, 0) {
+		return
+	}
+	pts := append(o.nearest(from.Pos, len(o.pts)), from)
+	if !o.Model.Fit(from, pts) {
+		return
+	}
+	orderby(polls, func(p *optim.Point) float64 { return o.Model.Predict(p.Pos) })
+}
+
+func (o *ModelOrder) Update(from *optim.Point, results []*optim.Point) {
+	o.add(from)
+	o.add(results...)
+}
+
+// QuadModel is a separable quadratic model (no cross terms) of the form:
+//
+//     f(x) = c + g . d + 1/2 * sum(h_i * d_i^2)    where d = x - center
+//
+// It is fit in the least squares sense and requires at least 2n+1 points.
+// If fewer points are available but at least n+1, a linear model is fit
+// instead.
+type QuadModel struct {
+	center []float64
+	coeffs []float64
+}
+
+func (q *QuadModel) Fit(center *optim.Point, pts []*optim.Point) bool {
+	ndim := center.Len()
+	nterms := 1 + 2*ndim
+	if len(pts) < nterms {
+		nterms = 1 + ndim
+	}
+	if len(pts) < nterms {
+		return false
+	}
+
+	rows := make([][]float64, len(pts))
+	rhs := make([]float64, len(pts))
+	for i, p := range pts {
+		d := diff(p.Pos, center.Pos)
+		row := make([]float64, nterms)
+		row[0] = 1
+		for j, v := range d {
+			row[1+j] = v
+			if nterms > 1+ndim {
+				row[1+ndim+j] = v * v / 2
+			}
+		}
+		rows[i] = row
+		rhs[i] = p.Val
+	}
+
+	coeffs := lstsq(rows, rhs)
+	if coeffs == nil {
+		return false
+	}
+	q.center = append([]float64{}, center.Pos...)
+	q.coeffs = coeffs
+	return true
+}
+
+func (q *QuadModel) Predict(x []float64) float64 {
+	ndim := len(q.center)
+	d := diff(x, q.center)
+	val := q.coeffs[0]
+	for j, v := range d {
+		val += q.coeffs[1+j] * v
+		if len(q.coeffs) > 1+ndim {
+			val += q.coeffs[1+ndim+j] * v * v / 2
+		}
+	}
+	return val
+}
+
+// lstsq returns the least squares solution x to rows*x = rhs.  It returns
+// nil if the system is rank deficient.
+func lstsq(rows [][]float64, rhs []float64) []float64 {
+	if len(rows) == 0 {
+		return nil
+	}
+	n := len(rows[0])
+	data := make([]float64, 0, len(rows)*n)
+	for _, row := range rows {
+		data = append(data, row...)
+	}
+	x, err := mat64.Solve(mat64.NewDense(len(rows), n, data), mat64.NewDense(len(rhs), 1, rhs))
+	if err != nil {
+		return nil
+	}
+	return x.Col(nil, 0)
+}
+
+func diff(a, b []float64) []float64 {
+	d := make([]float64, len(a))
+	for i := range a {
+		d[i] = a[i] - b[i]
+	}
+	return d
+}
+
+func dot(a, b []float64) float64 {
+	return mat64.NewDense(len(a), 1, a).Dot(mat64.NewDense(len(b), 1, b))
+}
+
+func norm(a []float64) float64 { return math.Sqrt(dot(a, a)) }
+
+func cosine(a, b []float64) float64 {
+	na, nb := norm(a), norm(b)
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot(a, b) / na / nb
+}
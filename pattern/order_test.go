@@ -0,0 +1,129 @@
+package pattern
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/baaaaam/optim"
+)
+
+// linpolls returns compass poll points around from and evaluates them with
+// fn.
+func linpolls(from *optim.Point, fn func([]float64) float64) []*optim.Point {
+	polls := []*optim.Point{}
+	for _, d := range (Compass2N{}).Span(from.Len()) {
+		pos := make([]float64, from.Len())
+		for i := range pos {
+			pos[i] = from.Pos[i] + float64(d[i])
+		}
+		polls = append(polls, &optim.Point{Pos: pos, Val: fn(pos)})
+	}
+	return polls
+}
+
+func TestGradientOrder(t *testing.T) {
+	fn := func(x []float64) float64 { return 3*x[0] - 2*x[1] + x[2] }
+	from := &optim.Point{Pos: []float64{1, 1, 1}}
+	from.Val = fn(from.Pos)
+
+	o := NewGradientOrder(0)
+	o.Update(from, linpolls(from, fn))
+
+	want := []float64{3, -2, 1}
+	g := o.Gradient(from)
+	for i := range want {
+		if math.Abs(g[i]-want[i]) > 1e-6 {
+			t.Errorf("gradient[%v]: want %v, got %v", i, want[i], g[i])
+		}
+	}
+
+	polls := linpolls(from, fn)
+	o.Order(from, polls)
+	for i := 1; i < len(polls); i++ {
+		if polls[i].Val < polls[i-1].Val {
+			t.Errorf("poll %v (val=%v) ordered after worse poll (val=%v)", i, polls[i].Val, polls[i-1].Val)
+		}
+	}
+}
+
+func TestModelOrder(t *testing.T) {
+	fn := func(x []float64) float64 { return (x[0]-0.4)*(x[0]-0.4) + 5*(x[1]+0.2)*(x[1]+0.2) }
+	from := &optim.Point{Pos: []float64{0, 0}}
+	from.Val = fn(from.Pos)
+
+	o := NewModelOrder(nil, 0)
+	o.Update(from, linpolls(from, fn))
+
+	polls := linpolls(from, fn)
+	o.Order(from, polls)
+	for i := range polls {
+		if got := o.Model.Predict(polls[i].Pos); math.Abs(got-polls[i].Val) > 1e-6 {
+			t.Errorf("model prediction at %v: want %v, got %v", polls[i].Pos, polls[i].Val, got)
+		}
+		if i > 0 && polls[i].Val < polls[i-1].Val {
+			t.Errorf("poll %v (val=%v) ordered after worse poll (val=%v)", i, polls[i].Val, polls[i-1].Val)
+		}
+	}
+}
+
+func TestLastSuccessOrder(t *testing.T) {
+	fn := func(x []float64) float64 { return -x[0] - 0.1*x[1] }
+	from := &optim.Point{Pos: []float64{0, 0}}
+	from.Val = fn(from.Pos)
+
+	o := &LastSuccessOrder{}
+	o.Update(from, linpolls(from, fn))
+
+	polls := linpolls(from, fn)
+	o.Order(from, polls)
+	if want, got := []float64{1, 0}, polls[0].Pos; got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("first poll: want %v, got %v", want, got)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	fn := func(x []float64) float64 {
+		tot := 0.0
+		for i, v := range x {
+			tot += float64(i+1) * (v - 1) * (v - 1)
+		}
+		return tot
+	}
+	tol := 1e-4
+
+	// seed every run identically so that only the ordering differs
+	defer func(r optim.Rng) { optim.Rand = r }(optim.Rand)
+
+	unordered := 0
+	for _, o := range []Orderer{nil, &LastSuccessOrder{}, NewGradientOrder(0), NewModelOrder(nil, 0)} {
+		optim.Rand = rand.New(rand.NewSource(1))
+		start := &optim.Point{Pos: []float64{-3, 4, 2, -1}, Val: math.Inf(1)}
+		mesh := &optim.InfMesh{StepSize: 2}
+		mesh.SetOrigin(start.Pos)
+		solv := &optim.Solver{
+			Method:  New(start, OrderBy(o)),
+			Obj:     optim.Func(fn),
+			Mesh:    mesh,
+			MaxEval: 5000,
+		}
+		for solv.Next() {
+			if solv.Best().Val < tol {
+				break
+			}
+		}
+		if err := solv.Err(); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("[INFO] %T: %v evals, got %v", o, solv.Neval(), solv.Best().Val)
+		if solv.Best().Val > tol {
+			t.Errorf("%T: failed to optimize: want < %v, got %v", o, tol, solv.Best().Val)
+		}
+
+		if o == nil {
+			unordered = solv.Neval()
+		} else if solv.Neval() >= unordered {
+			t.Errorf("%T: want fewer than the %v evals of unordered polling, got %v", o, unordered, solv.Neval())
+		}
+	}
+}
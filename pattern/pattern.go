@@ -43,6 +43,16 @@ func DiscreteSearch(m *Method) {
 	m.DiscreteSearch = true
 }
 
+// Anisotropic sets the method to resize the mesh step independently in each
+// dimension - only dimensions along which a successful poll moved are
+// grown, and a failed poll following successes only contracts the
+// dimensions those successes did not move along.  A failed poll following
+// another failure contracts every dimension.  This is only meaningful for
+// meshes implementing optim.AnisoMesh.
+func Anisotropic(m *Method) {
+	m.Anisotropic = true
+}
+
 // Poll2N sets the method to poll in both forward and backward in every
 // compass direction.
 func Poll2N(m *Method) { m.Poller.Spanner = Compass2N{} }
@@ -84,6 +94,18 @@ func SkipEps(eps float64) Option { return func(m *Method) { m.Poller.SkipEps = e
 
 func Nkeep(n int) Option { return func(m *Method) { m.Poller.Nkeep = n } }
 
+// OrderBy sets the strategy used to order poll points for evaluation.
+func OrderBy(o Orderer) Option { return func(m *Method) { m.Poller.Orderer = o } }
+
+// NoiseAware makes polls accept only points whose value is lower than the
+// poll center's by more than z combined standard errors (see optim.Better).
+// noise is a prior estimate of the objective's noise standard deviation -
+// usually the same as the evaler's.  This requires points with sample
+// statistics - e.g. from an optim.ResampleEvaler set with the Evaler option.
+func NoiseAware(z, noise float64) Option {
+	return func(m *Method) { m.Poller.NoiseZ, m.Poller.Noise = z, noise }
+}
+
 func ResetStep(threshold, tostep float64) Option {
 	return func(m *Method) { m.ResetStep = threshold; m.ResetStepSize = tostep }
 }
@@ -93,6 +115,7 @@ type Method struct {
 	Searcher       Searcher
 	Curr           *optim.Point
 	DiscreteSearch bool // true to project search points onto poll step size mesh
+	Anisotropic    bool // true to resize mesh steps independently per dimension
 	NsuccessGrow   int  // number of successive successful polls before growing mesh
 	nsuccess       int  // (internal) number of successive successful polls
 	Db             *sql.DB
@@ -103,9 +126,22 @@ type Method struct {
 	ResetStep     float64
 	ResetStepSize float64
 	StepMult      float64
-	origstep      float64
-	count         int
-	ev            optim.Evaler
+	// Barrier specifies how constraints are handled for objectives that
+	// implement optim.ConstrObjectiver.
+	Barrier BarrierMode
+	// Hmax is the current maximum constraint violation for infeasible points
+	// to be accepted by the progressive barrier.  Zero before the first
+	// iteration means no limit.
+	Hmax float64
+	// CurrInf is the current infeasible incumbent for the progressive
+	// barrier - nil if there is none.
+	CurrInf  *optim.Point
+	filter   filter
+	origstep float64
+	count    int
+	ev       optim.Evaler
+	// moved flags the dimensions moved along since the last failed poll.
+	moved []bool
 }
 
 func New(start *optim.Point, opts ...Option) *Method {
@@ -131,6 +167,63 @@ func (m *Method) AddPoint(p *optim.Point) {
 	}
 }
 
+// Rescore re-evaluates the incumbents using o (e.g. after an optim.Adapter
+// objective changed).  Points are evaluated directly rather than through the
+// method's evaler since a cache would return values of the previous
+// objective.  Constrained objectives are evaluated through the same barrier
+// as in Iterate, and with the progressive barrier the infeasible incumbents
+// are re-classified along with the feasible one.
+func (m *Method) Rescore(o optim.Objectiver) (best *optim.Point, n int, err error) {
+	if m.Curr.Pos == nil {
+		return m.Curr, 0, nil
+	}
+
+	pts := []*optim.Point{m.Curr}
+	var bobj *barrierObj
+	if co, ok := o.(optim.ConstrObjectiver); ok {
+		switch m.Barrier {
+		case ExtremeBarrier:
+			bobj = newBarrierObj(co, 0)
+		case ProgressiveBarrier:
+			bobj = newBarrierObj(co, m.Hmax)
+			for _, fp := range m.filter {
+				pts = append(pts, fp.Point)
+			}
+			m.filter = nil
+		}
+		if bobj != nil {
+			o = bobj
+		}
+	}
+
+	m.Curr = &optim.Point{Pos: m.Curr.Pos, Val: math.Inf(1)}
+	for _, p := range pts {
+		p = &optim.Point{Pos: append([]float64{}, p.Pos...), Val: math.Inf(1)}
+		val, err2 := o.Objective(p.Pos)
+		n++
+		if err2 != nil {
+			err = collect(err, err2)
+			continue
+		}
+		p.Val = val
+
+		if bobj != nil && m.Barrier == ProgressiveBarrier {
+			m.classify(p, bobj.Viol(p))
+		} else if p.Val < m.Curr.Val {
+			m.Curr = p
+		}
+	}
+
+	if m.Barrier == ProgressiveBarrier {
+		if fp := m.filter.incumbent(m.Hmax); fp != nil {
+			m.CurrInf = fp.Point
+		} else {
+			m.CurrInf = nil
+		}
+	}
+	return m.Curr, n, err
+}
+
 // Iterate mutates m and so for each iteration, the same, mutated m should be
 // passed in.
 func (m *Method) Iterate(o optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
@@ -140,6 +233,15 @@ func (m *Method) Iterate(o optim.Objectiver, mesh optim.Mesh) (best *optim.Point
 		mesh.SetStep(m.ResetStepSize)
 	}
 
+	if co, ok := o.(optim.ConstrObjectiver); ok {
+		switch m.Barrier {
+		case ExtremeBarrier:
+			o = newBarrierObj(co, 0)
+		case ProgressiveBarrier:
+			return m.iteratePB(co, mesh)
+		}
+	}
+
 	var nevalsearch, nevalpoll int
 	var success bool
 	defer m.updateDb(&nevalsearch, &nevalpoll, mesh.Step())
@@ -172,10 +274,16 @@ func (m *Method) Iterate(o optim.Objectiver, mesh optim.Mesh) (best *optim.Point
 
 	n += nevalpoll
 	if success {
+		prev := m.Curr
 		m.Curr = best
 		m.nsuccess++
+		m.markMoved(mesh, prev, best)
 		if m.nsuccess == m.NsuccessGrow { // == allows -1 to mean never grow
-			mesh.SetStep(mesh.Step() / m.StepMult)
+			if m.Anisotropic {
+				m.growAlong(mesh, prev, best)
+			} else {
+				mesh.SetStep(mesh.Step() / m.StepMult)
+			}
 			m.nsuccess = 0 // reset after resize
 		}
 
@@ -187,13 +295,63 @@ func (m *Method) Iterate(o optim.Objectiver, mesh optim.Mesh) (best *optim.Point
 		return best, n, collect(err, err2)
 	} else {
 		m.nsuccess = 0
-		if nextstep := mesh.Step() * m.StepMult; nextstep > 0 {
+		if m.Anisotropic {
+			m.shrinkUnmoved(mesh, m.Curr.Len())
+		} else if nextstep := mesh.Step() * m.StepMult; nextstep > 0 {
 			mesh.SetStep(mesh.Step() * m.StepMult)
 		}
+		m.moved = nil
 		return m.Curr, n, collect(err, err2)
 	}
 }
 
+// markMoved records the dimensions along which the successful step from ->
+// to moved.
+func (m *Method) markMoved(mesh optim.Mesh, from, to *optim.Point) {
+	if !m.Anisotropic {
+		return
+	}
+	if m.moved == nil {
+		m.moved = make([]bool, from.Len())
+	}
+	steps := optim.Steps(mesh, from.Len())
+	for i, x0 := range from.Pos {
+		if math.Abs(to.Pos[i]-x0) > steps[i]/2 {
+			m.moved[i] = true
+		}
+	}
+}
+
+// shrinkUnmoved contracts the mesh step in the dimensions not moved along
+// since the last failed poll - or in every dimension if there are none.
+func (m *Method) shrinkUnmoved(mesh optim.Mesh, ndim int) {
+	steps := optim.Steps(mesh, ndim)
+	all := true
+	for i := range steps {
+		if m.moved != nil && m.moved[i] {
+			all = false
+		}
+	}
+	for i := range steps {
+		if all || !m.moved[i] {
+			steps[i] *= m.StepMult
+		}
+	}
+	optim.SetSteps(mesh, steps)
+}
+
+// growAlong grows the mesh step only in the dimensions along which the
+// successful step from -> to moved.
+func (m *Method) growAlong(mesh optim.Mesh, from, to *optim.Point) {
+	steps := optim.Steps(mesh, from.Len())
+	for i, x0 := range from.Pos {
+		if math.Abs(to.Pos[i]-x0) > steps[i]/2 {
+			steps[i] /= m.StepMult
+		}
+	}
+	optim.SetSteps(mesh, steps)
+}
+
 func collect(err1, err2 error) error {
 	if err1 == nil && err2 == nil {
 		return nil
@@ -269,8 +427,12 @@ type Poller struct {
 	// SkipEps is the distance from the center point within which a poll point
 	// is excluded from evaluation.  This can occur if a mesh projection
 	// results in a point being projected back near the poll origin point.
-	SkipEps     float64
-	Spanner     Spanner
+	SkipEps float64
+	Spanner Spanner
+	// Orderer determines the order in which poll points are evaluated.  If
+	// nil, poll points are evaluated in the (random) order generated by
+	// Spanner with previously successful directions interspersed.
+	Orderer     Orderer
 	keepdirecs  []direc
 	points      []*optim.Point
 	prevhash    [sha1.Size]byte
@@ -279,10 +441,29 @@ type Poller struct {
 	// FlipCompass is the number of iterations of consecutive failed polls
 	// after which the poller switches to CompassNp1 polling permanently.
 	FlipCompass int
+	// NoiseZ is the number of combined standard errors by which a poll
+	// point's value must be lower than the poll center's to be accepted.
+	// Zero accepts any strictly lower value.
+	NoiseZ float64
+	// Noise is a prior estimate of the objective's noise standard deviation
+	// used for the standard errors compared with NoiseZ.
+	Noise float64
 }
 
 func (cp *Poller) Points() []*optim.Point { return cp.points }
 
+// initSpanner sets a default spanner appropriate for ndim dimensions if the
+// poller doesn't have one.
+func (cp *Poller) initSpanner(ndim int) {
+	if cp.Spanner != nil {
+		return
+	} else if ndim > 10 {
+		cp.Spanner = &RandomN{N: ndim}
+	} else {
+		cp.Spanner = Compass2N{}
+	}
+}
+
 type direc struct {
 	dir []int
 	val float64
@@ -303,14 +484,7 @@ func (b byval) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 // must be false and best must be from - neval may be non-zero.
 func (cp *Poller) Poll(obj optim.Objectiver, ev optim.Evaler, m optim.Mesh, from *optim.Point) (success bool, best *optim.Point, neval int, err error) {
 	best = from
-	if cp.Spanner == nil {
-		ndim := len(from.Pos)
-		if ndim > 10 {
-			cp.Spanner = &RandomN{N: len(from.Pos)}
-		} else {
-			cp.Spanner = Compass2N{}
-		}
-	}
+	cp.initSpanner(from.Len())
 
 	pollpoints := []*optim.Point{}
 
@@ -369,11 +543,24 @@ func (cp *Poller) Poll(obj optim.Objectiver, ev optim.Evaler, m optim.Mesh, from
 		}
 	}
 
-	objstop := &objStopper{Objectiver: obj, Best: from.Val}
-	results, n, err := ev.Eval(objstop, cp.points...)
+	if cp.Orderer != nil {
+		cp.Orderer.Order(from, cp.points)
+	}
+
+	var stopobj optim.Objectiver = &objStopper{Objectiver: obj, Best: from.Val}
+	if is, ok := ev.(optim.IncumbentSetter); ok {
+		// a single noisy sample better than from means nothing - don't stop
+		// early and compare the resampled means against from instead
+		is.SetIncumbent(from)
+		stopobj = obj
+	}
+	results, n, err := ev.Eval(stopobj, cp.points...)
 	if err == FoundBetterErr {
 		err = nil
 	}
+	if cp.Orderer != nil {
+		cp.Orderer.Update(from, results)
+	}
 
 	// this is separate from best to allow all points better than from to be
 	// added to keepdirecs before we update the best point.
@@ -381,9 +568,10 @@ func (cp *Poller) Poll(obj optim.Objectiver, ev optim.Evaler, m optim.Mesh, from
 
 	// Sort results and keep the best Nkeep as poll directions.
 	for _, p := range results {
-		if p.Val < best.Val {
-			cp.keepdirecs = append(cp.keepdirecs, direc{direcbetween(from, p, m), p.Val})
+		if !optim.Better(p, from, cp.NoiseZ, cp.Noise) {
+			continue
 		}
+		cp.keepdirecs = append(cp.keepdirecs, direc{direcbetween(from, p, m), p.Val})
 		if p.Val < nextbest.Val {
 			nextbest = p
 		}
@@ -400,12 +588,13 @@ func (cp *Poller) Poll(obj optim.Objectiver, ev optim.Evaler, m optim.Mesh, from
 		cp.keepdirecs = cp.keepdirecs[:nkeep]
 	}
 
-	if best.Val < from.Val {
+	success = best != from
+	if success {
 		cp.nConsecFail = 0
 	} else {
 		cp.nConsecFail++
 	}
-	return best.Val < from.Val, best, n, err
+	return success, best, n, err
 }
 
 type Searcher interface {
@@ -458,8 +647,12 @@ func (s *objStopper) Objective(v []float64) (float64, error) {
 }
 
 func genPollPoints(from *optim.Point, span Spanner, m optim.Mesh) []*optim.Point {
-	ndim := from.Len()
-	dirs := span.Span(ndim)
+	var dirs [][]int
+	if ps, ok := span.(PointSpanner); ok {
+		dirs = ps.SpanAt(from, m)
+	} else {
+		dirs = span.Span(from.Len())
+	}
 	polls := make([]*optim.Point, 0, len(dirs))
 	for _, d := range dirs {
 		polls = append(polls, pointFromDirec(from, d, m))
@@ -469,12 +662,12 @@ func genPollPoints(from *optim.Point, span Spanner, m optim.Mesh) []*optim.Point
 
 func pointFromDirec(from *optim.Point, direc []int, m optim.Mesh) *optim.Point {
 	pos := make([]float64, from.Len())
-	step := m.Step()
+	steps := optim.Steps(m, from.Len())
 	for i, x0 := range from.Pos {
-		pos[i] = x0 + float64(direc[i])*step
+		pos[i] = x0 + float64(direc[i])*steps[i]
 
 	}
-	return &optim.Point{m.Nearest(pos), math.Inf(1)}
+	return &optim.Point{Pos: m.Nearest(pos), Val: math.Inf(1)}
 }
 
 // Spanner is returns a set of poll directions (maybe positive spanning set?)
@@ -613,9 +806,11 @@ func (r *RandomN) Span(ndim int) [][]int {
 
 func direcbetween(from, to *optim.Point, m optim.Mesh) []int {
 	d := make([]int, from.Len())
-	step := m.Step()
+	steps := optim.Steps(m, from.Len())
 	for i, x0 := range from.Pos {
-		d[i] = int((to.Pos[i] - x0) / step)
+		if steps[i] != 0 {
+			d[i] = int((to.Pos[i] - x0) / steps[i])
+		}
 	}
 	return d
 }
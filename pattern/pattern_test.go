@@ -9,6 +9,7 @@ import (
 	_ "github.com/baaaaam/go-sqlite/sqlite3"
 	"github.com/baaaaam/optim"
 	"github.com/baaaaam/optim/bench"
+	"github.com/gonum/matrix/mat64"
 )
 
 func TestDb(t *testing.T) {
@@ -93,6 +94,140 @@ func patternsolver(fn bench.Func, db *sql.DB) (optim.Method, optim.Mesh) {
 	}
 	m := &optim.BoxMesh{&optim.InfMesh{StepSize: (max - min) / 10}, low, up}
 	m.SetOrigin(pos)
-	p := &optim.Point{pos, math.Inf(1)}
+	p := &optim.Point{Pos: pos, Val: math.Inf(1)}
 	return New(p, DB(db)), m
 }
+
+func TestAnisotropic(t *testing.T) {
+	// variables differ in scale by a factor of 1e4
+	fn := func(x []float64) float64 {
+		a, b := (x[0]-3e4)/1e4, x[1]-0.5
+		return a*a + b*b
+	}
+	tol := 1e-6
+
+	run := func(mesh optim.Mesh, opts ...Option) *optim.Solver {
+		start := &optim.Point{Pos: []float64{1e4, 0.1}, Val: math.Inf(1)}
+		mesh.SetOrigin(start.Pos)
+		opts = append(opts, NsuccessGrow(2))
+		solv := &optim.Solver{
+			Method:  New(start, opts...),
+			Obj:     optim.Func(fn),
+			Mesh:    mesh,
+			MaxEval: 20000,
+		}
+		for solv.Next() {
+			if solv.Best().Val < tol {
+				break
+			}
+		}
+		return solv
+	}
+
+	iso := run(&optim.InfMesh{StepSize: 1e3})
+	aniso := run(&optim.InfMesh{StepSize: 1e3, Scales: []float64{1, 1e-4}}, Anisotropic)
+	t.Logf("[INFO] isotropic: %v evals, got %v", iso.Neval(), iso.Best().Val)
+	t.Logf("[INFO] anisotropic: %v evals, got %v", aniso.Neval(), aniso.Best().Val)
+
+	if aniso.Best().Val > tol {
+		t.Errorf("anisotropic mesh failed to optimize: want < %v, got %v", tol, aniso.Best().Val)
+	}
+	if aniso.Neval() > iso.Neval() {
+		t.Errorf("anisotropic mesh used more evaluations than isotropic: %v > %v", aniso.Neval(), iso.Neval())
+	}
+}
+
+func TestAnisotropicContract(t *testing.T) {
+	fn := func(x []float64) float64 { return (x[0]-1)*(x[0]-1) + x[1]*x[1] }
+	start := &optim.Point{Pos: []float64{0, 0}, Val: fn([]float64{0, 0})}
+	mesh := &optim.InfMesh{StepSize: 1}
+	m := New(start, Anisotropic)
+
+	// the first poll moves along x, the second fails and only contracts y
+	// and the third fails again and contracts both
+	want := [][]float64{{1, 1}, {1, m.StepMult}, {m.StepMult, m.StepMult * m.StepMult}}
+	for i, w := range want {
+		if _, _, err := m.Iterate(optim.Func(fn), mesh); err != nil {
+			t.Fatal(err)
+		}
+		got := mesh.Steps()
+		if math.Abs(got[0]-w[0]) > 1e-12 || math.Abs(got[1]-w[1]) > 1e-12 {
+			t.Errorf("iter %v: want steps %v, got %v", i+1, w, got)
+		}
+	}
+}
+
+func TestEqMesh(t *testing.T) {
+	// minimize distance to (2, 0, 5) subject to x + y + z = 3
+	fn := func(x []float64) float64 {
+		a, b, c := x[0]-2, x[1], x[2]-5
+		return a*a + b*b + c*c
+	}
+	want := []float64{2.0 / 3, -4.0 / 3, 11.0 / 3}
+
+	Aeq := mat64.NewDense(1, 3, []float64{1, 1, 1})
+	Beq := mat64.NewDense(1, 1, []float64{3})
+	mesh, err := optim.NewEqMesh(&optim.InfMesh{StepSize: 1}, Aeq, Beq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := &optim.Point{Pos: []float64{1, 1, 1}, Val: math.Inf(1)}
+	mesh.SetOrigin(start.Pos)
+
+	solv := &optim.Solver{
+		Method:  New(start),
+		Obj:     optim.Func(fn),
+		Mesh:    mesh,
+		MaxEval: 5000,
+		MinStep: 1e-8,
+	}
+	solv.Run()
+
+	best := solv.Best()
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), best)
+	if sum := best.Pos[0] + best.Pos[1] + best.Pos[2]; math.Abs(sum-3) > 1e-9 {
+		t.Errorf("best point %v violates constraint: sum=%v", best.Pos, sum)
+	}
+	for i := range want {
+		if math.Abs(best.Pos[i]-want[i]) > 1e-4 {
+			t.Errorf("want %v, got %v", want, best.Pos)
+			break
+		}
+	}
+}
+
+func TestNoiseAware(t *testing.T) {
+	const noise = 0.5
+	obj := optim.Func(func(x []float64) float64 {
+		tot := 0.0
+		for _, v := range x {
+			tot += v * v
+		}
+		return tot + noise*optim.RandNorm()
+	})
+
+	// polling from the optimum, every accepted poll point is spurious
+	poll := func(opts ...Option) (nsuccess int) {
+		for i := 0; i < 50; i++ {
+			start := &optim.Point{Pos: []float64{0, 0}, Val: obj(make([]float64, 2))}
+			m := New(start, opts...)
+			mesh := &optim.InfMesh{StepSize: 0.1}
+			if _, _, err := m.Iterate(obj, mesh); err != nil {
+				t.Fatal(err)
+			} else if mesh.Step() >= 0.1 {
+				nsuccess++
+			}
+		}
+		return nsuccess
+	}
+
+	naive := poll()
+	ev := optim.NewResampleEvaler(optim.SerialEvaler{})
+	ev.Ninit, ev.Nmax = 4, 64
+	aware := poll(Evaler(ev), NoiseAware(2, noise))
+
+	t.Logf("[INFO] spurious successes of 50 polls: naive %v, noise-aware %v", naive, aware)
+	if aware > 15 || aware > naive/2 {
+		t.Errorf("want noise-aware polling to cut spurious successes, got %v vs %v", aware, naive)
+	}
+}
@@ -0,0 +1,291 @@
+package optim
+
+import (
+	"crypto/sha1"
+	"math"
+	"sync"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// Adapter is implemented by objectives with internal state (e.g. penalty
+// weights or Lagrange multipliers) that should be updated between solver
+// iterations.  Solver calls Adapt after every iteration.  Note that adapting
+// changes the objective function, so objective values computed before and
+// after a call to Adapt are not directly comparable - Solver re-scores its
+// best point and the method's incumbent (see Rescorer) whenever Adapt
+// reports a change.
+type Adapter interface {
+	// Adapt updates the objective's state given the best point found by
+	// the most recent iteration and reports whether the objective changed.
+	Adapt(best *Point) (changed bool)
+}
+
+// Rescorer is implemented by methods that keep objective values between
+// iterations (e.g. an incumbent point).  Solver calls Rescore whenever
+// Adapter.Adapt changes the objective so that the method doesn't compare new
+// objective values against ones computed with the previous objective.  For methods
+// that don't implement Rescorer, Solver only re-evaluates its own best
+// point.
+type Rescorer interface {
+	// Rescore re-evaluates the method's stored points using obj and returns
+	// the best of them along with the number of evaluations.
+	Rescore(obj Objectiver) (best *Point, n int, err error)
+}
+
+// ObjectiveLinConstr is a ConstrObjectiver that combines an objective with
+// the linear constraints low <= Ax <= up.  The constraint values are the
+// stacked Ax - up and low - Ax.
+type ObjectiveLinConstr struct {
+	Obj     Objectiver
+	A       *mat64.Dense
+	Low, Up *mat64.Dense
+	a       *mat64.Dense // stacked version of A
+	b       *mat64.Dense // Low and Up stacked
+}
+
+func (o *ObjectiveLinConstr) init() {
+	if o.a != nil {
+		// already initialized
+		return
+	}
+	o.a, o.b, _ = StackConstr(o.Low, o.A, o.Up)
+}
+
+func (o *ObjectiveLinConstr) Objective(v []float64) (float64, error) { return o.Obj.Objective(v) }
+
+func (o *ObjectiveLinConstr) Constraints(v []float64) ([]float64, error) {
+	o.init()
+	ax := &mat64.Dense{}
+	ax.Mul(o.a, mat64.NewDense(len(v), 1, v))
+
+	m, _ := ax.Dims()
+	g := make([]float64, m)
+	for i := range g {
+		g[i] = ax.At(i, 0) - o.b.At(i, 0)
+	}
+	return g, nil
+}
+
+// constrval evaluates the constraints and objective for v.  The objective is
+// not evaluated if skipinfeas is true and v is infeasible.
+func constrval(o ConstrObjectiver, v []float64, skipinfeas bool) (val float64, g []float64, err error) {
+	g, err = o.Constraints(v)
+	if err != nil {
+		return math.Inf(1), g, err
+	} else if skipinfeas && Violation(g) > 0 {
+		return math.Inf(1), g, nil
+	}
+	val, err = o.Objective(v)
+	return val, g, err
+}
+
+// DeathPenalty returns +infinity for infeasible points without evaluating
+// the underlying objective.
+type DeathPenalty struct {
+	Obj ConstrObjectiver
+}
+
+func (o DeathPenalty) Objective(v []float64) (float64, error) {
+	val, _, err := constrval(o.Obj, v, true)
+	return val, err
+}
+
+// QuadPenalty adds a static quadratic penalty to the objective:
+//
+//     f(x) + Weight * sum(max(0, g_i(x))^2)
+//
+// Unlike ObjectivePenalty, the penalty is additive and so is meaningful for
+// objectives with negative or zero values.
+type QuadPenalty struct {
+	Obj    ConstrObjectiver
+	Weight float64
+}
+
+func (o QuadPenalty) Objective(v []float64) (float64, error) {
+	val, g, err := constrval(o.Obj, v, false)
+	return val + o.Weight*Violation(g), err
+}
+
+// Default parameters for AdaptivePenalty.
+const (
+	DefaultPenaltyWeight = 1
+	DefaultTargetRatio   = 0.5
+	DefaultPenaltyFactor = 2
+)
+
+// AdaptivePenalty is a quadratic penalty (see QuadPenalty) whose weight is
+// adapted between solver iterations based on the fraction of feasible points
+// evaluated during the iteration.  If the fraction is below TargetRatio, the
+// weight is multiplied by Factor; otherwise the weight is divided by Factor.
+// The weight never drops below MinWeight.
+type AdaptivePenalty struct {
+	Obj ConstrObjectiver
+	// Weight is the current penalty weight.  If zero,
+	// DefaultPenaltyWeight is used.
+	Weight float64
+	// MinWeight is the smallest allowed penalty weight.
+	MinWeight float64
+	// TargetRatio is the desired fraction of feasible evaluations.  If zero,
+	// DefaultTargetRatio is used.
+	TargetRatio float64
+	// Factor is the multiplier used to adjust Weight.  If zero,
+	// DefaultPenaltyFactor is used.
+	Factor float64
+	mu     sync.Mutex
+	nfeas  int
+	neval  int
+}
+
+func (o *AdaptivePenalty) Objective(v []float64) (float64, error) {
+	val, g, err := constrval(o.Obj, v, false)
+	h := Violation(g)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.Weight == 0 {
+		o.Weight = DefaultPenaltyWeight
+	}
+	o.neval++
+	if h == 0 {
+		o.nfeas++
+	}
+	return val + o.Weight*h, err
+}
+
+// Adapt updates the penalty weight using the feasibility ratio of the
+// evaluations since the last call to Adapt.
+func (o *AdaptivePenalty) Adapt(best *Point) (changed bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.neval == 0 {
+		return false
+	}
+
+	target, factor := o.TargetRatio, o.Factor
+	if target == 0 {
+		target = DefaultTargetRatio
+	}
+	if factor == 0 {
+		factor = DefaultPenaltyFactor
+	}
+
+	prev := o.Weight
+	if ratio := float64(o.nfeas) / float64(o.neval); ratio < target {
+		o.Weight *= factor
+	} else {
+		o.Weight = math.Max(o.MinWeight, o.Weight/factor)
+	}
+	o.nfeas, o.neval = 0, 0
+	return o.Weight != prev
+}
+
+// Default parameters for AugLagrangian.  Subproblems are only solved
+// approximately (until an iteration fails to improve), so Mu grows slowly to
+// keep the penalized problem well conditioned for derivative-free methods.
+const (
+	DefaultAugMu     = 10
+	DefaultAugGrowth = 2
+	DefaultAugTau    = 0.25
+)
+
+// AugLagrangian is an augmented Lagrangian (Powell-Hestenes-Rockafellar)
+// penalty for inequality constraints:
+//
+//     f(x) + 1/(2*Mu) * sum(max(0, lambda_i + Mu*g_i(x))^2 - lambda_i^2)
+//
+// When an iteration fails to improve on the previous iteration's best point
+// (i.e. the penalized subproblem is approximately solved), the multipliers
+// are updated using the constraint values at the best point:
+//
+//     lambda_i = max(0, lambda_i + Mu*g_i(best))
+//
+// and Mu is multiplied by Growth if the constraint violation at the best
+// point did not fall below Tau times its value at the previous update.
+// Adapt evaluates the constraints (but not the objective) at the best point.
+type AugLagrangian struct {
+	Obj ConstrObjectiver
+	// Mu is the penalty parameter.  If zero, DefaultAugMu is used.
+	Mu float64
+	// Growth is the factor Mu is multiplied by when the violation
+	// decreases insufficiently.  If zero, DefaultAugGrowth is used.
+	Growth float64
+	// Tau is the required fractional violation decrease per iteration.  If
+	// zero, DefaultAugTau is used.
+	Tau float64
+	// Lambda holds the Lagrange multiplier estimates for each constraint.
+	// It is initialized to zeros if nil.
+	Lambda   []float64
+	mu       sync.RWMutex
+	prevviol float64
+	prevbest [sha1.Size]byte
+}
+
+func (o *AugLagrangian) Objective(v []float64) (float64, error) {
+	val, g, err := constrval(o.Obj, v, false)
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	mu := o.Mu
+	if mu == 0 {
+		mu = DefaultAugMu
+	}
+
+	pen := 0.0
+	for i, gi := range g {
+		lambda := 0.0
+		if i < len(o.Lambda) {
+			lambda = o.Lambda[i]
+		}
+		shifted := math.Max(0, lambda+mu*gi)
+		pen += shifted*shifted - lambda*lambda
+	}
+	return val + pen/(2*mu), err
+}
+
+// Adapt updates the Lagrange multipliers and penalty parameter using the
+// constraint values at best.
+func (o *AugLagrangian) Adapt(best *Point) (changed bool) {
+	if best == nil || best.Pos == nil {
+		return false
+	} else if h := best.Hash(); h != o.prevbest {
+		// subproblem is still making progress
+		o.prevbest = h
+		return false
+	}
+
+	g, err := o.Obj.Constraints(best.Pos)
+	if err != nil {
+		return false
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.Mu == 0 {
+		o.Mu = DefaultAugMu
+	}
+	growth, tau := o.Growth, o.Tau
+	if growth == 0 {
+		growth = DefaultAugGrowth
+	}
+	if tau == 0 {
+		tau = DefaultAugTau
+	}
+	if o.Lambda == nil {
+		o.Lambda = make([]float64, len(g))
+	}
+
+	for i, gi := range g {
+		lambda := math.Max(0, o.Lambda[i]+o.Mu*gi)
+		changed = changed || lambda != o.Lambda[i]
+		o.Lambda[i] = lambda
+	}
+
+	viol := Violation(g)
+	if o.prevviol != 0 && viol > tau*o.prevviol {
+		o.Mu *= growth
+		changed = true
+	}
+	o.prevviol = viol
+	return changed
+}
@@ -0,0 +1,149 @@
+package optim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// negobj is minimize -(x+y) subject to x^2 + y^2 <= 1 which has a negative
+// optimum of -sqrt(2) at (1/sqrt(2), 1/sqrt(2)).
+var negobj = ConstrFunc{
+	Obj:    func(x []float64) float64 { return -x[0] - x[1] },
+	Constr: func(x []float64) []float64 { return []float64{x[0]*x[0] + x[1]*x[1] - 1} },
+}
+
+func TestPenaltyValues(t *testing.T) {
+	feas := []float64{0.5, 0.5}
+	infeas := []float64{2, 0}
+
+	if v, _ := (DeathPenalty{negobj}).Objective(infeas); !math.IsInf(v, 1) {
+		t.Errorf("death penalty: want +Inf for infeasible point, got %v", v)
+	}
+	if v, _ := (DeathPenalty{negobj}).Objective(feas); v != -1 {
+		t.Errorf("death penalty: want -1 for feasible point, got %v", v)
+	}
+
+	if v, _ := (QuadPenalty{negobj, 10}).Objective(infeas); v != -2+10*9 {
+		t.Errorf("quadratic penalty: want %v, got %v", -2+10*9, v)
+	}
+
+	lag := &AugLagrangian{Obj: negobj, Mu: 2, Lambda: []float64{1}}
+	want := -2 + (math.Pow(1+2*3, 2)-1)/4
+	if v, _ := lag.Objective(infeas); math.Abs(v-want) > 1e-12 {
+		t.Errorf("augmented lagrangian: want %v, got %v", want, v)
+	}
+}
+
+func TestObjectiveLinConstr(t *testing.T) {
+	o := &ObjectiveLinConstr{
+		Obj: Func(func(x []float64) float64 { return 0 }),
+		A:   mat64.NewDense(1, 2, []float64{1, 2}),
+		Low: mat64.NewDense(1, 1, []float64{-1}),
+		Up:  mat64.NewDense(1, 1, []float64{3}),
+	}
+	g, _ := o.Constraints([]float64{2, 1})
+	if want := []float64{1, -5}; g[0] != want[0] || g[1] != want[1] {
+		t.Errorf("want %v, got %v", want, g)
+	}
+}
+
+func TestAdaptivePenalty(t *testing.T) {
+	p := &AdaptivePenalty{Obj: negobj}
+	p.Objective([]float64{2, 0})
+	p.Objective([]float64{3, 0})
+	p.Objective([]float64{0, 0})
+	if !p.Adapt(nil) || p.Weight != 2 {
+		t.Errorf("weight after mostly infeasible iteration: want 2, got %v", p.Weight)
+	}
+
+	p.Objective([]float64{0, 0})
+	if !p.Adapt(nil) || p.Weight != 1 {
+		t.Errorf("weight after feasible iteration: want 1, got %v", p.Weight)
+	}
+
+	if p.Adapt(nil) {
+		t.Errorf("want no change without evaluations since the last call")
+	}
+}
+
+// compassMethod is a minimal compass search used to exercise penalty
+// objectives through Solver without depending on other packages.  It only
+// re-evaluates its incumbent when Solver asks it to.
+type compassMethod struct {
+	curr     *Point
+	step     float64
+	nrescore int
+}
+
+func (m *compassMethod) AddPoint(p *Point) {}
+
+func (m *compassMethod) Rescore(obj Objectiver) (*Point, int, error) {
+	m.nrescore++
+	val, err := obj.Objective(m.curr.Pos)
+	m.curr = &Point{Pos: m.curr.Pos, Val: val}
+	return m.curr, 1, err
+}
+
+func (m *compassMethod) Iterate(obj Objectiver, mesh Mesh) (*Point, int, error) {
+	n := 0
+	if math.IsInf(m.curr.Val, 1) {
+		m.curr.Val, _ = obj.Objective(m.curr.Pos)
+		n++
+	}
+	for i := range m.curr.Pos {
+		for _, sign := range []float64{1, -1} {
+			p := m.curr.Clone()
+			p.Pos[i] += sign * m.step
+			p.Val, _ = obj.Objective(p.Pos)
+			n++
+			if p.Val < m.curr.Val {
+				m.curr = p
+				return p.Clone(), n, nil
+			}
+		}
+	}
+	m.step /= 2
+	mesh.SetStep(m.step)
+	return m.curr.Clone(), n, nil
+}
+
+func TestAugLagrangian(t *testing.T) {
+	lag := &AugLagrangian{Obj: negobj}
+	m := &compassMethod{curr: &Point{Pos: []float64{0, 0}, Val: math.Inf(1)}, step: 0.5}
+	s := &Solver{Method: m, Obj: lag, Mesh: &InfMesh{StepSize: 0.5}, MaxIter: 5000, MinStep: 1e-8}
+	s.Run()
+
+	x := m.curr.Pos
+	t.Logf("[INFO] %v evals, %v of %v iterations rescored: got %v, lambda=%v", s.Neval(), m.nrescore, s.Niter(), x, lag.Lambda)
+	if got, want := negobj.Obj(x), -math.Sqrt2; math.Abs(got-want) > 1e-3 {
+		t.Errorf("objective: want %v, got %v", want, got)
+	}
+	if g := negobj.Constr(x); Violation(g) > 1e-6 {
+		t.Errorf("solution %v is infeasible: g = %v", x, g)
+	}
+	if want := 1 / math.Sqrt2; math.Abs(lag.Lambda[0]-want) > 1e-2 {
+		t.Errorf("multiplier: want %v, got %v", want, lag.Lambda[0])
+	}
+	if m.nrescore == 0 || m.nrescore >= s.Niter()/2 {
+		t.Errorf("want rescoring only after multiplier updates, got %v of %v iterations", m.nrescore, s.Niter())
+	}
+}
+
+func TestAugLagrangianAdapt(t *testing.T) {
+	lag := &AugLagrangian{Obj: negobj}
+	infeas := &Point{Pos: []float64{2, 0}}
+	if lag.Adapt(infeas) {
+		t.Errorf("want no change while the subproblem is making progress")
+	}
+	if !lag.Adapt(infeas) || lag.Lambda[0] != DefaultAugMu*3 {
+		t.Errorf("want multiplier %v once the subproblem stalls, got %v", DefaultAugMu*3, lag.Lambda)
+	}
+
+	lag = &AugLagrangian{Obj: negobj}
+	feas := &Point{Pos: []float64{0, 0}}
+	if lag.Adapt(feas) || lag.Adapt(feas) {
+		t.Errorf("want no change at an interior point with zero multipliers, got %v", lag.Lambda)
+	}
+}
@@ -18,7 +18,7 @@ func RandPop(n int, low, up []float64) []*Point {
 		for j := range pos {
 			pos[j] = low[j] + RandFloat()*(up[j]-low[j])
 		}
-		points[i] = &Point{pos, math.Inf(1)}
+		points[i] = &Point{Pos: pos, Val: math.Inf(1)}
 	}
 	return points
 }
@@ -0,0 +1,293 @@
+// Package sa provides a simulated annealing optimizer with an optional
+// parallel tempering (replica exchange) mode as described in:
+//
+//     Kirkpatrick, Scott, C. Daniel Gelatt, and Mario P. Vecchi.
+//     "Optimization by simulated annealing." Science 220.4598 (1983):
+//     671-680.
+//
+//     Earl, David J., and Michael W. Deem. "Parallel tempering: Theory,
+//     applications, and new perspectives." Physical Chemistry Chemical
+//     Physics 7.23 (2005): 3910-3916.
+//
+// Each call to Iterate proposes one move per chain and evaluates all
+// proposals as a single batch through the method's Evaler.
+package sa
+
+import (
+	"database/sql"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+const (
+	// TblChains is the name of the sql database table that contains the
+	// temperature and current position of each chain for each iteration.
+	TblChains = "sachains"
+	// TblBest is the name of the sql database table that contains the best
+	// position found over all chains at each iteration.
+	TblBest = "sabest"
+)
+
+const (
+	DefaultTemp   = 1
+	DefaultSigma  = 1
+	DefaultAlpha  = 0.995
+	DefaultTarget = 0.3
+	DefaultFactor = 0.95
+	// AcceptMemory is the weight given to past moves in each chain's
+	// exponentially weighted acceptance rate.
+	AcceptMemory = 0.9
+)
+
+// Chain is a single Markov chain sampling the objective at temperature Temp.
+type Chain struct {
+	Cur *optim.Point
+	// T0 is the chain's starting temperature.
+	T0   float64
+	Temp float64
+	// K is the number of steps taken since the chain was started or last
+	// reheated.
+	K int
+	// Accept is the chain's recent acceptance rate.
+	Accept float64
+}
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Cooling sets the cooling schedule.
+func Cooling(s Schedule) Option { return func(m *Method) { m.Schedule = s } }
+
+// Neighborhood sets the generator for candidate moves.
+func Neighborhood(n Neighbor) Option { return func(m *Method) { m.Neighbor = n } }
+
+// Temp sets the starting temperature of the coldest chain.
+func Temp(t0 float64) Option { return func(m *Method) { m.T0 = t0 } }
+
+// Reheat resets all chains to their starting temperatures after n iterations
+// without improvement of the best point.
+func Reheat(n int) Option { return func(m *Method) { m.ReheatIters = n } }
+
+// Tempering enables parallel tempering with nchains chains.  Chain i starts
+// at temperature T0*ratio^i.  After each step, neighboring chains attempt to
+// swap positions.
+func Tempering(nchains int, ratio float64) Option {
+	return func(m *Method) {
+		m.nchains = nchains
+		m.Ratio = ratio
+	}
+}
+
+type Method struct {
+	// Chains holds the annealing chains sorted from coldest to hottest.
+	Chains   []*Chain
+	Schedule Schedule
+	Neighbor Neighbor
+	T0       float64
+	// Ratio is the temperature ratio between neighboring chains.
+	Ratio float64
+	// ReheatIters is the number of iterations without improvement after which
+	// chains are reheated.  Zero disables reheating.
+	ReheatIters int
+	Nreheat     int
+	// Nswap counts the number of accepted chain swaps.
+	Nswap     int
+	Db        *sql.DB
+	ev        optim.Evaler
+	nchains   int
+	best      *optim.Point
+	iter      int
+	noimprove int
+	started   bool
+}
+
+// New creates a simulated annealing method starting at start.  If start's
+// value is +infinity, it is evaluated on the first iteration.  By default a
+// single chain with exponential cooling and MeshNeighbor moves is used.
+func New(start *optim.Point, opts ...Option) *Method {
+	m := &Method{
+		Schedule: Exponential{DefaultAlpha},
+		Neighbor: MeshNeighbor{DefaultSigma},
+		T0:       DefaultTemp,
+		Ratio:    1,
+		ev:       optim.SerialEvaler{},
+		nchains:  1,
+		best:     start.Clone(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	t0 := m.T0
+	for i := 0; i < m.nchains; i++ {
+		m.Chains = append(m.Chains, &Chain{Cur: start.Clone(), T0: t0, Temp: t0, Accept: 1})
+		t0 *= m.Ratio
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint moves the coldest chain to p if p is better than the best point
+// found so far.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val >= m.best.Val {
+		return
+	}
+	m.best = p.Clone()
+	m.Chains[0].Cur = p.Clone()
+}
+
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	if !m.started && math.IsInf(m.best.Val, 1) {
+		m.started = true
+		start := &optim.Point{Pos: mesh.Nearest(m.best.Pos), Val: math.Inf(1)}
+		_, n, err = m.ev.Eval(obj, start)
+		m.best = start
+		for _, c := range m.Chains {
+			c.Cur = start.Clone()
+		}
+		return m.best, n, err
+	}
+	m.started = true
+
+	proposals := make([]*optim.Point, len(m.Chains))
+	for i, c := range m.Chains {
+		pos := m.Neighbor.Neighbor(c.Cur.Pos, c.Temp, mesh)
+		proposals[i] = &optim.Point{Pos: pos, Val: math.Inf(1)}
+	}
+	_, n, err = m.ev.Eval(obj, proposals...)
+
+	improved := false
+	for i, c := range m.Chains {
+		p := proposals[i]
+		accepted := 0.0
+		// chains at infeasible (infinite valued) points wander freely
+		if math.IsInf(c.Cur.Val, 1) || metropolis(p.Val-c.Cur.Val, c.Temp) {
+			c.Cur = p
+			accepted = 1
+		}
+		c.Accept = AcceptMemory*c.Accept + (1-AcceptMemory)*accepted
+		if p.Val < m.best.Val {
+			m.best = p.Clone()
+			improved = true
+		}
+	}
+
+	m.swap()
+
+	for _, c := range m.Chains {
+		c.K++
+		c.Temp = m.Schedule.Temp(c.K, c.T0, c.Temp, c.Accept)
+	}
+
+	if improved {
+		m.noimprove = 0
+	} else {
+		m.noimprove++
+	}
+	if m.ReheatIters > 0 && m.noimprove >= m.ReheatIters {
+		m.noimprove = 0
+		m.Nreheat++
+		for _, c := range m.Chains {
+			c.Temp = c.T0
+			c.K = 0
+		}
+	}
+
+	return m.best, n, err
+}
+
+// swap attempts position exchanges between neighboring chains.
+func (m *Method) swap() {
+	for i := 0; i+1 < len(m.Chains); i++ {
+		a, b := m.Chains[i], m.Chains[i+1]
+		delta := (a.Cur.Val - b.Cur.Val) * (1/a.Temp - 1/b.Temp)
+		if delta >= 0 || optim.RandFloat() < math.Exp(delta) {
+			a.Cur, b.Cur = b.Cur, a.Cur
+			m.Nswap++
+		}
+	}
+}
+
+// metropolis returns true if a move changing the objective by delta is
+// accepted at temperature temp.
+func metropolis(delta, temp float64) bool {
+	if delta <= 0 {
+		return true
+	} else if math.IsInf(delta, 1) || math.IsNaN(delta) || temp <= 0 {
+		return false
+	}
+	return optim.RandFloat() < math.Exp(-delta/temp)
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblChains + " (chain INTEGER, iter INTEGER, temp REAL, accept REAL, val REAL, posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+
+	s = "CREATE TABLE IF NOT EXISTS " + TblBest + " (iter INTEGER, val REAL, posid BLOB);"
+	_, err = m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s0, err := tx.Prepare("INSERT INTO " + TblChains + " (chain,iter,temp,accept,val,posid) VALUES (?,?,?,?,?,?);")
+	if checkdberr(err) {
+		return
+	}
+	pts := []*optim.Point{m.best}
+	for i, c := range m.Chains {
+		_, err := s0.Exec(i, m.iter, c.Temp, c.Accept, c.Cur.Val, c.Cur.HashSlice())
+		if checkdberr(err) {
+			return
+		}
+		pts = append(pts, c.Cur)
+	}
+
+	s1 := "INSERT INTO " + TblBest + " (iter,val,posid) VALUES (?,?,?);"
+	_, err = tx.Exec(s1, m.iter, m.best.Val, m.best.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, pts...)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("sa: db write failed -", err)
+		return true
+	}
+	return false
+}
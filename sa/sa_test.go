@@ -0,0 +1,212 @@
+package sa
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+	"github.com/baaaaam/optim/bench"
+)
+
+func TestSchedules(t *testing.T) {
+	fn := bench.Rastrigin{NDim: 2}
+	cases := []struct {
+		name  string
+		t0    float64
+		sched Schedule
+	}{
+		{"exponential", 10, Exponential{DefaultAlpha}},
+		{"logarithmic", 1, Logarithmic{}},
+		{"adaptive", 10, Adaptive{DefaultTarget, DefaultFactor}},
+	}
+
+	for _, c := range cases {
+		start := &optim.Point{Pos: []float64{3.2, -2.7}, Val: math.Inf(1)}
+		solv := &optim.Solver{
+			Method:  New(start, Temp(c.t0), Cooling(c.sched), Neighborhood(MeshNeighbor{0.3})),
+			Obj:     optim.Func(fn.Eval),
+			Mesh:    &optim.InfMesh{},
+			MaxEval: 10000,
+		}
+		solv.Run()
+
+		// the nearest local minima have values close to 1
+		t.Logf("[INFO] %v: %v evals: got %v", c.name, solv.Neval(), solv.Best())
+		if solv.Best().Val > 0.5 {
+			t.Errorf("%v: want < 0.5, got %v", c.name, solv.Best().Val)
+		}
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	s := Adaptive{Target: 0.5, Factor: 0.9}
+	if got := s.Temp(1, 10, 5, 0.8); got != 4.5 {
+		t.Errorf("high acceptance: want 4.5, got %v", got)
+	}
+	if got := s.Temp(1, 10, 9.5, 0.1); got != 10 {
+		t.Errorf("low acceptance: want temperature capped at 10, got %v", got)
+	}
+}
+
+func TestIntMesh(t *testing.T) {
+	noninteger := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for _, v := range x {
+			if v != math.Floor(v) {
+				noninteger++
+			}
+		}
+		return (x[0]-7)*(x[0]-7) + (x[1]+4)*(x[1]+4)
+	})
+
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, Neighborhood(MeshNeighbor{0.3})),
+		Obj:     obj,
+		Mesh:    &optim.IntMesh{Mesh: &optim.InfMesh{}},
+		MaxIter: 1000,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if noninteger > 0 {
+		t.Errorf("%v non-integer coordinates evaluated", noninteger)
+	}
+	if solv.Best().Val != 0 {
+		t.Errorf("want [7 -4], got %v", solv.Best().Pos)
+	}
+}
+
+func TestBoxMesh(t *testing.T) {
+	low, up := []float64{-1, -1}, []float64{1, 1}
+	outside := 0
+	obj := optim.Func(func(x []float64) float64 {
+		for i := range x {
+			if x[i] < low[i] || x[i] > up[i] {
+				outside++
+			}
+		}
+		return (x[0]-3)*(x[0]-3) + (x[1]-3)*(x[1]-3)
+	})
+
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start),
+		Obj:     obj,
+		Mesh:    &optim.BoxMesh{Mesh: &optim.InfMesh{StepSize: 0.25}, Lower: low, Upper: up},
+		MaxIter: 500,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if outside > 0 {
+		t.Errorf("%v points evaluated outside bounds", outside)
+	}
+	if solv.Best().Pos[0] != 1 || solv.Best().Pos[1] != 1 {
+		t.Errorf("want [1 1], got %v", solv.Best().Pos)
+	}
+}
+
+func TestInfeasibleStart(t *testing.T) {
+	// points with x < 1 are infeasible
+	obj := optim.Func(func(x []float64) float64 {
+		if x[0] < 1 {
+			return math.Inf(1)
+		}
+		return x[0]*x[0] + x[1]*x[1]
+	})
+
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start),
+		Obj:     obj,
+		Mesh:    &optim.InfMesh{StepSize: 0.5},
+		MaxEval: 1000,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if math.IsInf(solv.Best().Val, 1) {
+		t.Errorf("chains never left the infeasible start")
+	}
+}
+
+func TestReheat(t *testing.T) {
+	start := &optim.Point{Pos: []float64{0, 0}, Val: math.Inf(1)}
+	m := New(start, Reheat(50), Temp(0.1), Cooling(Exponential{0.9}))
+	obj := optim.Func(func(x []float64) float64 { return x[0]*x[0] + x[1]*x[1] })
+	mesh := &optim.InfMesh{}
+	for i := 0; i < 300; i++ {
+		if _, _, err := m.Iterate(obj, mesh); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m.Nreheat == 0 {
+		t.Errorf("chains never reheated")
+	}
+	if m.Chains[0].Temp < 0.1*math.Pow(0.9, 51) {
+		t.Errorf("temperature %v kept cooling despite reheating", m.Chains[0].Temp)
+	}
+}
+
+func TestTempering(t *testing.T) {
+	fn := bench.Rastrigin{NDim: 3}
+	nchains := 6
+	start := &optim.Point{Pos: []float64{4, -4, 4}, Val: math.Inf(1)}
+	m := New(start, Tempering(nchains, 3), Temp(0.1), Cooling(Exponential{1}),
+		Neighborhood(MeshNeighbor{0.5}), Evaler(optim.ParallelEvaler{}))
+	solv := &optim.Solver{
+		Method:  m,
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 1000,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals, %v swaps: got %v", solv.Neval(), m.Nswap, solv.Best())
+	if want := 1 + nchains*(solv.Niter()-1); solv.Neval() != want {
+		t.Errorf("want %v evals, got %v", want, solv.Neval())
+	}
+	if m.Nswap == 0 {
+		t.Errorf("no chain swaps occurred")
+	}
+	for i := 1; i < nchains; i++ {
+		if m.Chains[i].Temp <= m.Chains[i-1].Temp {
+			t.Errorf("chain %v not hotter than chain %v", i, i-1)
+		}
+	}
+	if solv.Best().Val > 0.1 {
+		t.Errorf("want < 0.1, got %v", solv.Best().Val)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fn := bench.Rosenbrock{NDim: 2}
+	start := &optim.Point{Pos: []float64{-1.2, 1}, Val: math.Inf(1)}
+	solv := &optim.Solver{
+		Method:  New(start, Tempering(3, 2), DB(db)),
+		Obj:     optim.Func(fn.Eval),
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	for _, tbl := range []string{TblChains, TblBest} {
+		var count int
+		err = db.QueryRow("SELECT COUNT(*) FROM " + tbl).Scan(&count)
+		if err != nil {
+			t.Errorf("[ERROR] %v table query failed: %v", tbl, err)
+		} else if count == 0 {
+			t.Errorf("[ERROR] %v table has no rows", tbl)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package sa
+
+import (
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// Schedule determines how a chain's temperature changes over time.
+type Schedule interface {
+	// Temp returns the new temperature for a chain that has performed k
+	// steps since it was started (or reheated) at temperature t0.  t is the
+	// chain's current temperature and accept is its recent acceptance rate.
+	Temp(k int, t0, t, accept float64) float64
+}
+
+// Exponential cools geometrically: T_k = T_0 * Alpha^k.
+type Exponential struct {
+	Alpha float64
+}
+
+func (s Exponential) Temp(k int, t0, t, accept float64) float64 {
+	return t0 * math.Pow(s.Alpha, float64(k))
+}
+
+// Logarithmic cools with T_k = T_0 * ln(2) / ln(k+2).  This is the classic
+// schedule for which convergence to the global optimum can be proven, but it
+// cools very slowly.
+type Logarithmic struct{}
+
+func (s Logarithmic) Temp(k int, t0, t, accept float64) float64 {
+	return t0 * math.Ln2 / math.Log(float64(k)+2)
+}
+
+// Adaptive cools by multiplying the temperature by Factor while the
+// acceptance rate is above Target and heats by dividing by Factor while it is
+// below.  The temperature never exceeds the chain's starting temperature.
+type Adaptive struct {
+	Target float64
+	Factor float64
+}
+
+func (s Adaptive) Temp(k int, t0, t, accept float64) float64 {
+	if accept > s.Target {
+		return t * s.Factor
+	}
+	return math.Min(t0, t/s.Factor)
+}
+
+// Neighbor generates candidate moves.
+type Neighbor interface {
+	// Neighbor returns a new point near x.  The returned point must be
+	// on the mesh.
+	Neighbor(x []float64, temp float64, mesh optim.Mesh) []float64
+}
+
+// MeshNeighbor perturbs a random subset of coordinates (each with probability
+// 1/ndim but at least one) by normally distributed amounts with standard
+// deviation Sigma.  For coordinates with a nonzero mesh step, moves are whole
+// multiples of at least one step.  If the projection onto the mesh results in
+// no movement (e.g. with IntMesh or at a BoxMesh bound), the perturbation is
+// retried with a doubled Sigma.
+type MeshNeighbor struct {
+	Sigma float64
+}
+
+func (n MeshNeighbor) Neighbor(x []float64, temp float64, mesh optim.Mesh) []float64 {
+	ndim := len(x)
+	steps := optim.Steps(mesh, ndim)
+	sigma := n.Sigma
+
+	var y []float64
+	for try := 0; try < 20; try++ {
+		y = append([]float64{}, x...)
+		moved := false
+		for !moved {
+			for i := range y {
+				if optim.Rand.Intn(ndim) != 0 {
+					continue
+				}
+				moved = true
+				d := sigma * optim.RandNorm()
+				if steps[i] > 0 {
+					nstep := math.Max(1, math.Floor(math.Abs(d)/steps[i]+0.5))
+					d = math.Copysign(nstep*steps[i], d)
+				}
+				y[i] += d
+			}
+		}
+
+		y = mesh.Nearest(y)
+		for i := range y {
+			if y[i] != x[i] {
+				return y
+			}
+		}
+		sigma *= 2
+	}
+	return y
+}
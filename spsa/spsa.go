@@ -0,0 +1,324 @@
+// Package spsa provides a simultaneous perturbation stochastic approximation
+// (SPSA) method for noisy objectives as described in:
+//
+//     Spall, James C. "Implementation of the simultaneous perturbation
+//     algorithm for stochastic optimization." IEEE Transactions on Aerospace
+//     and Electronic Systems 34.3 (1998): 817-823.
+//
+// Each iteration estimates the gradient from just two evaluations at
+// theta +/- c_k*delta for a random perturbation delta with independent +/-1
+// components, and takes the step -a_k*g.  The gain sequences
+// a_k = a/(k+1+A)^alpha and c_k = c/(k+1)^gamma decay slowly enough that the
+// noise averages out over many iterations - unlike finite differences or
+// pattern search comparisons that are easily fooled by a single noisy
+// evaluation.  Several gradient estimates can be averaged per iteration and
+// steps can be blocked if they are too large or appear to make the objective
+// worse.  All evaluations of an iteration are performed as a single batch
+// through the method's optim.Evaler.
+package spsa
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"log"
+	"math"
+
+	"github.com/baaaaam/optim"
+)
+
+// TblIter is the name of the sql database table that contains the iterate,
+// its estimated value, the gain sequence values and whether the step was
+// blocked for each iteration.
+const TblIter = "spsaiter"
+
+// Spall's recommended (asymptotically non-optimal but practically robust)
+// gain sequence exponents.
+const (
+	DefaultAlpha = 0.602
+	DefaultGamma = 0.101
+)
+
+type Option func(*Method)
+
+func Evaler(e optim.Evaler) Option { return func(m *Method) { m.ev = e } }
+
+func DB(db *sql.DB) Option { return func(m *Method) { m.Db = db } }
+
+// Gains sets the step gain a and the stability constant A.  If a is zero, it
+// is calibrated from the first gradient estimate so that the first step
+// moves InitStep in the dimension with the largest gradient.  A is typically
+// about 10% of the expected number of iterations.
+func Gains(a, A float64) Option { return func(m *Method) { m.A, m.BigA = a, A } }
+
+// Exponents sets the decay exponents alpha and gamma of the gain sequences.
+func Exponents(alpha, gamma float64) Option {
+	return func(m *Method) { m.Alpha, m.Gamma = alpha, gamma }
+}
+
+// InitStep sets the magnitude of the first step used to calibrate a.  It
+// defaults to the perturbation size c.
+func InitStep(step float64) Option { return func(m *Method) { m.InitStep = step } }
+
+// Average sets the number of independent gradient estimates averaged each
+// iteration.
+func Average(n int) Option { return func(m *Method) { m.Navg = n } }
+
+// Block evaluates each candidate iterate and rejects the step if its value
+// exceeds the current iterate's estimated value by more than tol.  tol should
+// be a few times the noise standard deviation.
+func Block(tol float64) Option { return func(m *Method) { m.BlockTol = tol } }
+
+// MaxStep rejects steps that move any variable further than max.
+func MaxStep(max float64) Option { return func(m *Method) { m.MaxStep = max } }
+
+// Bounds constrains the iterates to the box defined by low and up.  If no
+// bounds are given and the solver's mesh is an optim.BoxMesh, the mesh's
+// bounds are used.
+func Bounds(low, up []float64) Option {
+	return func(m *Method) {
+		m.Low = append([]float64{}, low...)
+		m.Up = append([]float64{}, up...)
+	}
+}
+
+type Method struct {
+	// Theta is the current iterate.  It is kept off the mesh so that small
+	// steps accumulate - only evaluated points are projected onto the mesh.
+	Theta []float64
+	// A, C, BigA, Alpha and Gamma define the gain sequences
+	// a_k = A/(k+1+BigA)^Alpha and c_k = C/(k+1)^Gamma.
+	A, C, BigA   float64
+	Alpha, Gamma float64
+	InitStep     float64
+	Navg         int
+	BlockTol     float64
+	MaxStep      float64
+	Low, Up      []float64
+	// Estimate is the iterate before the last step with its value estimated
+	// by the mean of the perturbed evaluations - or, with the Block option,
+	// the accepted iterate and its own evaluation.  Estimated values are
+	// never returned by Iterate since no point was evaluated there.
+	Estimate *optim.Point
+	// Nblocked counts rejected steps.
+	Nblocked int
+	Db       *sql.DB
+
+	ev      optim.Evaler
+	k       int
+	ak, ck  float64
+	best    *optim.Point
+	blocked bool
+	iter    int
+}
+
+// New creates an SPSA method starting at start with perturbation size c.  c
+// should be roughly the noise standard deviation of the objective divided by
+// the magnitude of its gradient - i.e. large enough that the difference of
+// two evaluations is not dominated by noise.
+func New(start *optim.Point, c float64, opts ...Option) *Method {
+	m := &Method{
+		Theta:    append([]float64{}, start.Pos...),
+		C:        c,
+		Alpha:    DefaultAlpha,
+		Gamma:    DefaultGamma,
+		Navg:     1,
+		Estimate: start.Clone(),
+		ev:       optim.SerialEvaler{},
+		best:     start.Clone(),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.InitStep == 0 {
+		m.InitStep = c
+	}
+
+	m.initdb()
+	return m
+}
+
+// AddPoint moves the iterate to p if p's value is lower than the current
+// iterate's estimated value.
+func (m *Method) AddPoint(p *optim.Point) {
+	if p.Val < m.Estimate.Val {
+		m.Theta = append([]float64{}, p.Pos...)
+		m.Estimate = p.Clone()
+	}
+	if p.Val < m.best.Val {
+		m.best = p.Clone()
+	}
+}
+
+// Iterate performs one SPSA step.  The returned point is the best point
+// evaluated so far - see Estimate for the iterate's estimated value.
+func (m *Method) Iterate(obj optim.Objectiver, mesh optim.Mesh) (best *optim.Point, n int, err error) {
+	m.iter++
+	defer m.updateDb()
+
+	if bm, ok := mesh.(*optim.BoxMesh); ok && m.Low == nil {
+		m.Low, m.Up = bm.Lower, bm.Upper
+	}
+	m.Theta = m.clip(m.Theta)
+
+	ndim := len(m.Theta)
+	ck := m.C / math.Pow(float64(m.k+1), m.Gamma)
+	m.ck = ck
+	deltas := make([][]float64, m.Navg)
+	plus := make([]*optim.Point, m.Navg)
+	minus := make([]*optim.Point, m.Navg)
+	pts := []*optim.Point{}
+	for q := range deltas {
+		deltas[q] = make([]float64, ndim)
+		pp := make([]float64, ndim)
+		pm := make([]float64, ndim)
+		for i := range deltas[q] {
+			deltas[q][i] = float64(2*optim.Rand.Intn(2) - 1)
+			pp[i] = m.Theta[i] + ck*deltas[q][i]
+			pm[i] = m.Theta[i] - ck*deltas[q][i]
+		}
+		plus[q] = &optim.Point{Pos: mesh.Nearest(m.clip(pp)), Val: math.Inf(1)}
+		minus[q] = &optim.Point{Pos: mesh.Nearest(m.clip(pm)), Val: math.Inf(1)}
+		pts = append(pts, plus[q], minus[q])
+	}
+
+	results, n, err := m.ev.Eval(obj, pts...)
+	if err != nil {
+		return m.best, n, err
+	}
+	// evalers skip duplicate points - which are common for small ndim or
+	// coarse meshes - so share their values
+	vals := map[[sha1.Size]byte]float64{}
+	for _, p := range results {
+		vals[p.Hash()] = p.Val
+	}
+	for _, p := range pts {
+		p.Val = vals[p.Hash()]
+		m.update(p)
+	}
+
+	// bounds and the mesh can shorten perturbations so difference over the
+	// actual distance between the evaluated points
+	g := make([]float64, ndim)
+	fest := 0.0
+	for q := range deltas {
+		diff := plus[q].Val - minus[q].Val
+		for i := range g {
+			if h := plus[q].Pos[i] - minus[q].Pos[i]; h != 0 {
+				g[i] += diff / h / float64(m.Navg)
+			}
+		}
+		fest += (plus[q].Val + minus[q].Val) / float64(2*m.Navg)
+	}
+	m.Estimate = &optim.Point{Pos: mesh.Nearest(m.Theta), Val: fest}
+
+	if m.A == 0 {
+		gmax := 0.0
+		for _, v := range g {
+			gmax = math.Max(gmax, math.Abs(v))
+		}
+		if gmax == 0 || math.IsInf(gmax, 0) || math.IsNaN(gmax) {
+			// can't calibrate from this estimate - try again next iteration
+			return m.best, n, nil
+		}
+		m.A = m.InitStep * math.Pow(m.BigA+1, m.Alpha) / gmax
+	}
+
+	ak := m.A / math.Pow(float64(m.k+1)+m.BigA, m.Alpha)
+	m.ak = ak
+	next := make([]float64, ndim)
+	maxmove := 0.0
+	for i := range next {
+		next[i] = m.Theta[i] - ak*g[i]
+	}
+	next = m.clip(next)
+	for i := range next {
+		maxmove = math.Max(maxmove, math.Abs(next[i]-m.Theta[i]))
+	}
+	m.k++
+
+	m.blocked = math.IsNaN(maxmove) || (m.MaxStep > 0 && maxmove > m.MaxStep)
+	if !m.blocked && m.BlockTol > 0 {
+		p := &optim.Point{Pos: mesh.Nearest(next), Val: math.Inf(1)}
+		_, ni, err := m.ev.Eval(obj, p)
+		n += ni
+		if err != nil {
+			return m.best, n, err
+		}
+		m.update(p)
+		m.blocked = p.Val > fest+m.BlockTol
+		if !m.blocked {
+			m.Theta = next
+			m.Estimate = p
+			return m.best, n, nil
+		}
+	}
+
+	if m.blocked {
+		m.Nblocked++
+	} else {
+		m.Theta = next
+	}
+	return m.best, n, nil
+}
+
+// update records p as the best point evaluated if it is better.
+func (m *Method) update(p *optim.Point) {
+	if p.Val < m.best.Val {
+		m.best = p.Clone()
+	}
+}
+
+func (m *Method) clip(x []float64) []float64 {
+	pos := append([]float64{}, x...)
+	if m.Low == nil {
+		return pos
+	}
+	for i := range pos {
+		pos[i] = math.Min(m.Up[i], math.Max(m.Low[i], pos[i]))
+	}
+	return pos
+}
+
+func (m *Method) initdb() {
+	if m.Db == nil {
+		return
+	}
+
+	s := "CREATE TABLE IF NOT EXISTS " + TblIter + " (iter INTEGER,val REAL,ak REAL,ck REAL,blocked INTEGER,posid BLOB);"
+	_, err := m.Db.Exec(s)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func (m *Method) updateDb() {
+	if m.Db == nil {
+		return
+	}
+
+	tx, err := m.Db.Begin()
+	if err != nil {
+		panic(err.Error())
+	}
+	defer tx.Commit()
+
+	s := "INSERT INTO " + TblIter + " (iter,val,ak,ck,blocked,posid) VALUES (?,?,?,?,?,?);"
+	_, err = tx.Exec(s, m.iter, m.Estimate.Val, m.ak, m.ck, m.blocked, m.Estimate.HashSlice())
+	if checkdberr(err) {
+		return
+	}
+
+	err = optim.RecordPointPos(tx, m.Estimate)
+	if checkdberr(err) {
+		return
+	}
+}
+
+func checkdberr(err error) bool {
+	if err != nil {
+		log.Print("spsa: db write failed -", err)
+		return true
+	}
+	return false
+}
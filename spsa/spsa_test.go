@@ -0,0 +1,185 @@
+package spsa
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+
+	_ "github.com/baaaaam/go-sqlite/sqlite3"
+	"github.com/baaaaam/optim"
+)
+
+const noise = 0.1
+
+// noisy is a shifted sphere with its minimum at (1, 1, ...) plus normally
+// distributed noise.
+var noisy = optim.Func(func(x []float64) float64 {
+	tot := 0.0
+	for _, v := range x {
+		tot += (v - 1) * (v - 1)
+	}
+	return tot + noise*optim.RandNorm()
+})
+
+func start(ndim int, v float64) *optim.Point {
+	pos := make([]float64, ndim)
+	for i := range pos {
+		pos[i] = v
+	}
+	return &optim.Point{Pos: pos, Val: math.Inf(1)}
+}
+
+func dist(x []float64, v float64) float64 {
+	tot := 0.0
+	for _, xi := range x {
+		tot += (xi - v) * (xi - v)
+	}
+	return math.Sqrt(tot)
+}
+
+func TestNoisy(t *testing.T) {
+	for _, opts := range [][]Option{nil, {Average(4)}, {Block(3 * noise)}, {MaxStep(0.5)}} {
+		m := New(start(5, -2), 0.2, append(opts, Gains(0, 50))...)
+		solv := &optim.Solver{Method: m, Obj: noisy, Mesh: &optim.InfMesh{}, MaxIter: 2000}
+		solv.Run()
+
+		d := dist(m.Theta, 1)
+		t.Logf("[INFO] navg=%v: %v evals, %v blocked: distance %.4f", m.Navg, solv.Neval(), m.Nblocked, d)
+		if d > 0.1 {
+			t.Errorf("navg=%v: want iterate within 0.1 of optimum, got %v (%v)", m.Navg, m.Theta, d)
+		}
+	}
+}
+
+func TestGains(t *testing.T) {
+	m := New(start(2, -2), 0.5, Gains(2, 10), Average(3), Evaler(optim.ParallelEvaler{}))
+	_, n, err := m.Iterate(noisy, &optim.InfMesh{})
+	if err != nil {
+		t.Fatal(err)
+	} else if n > 4 {
+		// only 4 distinct perturbations exist in 2D
+		t.Errorf("want at most 4 evals, got %v", n)
+	}
+
+	for k := 1; k < 5; k++ {
+		m.Iterate(noisy, &optim.InfMesh{})
+		wantak := 2 / math.Pow(float64(k+1)+10, DefaultAlpha)
+		wantck := 0.5 / math.Pow(float64(k+1), DefaultGamma)
+		if math.Abs(m.ak-wantak) > 1e-12 || math.Abs(m.ck-wantck) > 1e-12 {
+			t.Errorf("iter %v: want (a_k, c_k) = (%v, %v), got (%v, %v)", k, wantak, wantck, m.ak, m.ck)
+		}
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	lin := optim.Func(func(x []float64) float64 { return -3 * x[0] })
+	m := New(start(1, 0), 0.1, InitStep(0.25))
+	m.Iterate(lin, &optim.InfMesh{})
+
+	// the first step moves InitStep along the steepest dimension
+	if got := m.Theta[0]; math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("want first step 0.25, got %v", got)
+	}
+}
+
+func TestClippedPerturbation(t *testing.T) {
+	// the perturbation below the lower bound is clipped so the evaluated
+	// points are only c apart instead of 2c
+	lin := optim.Func(func(x []float64) float64 { return -3 * x[0] })
+	m := New(start(1, 0), 0.1, Gains(1, 0), Bounds([]float64{0}, []float64{10}))
+	m.Iterate(lin, &optim.InfMesh{})
+
+	// a_0 = 1 so the step is the gradient estimate
+	if got := m.Theta[0]; math.Abs(got-3) > 1e-9 {
+		t.Errorf("want step 3, got %v", got)
+	}
+}
+
+func TestBestEvaluated(t *testing.T) {
+	evaluated := map[float64]float64{}
+	quad := optim.Func(func(x []float64) float64 {
+		v := (x[0] - 1) * (x[0] - 1)
+		evaluated[x[0]] = v
+		return v
+	})
+	m := New(start(1, -2), 0.3, Gains(0, 10))
+	solv := &optim.Solver{Method: m, Obj: quad, Mesh: &optim.InfMesh{}, MaxIter: 50}
+	solv.Run()
+
+	best := solv.Best()
+	if v, ok := evaluated[best.Pos[0]]; !ok || v != best.Val {
+		t.Errorf("best %v was not evaluated with that value", best)
+	}
+	for _, v := range evaluated {
+		if v < best.Val {
+			t.Errorf("evaluated %v but best is %v", v, best.Val)
+			break
+		}
+	}
+}
+
+func TestMaxStep(t *testing.T) {
+	steep := optim.Func(func(x []float64) float64 { return 1e6 * x[0] * x[0] })
+	m := New(start(1, 1), 0.01, Gains(1, 0), MaxStep(0.1))
+	for i := 0; i < 10; i++ {
+		m.Iterate(steep, &optim.InfMesh{})
+	}
+	if m.Nblocked != 10 || m.Theta[0] != 1 {
+		t.Errorf("want all 10 steps blocked at 1, got %v blocked at %v", m.Nblocked, m.Theta[0])
+	}
+}
+
+func TestBounds(t *testing.T) {
+	low, up := []float64{-5, -5, -5}, []float64{0.5, 5, 5}
+	m := New(start(3, -2), 0.2, Gains(0, 50), Bounds(low, up))
+	solv := &optim.Solver{Method: m, Obj: noisy, Mesh: &optim.InfMesh{}, MaxIter: 2000}
+	solv.Run()
+
+	t.Logf("[INFO] got %v", m.Theta)
+	if m.Theta[0] > 0.5 || m.Theta[0] < 0.49 {
+		t.Errorf("want first variable at its upper bound 0.5, got %v", m.Theta[0])
+	}
+	if d := dist(m.Theta[1:], 1); d > 0.1 {
+		t.Errorf("want unbounded variables within 0.1 of optimum, got %v", m.Theta[1:])
+	}
+}
+
+func TestIntMesh(t *testing.T) {
+	obj := optim.Func(func(x []float64) float64 {
+		a, b := x[0]-3.3, x[1]+7.8
+		return a*a + b*b
+	})
+	m := New(start(2, 20), 2, Gains(0, 20))
+	mesh := &optim.IntMesh{Mesh: &optim.InfMesh{StepSize: 1}}
+	solv := &optim.Solver{Method: m, Obj: obj, Mesh: mesh, MaxIter: 1000}
+	solv.Run()
+
+	want := []float64{3, -8}
+	if got := mesh.Nearest(m.Theta); got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestDb(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	solv := &optim.Solver{
+		Method:  New(start(3, 1), 0.5, DB(db)),
+		Obj:     noisy,
+		Mesh:    &optim.InfMesh{},
+		MaxIter: 10,
+	}
+	solv.Run()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM " + TblIter).Scan(&count)
+	if err != nil {
+		t.Errorf("[ERROR] %v table query failed: %v", TblIter, err)
+	} else if count != solv.Niter() {
+		t.Errorf("[ERROR] %v table has %v rows, want %v", TblIter, count, solv.Niter())
+	}
+}
@@ -429,7 +429,7 @@ func (m *Method) updateDb(mesh optim.Mesh) {
 			return
 		}
 
-		pp := &optim.Point{mesh.Nearest(p.Pos), p.Val}
+		pp := &optim.Point{Pos: mesh.Nearest(p.Pos), Val: p.Val}
 		_, err = s0b.Exec(p.Id, m.iter, p.Val, pp.HashSlice())
 		if checkdberr(err) {
 			return
@@ -8,6 +8,7 @@ import (
 	_ "github.com/baaaaam/go-sqlite/sqlite3"
 	"github.com/baaaaam/optim"
 	"github.com/baaaaam/optim/bench"
+	"github.com/gonum/matrix/mat64"
 )
 
 type fakeRand struct {
@@ -138,11 +139,11 @@ func TestParticle_Move(t *testing.T) {
 
 	// initialize and execute
 	p := &Particle{
-		Point: &optim.Point{x0, 42},
+		Point: &optim.Point{Pos: x0, Val: 42},
 		Vel:   v0,
-		Best:  &optim.Point{xbest, 41},
+		Best:  &optim.Point{Pos: xbest, Val: 41},
 	}
-	glob := &optim.Point{globest, 41}
+	glob := &optim.Point{Pos: globest, Val: 41}
 
 	p.Move(glob, vmax, DefaultInertia, DefaultSocial, DefaultCognition)
 
@@ -197,6 +198,36 @@ func TestDb(t *testing.T) {
 	}
 }
 
+func TestPolyMesh(t *testing.T) {
+	// keep all particles inside x + 2y <= 1
+	A := mat64.NewDense(1, 2, []float64{1, 2})
+	up := mat64.NewDense(1, 1, []float64{1})
+	low := mat64.NewDense(1, 1, []float64{-100})
+
+	fn := bench.Rosenbrock{NDim: 2}
+	infeasible := 0
+	obj := optim.Func(func(x []float64) float64 {
+		if x[0]+2*x[1] > 1+1e-9 {
+			infeasible++
+		}
+		return fn.Eval(x)
+	})
+
+	low0, up0 := fn.Bounds()
+	solv := &optim.Solver{
+		Method:  New(NewPopulationRand(20, low0, up0), VmaxBounds(low0, up0)),
+		Obj:     obj,
+		Mesh:    &optim.PolyMesh{Mesh: &optim.InfMesh{}, A: A, Low: low, Up: up, Lower: low0, Upper: up0},
+		MaxIter: 200,
+	}
+	solv.Run()
+
+	t.Logf("[INFO] %v evals: got %v", solv.Neval(), solv.Best())
+	if infeasible > 0 {
+		t.Errorf("%v infeasible points evaluated", infeasible)
+	}
+}
+
 func swarmsolver(fn bench.Func, db *sql.DB) (optim.Method, optim.Mesh) {
 	low, up := fn.Bounds()
 	n := 20 + 1*len(low)